@@ -6,9 +6,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"runtime"
 	"strings"
 	"time"
 
+	shellquote "github.com/kballard/go-shellquote"
 	"github.com/pterm/pterm"
 	"github.com/urfave/cli/v2"
 
@@ -16,6 +18,10 @@ import (
 
 	"github.com/ayoisaiah/f2/find"
 	"github.com/ayoisaiah/f2/internal/config"
+	"github.com/ayoisaiah/f2/internal/file"
+	internalpath "github.com/ayoisaiah/f2/internal/path"
+	"github.com/ayoisaiah/f2/internal/pattern"
+	"github.com/ayoisaiah/f2/internal/warning"
 	"github.com/ayoisaiah/f2/rename"
 	"github.com/ayoisaiah/f2/replace"
 	"github.com/ayoisaiah/f2/report"
@@ -30,41 +36,57 @@ var errRenameFailed = errors.New(
 	"some files could not be renamed. Revert the changes through the --undo flag",
 )
 
+// ErrNoMatches is returned when the find pattern does not match any files.
+var ErrNoMatches = errors.New("failed to match any files")
+
+// ExitCodeNoMatches is the exit status of the program if no files are
+// matched so that scripts can distinguish it from other errors.
+const ExitCodeNoMatches = 2
+
 const (
 	EnvUpdateNotifier = "F2_UPDATE_NOTIFIER"
 	EnvNoColor        = "NO_COLOR"
 	EnvF2NoColor      = "F2_NO_COLOR"
 	EnvDefaultOpts    = "F2_DEFAULT_OPTS"
+	EnvManifest       = "F2_MANIFEST"
+	EnvForbidExec     = "F2_FORBID_EXEC"
+	EnvConfig         = "F2_CONFIG"
 )
 
 // supportedDefaultFlags contains those flags that can be
 // overridden through the `F2_DEFAULT_OPTS` environmental variable.
 var supportedDefaultFlags = []string{
-	"hidden", "allow-overwrites", "exclude", "exec", "fix-conflicts", "include-dir", "ignore-case", "ignore-ext", "json", "max-depth", "no-color", "only-dir", "quiet", "recursive", "replace-limit", "sort", "sortr", "string-mode", "verbose",
+	"hidden", "allow-move", "allow-overwrites", "backup-fallback", "broken-symlinks", "config", "default-stem", "depth", "dir-mode", "dirs-first", "dirs-last", "empty-name-fallback", "exclude", "exec", "ext", "fail-fast", "find-includes-ext", "fix-conflicts", "group", "include-dir", "index-per-root", "ignore-case", "ignore-ext", "json", "json-stream", "long-paths", "match-symlinks-only", "max-depth", "min-depth", "no-color", "no-fix-chars", "no-fix-exists", "no-fix-length", "no-fix-period", "only-dir", "output-format", "overwrite-if", "owner", "preserve-subdir-structure", "protect-prefix", "protect-suffix", "quiet", "rate", "recursive", "regex-engine", "replace-limit", "respect-gitignore", "safe", "show-index", "skip-conforming", "sort", "sortr", "start-step", "stat-max-bytes", "string-mode", "trim-to", "unaccent", "verbose", "with-xattrs", "workers",
 }
 
 // getDefaultOptsCtx creates a new `cli.Context` that represents the
 // program's options if it were run solely with the flags and arguments
 // represented in the `F2_DEFAULT_OPTS` environmental variable.
-// If this variable does not exist in the env, the returned Context
-// is `nil`.
+// The value is split into arguments with the quoting rules of a POSIX
+// shell so that option values may contain spaces. If this variable does
+// not exist in the env or cannot be parsed, the returned Context is `nil`.
 func getDefaultOptsCtx() *cli.Context {
 	var defaultCtx *cli.Context
 
 	if optsEnv, exists := os.LookupEnv(EnvDefaultOpts); exists {
-		defaultOpts := make([]string, len(os.Args))
+		args, err := shellquote.Split(optsEnv)
+		if err != nil {
+			report.Warning(
+				fmt.Sprintf("ignoring %s: %v", EnvDefaultOpts, err),
+			)
 
-		copy(defaultOpts, os.Args)
+			return nil
+		}
 
-		defaultOpts = append(defaultOpts[:1], strings.Split(optsEnv, " ")...)
+		defaultOpts := append([]string{os.Args[0]}, args...)
 
 		app := NewApp()
 
 		app.Before = func(c *cli.Context) error {
 			if c.IsSet("find") || c.IsSet("replace") || c.IsSet("csv") ||
 				c.IsSet("undo") {
-				pterm.Fprintln(os.Stderr,
-					pterm.Warning.Sprintf(
+				report.Warning(
+					fmt.Sprintf(
 						"%s are not supported as default options",
 						"'find', 'replace', 'csv' and 'undo'",
 					),
@@ -89,6 +111,9 @@ func getDefaultOptsCtx() *cli.Context {
 func GetApp(reader io.Reader, writer io.Writer) *cli.App {
 	app := NewApp()
 
+	// warnings are collected afresh for each operation
+	warning.Reset()
+
 	defaultCtx := getDefaultOptsCtx()
 
 	app.Before = func(c *cli.Context) error {
@@ -115,8 +140,8 @@ func GetApp(reader io.Reader, writer io.Writer) *cli.App {
 
 				err := c.Set(defaultFlag, value)
 				if err != nil {
-					pterm.Fprintln(os.Stderr,
-						pterm.Warning.Sprintf(
+					report.Warning(
+						fmt.Sprintf(
 							"Unable to set default option for: %s",
 							defaultFlag,
 						),
@@ -267,10 +292,91 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Aliases: []string{"u"},
 				Usage:   "Undo the last operation performed in the current working directory if possible.\n\t\t\t\tLearn more: https://github.com/ayoisaiah/f2/wiki/Undoing-a-renaming-operation.",
 			},
+			&cli.BoolFlag{
+				Name:  "undo-dry-run",
+				Usage: "Preview the undo of the last operation performed in the current working directory without modifying the filesystem.\n\t\t\t\tImplies -u/--undo and takes precedence over -x/--exec.",
+			},
+			&cli.StringFlag{
+				Name:        "undo-file",
+				Usage:       "Undo the renaming operation recorded in the specified backup file regardless of the current working directory.\n\t\t\t\tImplies -u/--undo. Unlike the backup files that are found automatically, this file is not deleted afterwards.",
+				DefaultText: "<path/to/backup/file>",
+				TakesFile:   true,
+			},
 			&cli.BoolFlag{
 				Name:  "allow-overwrites",
 				Usage: "Allow the renaming operation to overwite existing files.\n\t\t\t\tNote that using this option can lead to unrecoverable data loss in the renamed files.",
 			},
+			&cli.BoolFlag{
+				Name:  "allow-move",
+				Usage: "Allow targets to be moved out of their original directory when --preserve-subdir-structure is set.",
+			},
+			&cli.StringFlag{
+				Name:        "apply-plan",
+				Usage:       "Commit the renaming operation recorded in a plan file created with --save-plan.\n\t\t\t\tThe plan is checked for conflicts again but the targets are never changed so that exactly the reviewed changes are applied.",
+				DefaultText: "<path/to/plan>",
+				TakesFile:   true,
+			},
+			&cli.BoolFlag{
+				Name:  "backup-fallback",
+				Usage: "Write the backup file to the temporary directory if the backup directory is not writable.\n\t\t\t\tOtherwise, the renaming operation is aborted before any file is renamed.",
+			},
+			&cli.BoolFlag{
+				Name:  "broken-symlinks",
+				Usage: "Only match symbolic links whose target does not exist. Implies --match-symlinks-only.",
+			},
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       "Load the extension rules from the specified config file instead of 'f2/config.json' in the user's config directory.\n\t\t\t\tEach rule is a find and replace pair that applies by default to the files with one of its extensions.\n\t\t\t\tThe rules are ignored if -f/--find or -r/--replace is set.\n\t\t\t\tThe config file may also define named pipelines of find and replace steps for --pipeline.\n\t\t\t\tThe rules and pipelines in a '.f2.json' file in the current directory take precedence over those in the config file.",
+				DefaultText: "<path/to/config/file>",
+				EnvVars:     []string{EnvConfig},
+				TakesFile:   true,
+			},
+			&cli.BoolFlag{
+				Name:  "count",
+				Usage: "Print the number of files that match the search pattern in each directory and exit.\n\t\t\t\tA replacement string is not required in this mode.",
+			},
+			&cli.StringFlag{
+				Name:        "counter-file",
+				Usage:       "Continue the numbering of the indexing variables (such as %03d) from the last number recorded in the specified file.\n\t\t\t\tThe highest number used is written back to the file after the renaming operation is executed so that\n\t\t\t\tthe next operation continues from there. The file is locked while it is in use by a renaming operation.",
+				DefaultText: "<path/to/counter/file>",
+				TakesFile:   true,
+			},
+			&cli.BoolFlag{
+				Name:  "default-stem",
+				Usage: "Match only the file name without its extension when no find pattern is provided, and keep the original extension.\n\t\t\t\tUnlike -e/--ignore-ext, explicit find patterns are still matched against the full file name.",
+			},
+			&cli.IntFlag{
+				Name:        "depth",
+				Usage:       "Only match the entries at the specified depth of a recursive search (the same as setting both --min-depth and --max-depth).\n\t\t\t\tA value of 0 refers to the entries directly within each path argument.",
+				DefaultText: "<integer>",
+			},
+			&cli.StringFlag{
+				Name:        "dir-mode",
+				Usage:       "Set the permissions (in octal) of the directories that are created during the renaming operation.\n\t\t\t\tThe umask of the current process still applies.",
+				Value:       "0750",
+				DefaultText: "<octal>",
+			},
+			&cli.BoolFlag{
+				Name:  "dirs-first",
+				Usage: "Place the matched directories before the files (after sorting) which also affects the numbering of indexing variables.",
+			},
+			&cli.BoolFlag{
+				Name:  "dirs-last",
+				Usage: "Place the matched directories after the files (after sorting) which also affects the numbering of indexing variables.",
+			},
+			&cli.BoolFlag{
+				Name:  "edit",
+				Usage: "Open the new names in your text editor ($VISUAL or $EDITOR) before renaming.\n\t\t\t\tEach line is prefixed with the number of the match it refers to which must be left intact.\n\t\t\t\tDelete a line to skip renaming the corresponding file.",
+			},
+			&cli.BoolFlag{
+				Name:  "empty",
+				Usage: "Only match empty files (zero bytes in size). Directories are matched (with -d/--include-dir) if they have no entries.",
+			},
+			&cli.StringFlag{
+				Name:        "empty-name-fallback",
+				Usage:       "Use the provided name for files that would otherwise be renamed to an empty string.\n\t\t\t\tA number is appended to the name if necessary to keep each target unique.",
+				DefaultText: "<name>",
+			},
 			&cli.StringSliceFlag{
 				Name:        "exclude",
 				Aliases:     []string{"E"},
@@ -282,11 +388,68 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Aliases: []string{"x"},
 				Usage:   "Execute the renaming operation and commit the changes to the filesystem.",
 			},
+			&cli.StringFlag{
+				Name:        "exec-after",
+				Usage:       "Run the specified command for each path that was renamed successfully with -x/--exec.\n\t\t\t\tThe {{source}} and {{target}} placeholders in the command are replaced with the old and new paths.\n\t\t\t\tThe command is not run through a shell and a failure is reported without aborting the remaining commands.",
+				DefaultText: "<command>",
+			},
+			&cli.BoolFlag{
+				Name:  "exec-after-fail-fast",
+				Usage: "Stop running the --exec-after command at the first failure and exit with an error.",
+			},
+			&cli.BoolFlag{
+				Name:  "explain",
+				Usage: "Print the reason that each file or directory was not matched to the standard error.",
+			},
+			&cli.StringSliceFlag{
+				Name:        "ext",
+				Usage:       "Only match files with the provided extension. Other files are skipped as soon as they are\n\t\t\t\tencountered which speeds up searches in large directory trees.\n\t\t\t\tMultiple extensions can be specified by repeating this option in a command.\n\n\t\t\t\tE.g: `--ext jpg --ext png` only matches JPEG and PNG files.",
+				DefaultText: "<extension>",
+			},
+			&cli.BoolFlag{
+				Name:  "fail-fast",
+				Usage: "Stop the renaming operation as soon as an error is encountered instead of attempting the remaining files.\n\t\t\t\tThe files that were renamed before the error are still recorded for the undo operation.",
+			},
+			&cli.BoolFlag{
+				Name:        "find-includes-ext",
+				Usage:       "Whether the find pattern is matched against the file extension. Defaults to true unless -e/--ignore-ext is set.\n\t\t\t\t--ignore-ext --find-includes-ext: the extension is searched but the original extension is always kept.\n\t\t\t\t--find-includes-ext=false: the extension is not searched and it is reattached to the new name.",
+				DefaultText: "<true|false>",
+			},
+			&cli.StringSliceFlag{
+				Name:        "find-dir",
+				Usage:       "Search pattern for directories. When set, -f/--find and -r/--replace apply only to files\n\t\t\t\twhile directories are renamed with --find-dir and --replace-dir. Implies -d/--include-dir.",
+				DefaultText: "<pattern>",
+			},
+			&cli.BoolFlag{
+				Name:  "find-duplicate-names",
+				Usage: "Print the matches that share the same name across different directories and exit.\n\t\t\t\tThese would collide if the matches were moved into a single directory.",
+			},
 			&cli.BoolFlag{
 				Name:    "fix-conflicts",
 				Aliases: []string{"F"},
 				Usage:   "Automatically fix renaming conflicts based on predefined rules.\n\t\t\t\tLearn more: https://github.com/ayoisaiah/f2/wiki/Validation-and-conflict-detection.",
 			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Operate on protected system locations (such as the root directory or the home directory) which are refused by default.\n\t\t\t\tMore locations can be protected through the protected_paths key in the config file.",
+			},
+			&cli.StringFlag{
+				Name:        "group",
+				Usage:       "Match only the files and directories that belong to the specified group (name or numeric id).\n\t\t\t\tThis option has no effect on Windows.",
+				DefaultText: "<group>",
+			},
+			&cli.StringFlag{
+				Name:        "hash-in",
+				Usage:       "Match only the files whose content hash is in the list (one md5, sha1, sha256, or sha512 hash per line).\n\t\t\t\tThe output of tools like sha256sum may be used directly. Directories are not matched.",
+				DefaultText: "<path/to/hash/list>",
+				TakesFile:   true,
+			},
+			&cli.StringFlag{
+				Name:        "hash-not-in",
+				Usage:       "Match only the files whose content hash is not in the list (one md5, sha1, sha256, or sha512 hash per line).\n\t\t\t\tThis is useful for renaming only the files that have not been seen before. Directories are not matched.",
+				DefaultText: "<path/to/hash/list>",
+				TakesFile:   true,
+			},
 			&cli.BoolFlag{
 				Name:    "hidden",
 				Aliases: []string{"H"},
@@ -297,6 +460,10 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Aliases: []string{"d"},
 				Usage:   "Match directories in the renaming operation (they are exempted by default).",
 			},
+			&cli.BoolFlag{
+				Name:  "index-per-root",
+				Usage: "Start the numbering of indexing variables (such as {%03d}) afresh for each path argument\n\t\t\t\tinstead of numbering all the matches in a single sequence.",
+			},
 			&cli.BoolFlag{
 				Name:    "ignore-case",
 				Aliases: []string{"i"},
@@ -311,10 +478,41 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Name:  "json",
 				Usage: "Always produce JSON output except for error messages which go to the standard error",
 			},
-			&cli.UintFlag{
+			&cli.BoolFlag{
+				Name:  "json-stream",
+				Usage: "Produce JSON output with one object per line for each change followed by a summary object.\n\t\t\t\tUnlike --json, the changes are not collected into a single array before they are printed.",
+			},
+			&cli.BoolFlag{
+				Name:  "long-paths",
+				Usage: "Use extended-length paths so that targets may exceed the limit of 260 characters in Windows.\n\t\t\t\tSome programs may be unable to open such paths. This option has no effect on other platforms.",
+			},
+			&cli.StringFlag{
+				Name:        "manifest",
+				Usage:       "Append a record of each renamed file to the specified file (one JSON object per line).\n\t\t\t\tUnlike the backup files used by --undo, the manifest is never overwritten.",
+				DefaultText: "<path/to/manifest>",
+				EnvVars:     []string{EnvManifest},
+				TakesFile:   true,
+			},
+			&cli.StringFlag{
+				Name:        "map-file",
+				Usage:       "Rename the files according to a table of exact mappings with one SOURCE=TARGET line per file.\n\t\t\t\tOnly the files whose name is listed as a source are renamed. The names that do not match any file are reported.",
+				DefaultText: "<path/to/map/file>",
+				TakesFile:   true,
+			},
+			&cli.BoolFlag{
+				Name:  "match-symlinks-only",
+				Usage: "Only match symbolic links. The links themselves are renamed rather than their targets.",
+			},
+			&cli.StringFlag{
 				Name:        "max-depth",
 				Aliases:     []string{"m"},
-				Usage:       "Indicates the maximum depth for a recursive search (set to 0 by default for no limit).",
+				Usage:       "Indicates the maximum depth for a recursive search.\n\t\t\t\tIt's set to 'unlimited' (or -1) by default indicating that there is no limit.\n\t\t\t\tA value of 0 restricts the search to the current level only.",
+				Value:       "unlimited",
+				DefaultText: "<integer|unlimited>",
+			},
+			&cli.IntFlag{
+				Name:        "min-depth",
+				Usage:       "Indicates the minimum depth of the entries to match in a recursive search. Implies -R/--recursive.\n\t\t\t\tIt's set to 0 by default which includes the entries directly within each path argument.",
 				Value:       0,
 				DefaultText: "<integer>",
 			},
@@ -322,38 +520,166 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Name:  "no-color",
 				Usage: "Disable coloured output.",
 			},
+			&cli.BoolFlag{
+				Name:  "no-fix-chars",
+				Usage: "Report forbidden characters in the new names even when -F/--fix-conflicts is set.",
+			},
+			&cli.BoolFlag{
+				Name:  "no-fix-exists",
+				Usage: "Report new names that already exist even when -F/--fix-conflicts is set.",
+			},
+			&cli.BoolFlag{
+				Name:  "no-fix-length",
+				Usage: "Report new names that exceed the maximum length even when -F/--fix-conflicts is set.",
+			},
+			&cli.BoolFlag{
+				Name:  "no-fix-period",
+				Usage: "Report trailing periods in the new names (Windows only) even when -F/--fix-conflicts is set.",
+			},
+			&cli.BoolFlag{
+				Name:  "non-empty",
+				Usage: "Only match files that are not empty. Directories are matched (with -d/--include-dir) if they have at least one entry.",
+			},
+			&cli.StringFlag{
+				Name:        "normalize-separators-to",
+				Usage:       "Replace each run of spaces, underscores and hyphens in the matched names with the provided character.\n\t\t\t\tThis is a shorthand for -f '[ _-]+' -r <char> so it cannot be combined with -f/--find or -r/--replace.",
+				DefaultText: "<char>",
+			},
 			&cli.BoolFlag{
 				Name:    "only-dir",
 				Aliases: []string{"D"},
 				Usage:   "Rename only directories, not files (implies -d/--include-dir).",
 			},
+			&cli.StringFlag{
+				Name:        "output-format",
+				Usage:       "The format of the report of the renaming operation: 'table', 'tree', 'json' (same as --json), or 'html'.\n\t\t\t\tThe 'tree' format groups the changes under their directories which is easier to scan for recursive operations.\n\t\t\t\tThe 'html' format renders the changes and any conflicts as a self-contained HTML page.",
+				Value:       config.FormatTable,
+				DefaultText: "<table|tree|json|html>",
+			},
+			&cli.StringFlag{
+				Name:        "order-file",
+				Usage:       "Order the matches according to a file that lists one file name (or path relative to the file) per line.\n\t\t\t\tMatches that are not listed in the file are placed last. Indexes such as {%03d} are assigned in this order.",
+				DefaultText: "<path/to/order/file>",
+				TakesFile:   true,
+			},
+			&cli.StringFlag{
+				Name:        "overwrite-if",
+				Usage:       "Determines when existing paths may be overwritten (implies --allow-overwrites).\n\t\t\t\tOne of 'always', 'newer' (the source was modified more recently), or 'larger' (the source is bigger).\n\t\t\t\tMatches that do not satisfy the policy are left unchanged.",
+				Value:       "always",
+				DefaultText: "<always|newer|larger>",
+			},
+			&cli.StringFlag{
+				Name:        "owner",
+				Usage:       "Match only the files and directories that are owned by the specified user (name or numeric id).\n\t\t\t\tThis option has no effect on Windows.",
+				DefaultText: "<user>",
+			},
+			&cli.StringFlag{
+				Name:        "pipeline",
+				Usage:       "Rename the matches with the named pipeline in the config file. A pipeline is an ordered list of find and replace steps\n\t\t\t\twhich are applied like a chain of -f/--find and -r/--replace options. A step without a find pattern matches the entire name.\n\t\t\t\tIt cannot be combined with -f/--find or -r/--replace.",
+				DefaultText: "<name>",
+			},
+			&cli.BoolFlag{
+				Name:  "preserve-subdir-structure",
+				Usage: "Keep each renamed path in its original directory so that only the base name is changed.\n\t\t\t\tThe renaming operation is aborted if a target includes a different directory unless --allow-move is set.",
+			},
+			&cli.BoolFlag{
+				Name:  "print-config",
+				Usage: "Print the effective options in JSON format and exit without renaming anything.\n\t\t\t\tThe options reflect the config files, F2_DEFAULT_OPTS, and the flags on the command line (in increasing order of precedence).",
+			},
+			&cli.StringFlag{
+				Name:        "protect-prefix",
+				Usage:       "Keep the specified prefix of the file names untouched by the find and replace operation.\n\t\t\t\tIt is split off each name that starts with it before replacing and reattached afterwards.",
+				DefaultText: "<string>",
+			},
+			&cli.StringFlag{
+				Name:        "protect-suffix",
+				Usage:       "Keep the specified suffix of the file names untouched by the find and replace operation.\n\t\t\t\tThe suffix is matched before the extension if -e/--ignore-ext is set.",
+				DefaultText: "<string>",
+			},
 			&cli.BoolFlag{
 				Name:    "quiet",
 				Aliases: []string{"q"},
 				Usage:   "Don't print out any information to the standard output.\n\t\t\t\tErrors will continue being sent to the standard error",
 			},
+			&cli.IntFlag{
+				Name:        "rate",
+				Usage:       "Limit the renaming operation (and the files that are read for the variables in the replacement)\n\t\t\t\tto the specified number of filesystem operations per second. Useful on shared or network storage.",
+				DefaultText: "<integer>",
+			},
 			&cli.BoolFlag{
 				Name:    "recursive",
 				Aliases: []string{"R"},
 				Usage:   "Recursively traverse directories when searching for matches.",
 			},
-			&cli.IntFlag{
+			&cli.StringFlag{
+				Name:        "regex-engine",
+				Usage:       "The regular expression engine for the find patterns: 're2' or 'pcre'.\n\t\t\t\tThe 'pcre' engine supports lookarounds and backreferences, but it backtracks so some patterns can be very slow to match.\n\t\t\t\tEach match is aborted after one second in that case and treated as a non-match.\n\t\t\t\tIn the replacement string, capture groups are referenced as $1 or ${name} with either engine.",
+				Value:       pattern.RE2,
+				DefaultText: "<re2|pcre>",
+			},
+			&cli.StringSliceFlag{
+				Name:        "replace-dir",
+				Usage:       "Replacement string or pattern for directories. See --find-dir.",
+				DefaultText: "<string>",
+			},
+			&cli.StringFlag{
+				Name:        "replace-if",
+				Usage:       "Only apply the replacement to the files whose original names match the provided regular expression pattern.\n\t\t\t\tUnlike -E/--exclude, the other files are still reported but they keep their original names.",
+				DefaultText: "<pattern>",
+			},
+			&cli.StringFlag{
 				Name:        "replace-limit",
 				Aliases:     []string{"l"},
-				Usage:       "Limit the number of replacements to be made on each matched file.\n\t\t\t\tIt's set to 0 by default indicating that all matches should be replaced.\n\t\t\t\tCan be set to a negative integer to start replacing from the end of the file name.",
-				Value:       0,
-				DefaultText: "<integer>",
+				Usage:       "Limit the number of replacements to be made on each matched file.\n\t\t\t\tIt's set to 0 by default indicating that all matches should be replaced.\n\t\t\t\tCan be set to a negative integer to start replacing from the end of the file name.\n\t\t\t\tUse START:COUNT to replace COUNT matches starting from the one at position START (from the end if negative).\n\t\t\t\tE.g: '-l 2:1' replaces only the second match and '-l -2:1' only the second to last one.\n\t\t\t\tCapture variables such as $1 refer to the groups of each replaced match.",
+				Value:       "0",
+				DefaultText: "<integer|start:count>",
+			},
+			&cli.BoolFlag{
+				Name:  "respect-gitignore",
+				Usage: "Skip the paths that are ignored by the '.gitignore' files of the git repository they belong to.\n\t\t\t\tThe contents of the ignored directories are skipped as well, but the paths that are specified as arguments are always matched.",
+			},
+			&cli.BoolFlag{
+				Name:  "restore-times",
+				Usage: "Restore the original modification times of the reverted files when used with -u/--undo.",
+			},
+			&cli.BoolFlag{
+				Name:    "safe",
+				Usage:   "Refuse to commit the renaming operation even if -x/--exec is set so that the filesystem is never modified.\n\t\t\t\tThis can also be enabled through the F2_FORBID_EXEC environmental variable.",
+				EnvVars: []string{EnvForbidExec},
+			},
+			&cli.BoolFlag{
+				Name:  "save-plan",
+				Usage: "Save the changes of a dry run to a plan file in the backups directory so that they can be applied later with --apply-plan.",
+			},
+			&cli.StringFlag{
+				Name:        "set-mtime-from",
+				Usage:       "Set the modification time of each renamed file to the date in its new name.\n\t\t\t\tThe value is split at the last colon into a regular expression that finds the date (the first capture group is used if present)\n\t\t\t\tand a Go time layout that parses it. Files whose date cannot be parsed are left untouched with a warning.\n\n\t\t\t\tE.g: `--set-mtime-from '(\\d{8}):20060102'` parses the date in 'IMG_20210314_1.jpg'.",
+				DefaultText: "<regex:layout>",
+			},
+			&cli.BoolFlag{
+				Name:  "show-index",
+				Usage: "Include the index of each change (starting from 0) in the table and JSON output.\n\t\t\t\tThe index is the position of the change in the order that the indexing variables such as {%d} follow.",
+			},
+			&cli.BoolFlag{
+				Name:  "since-last-run",
+				Usage: "Only match the files that were modified since the last renaming operation in the current working directory.\n\t\t\t\tThe date of the operation is read from its backup file so every file is matched if there is none (for example, after -u/--undo).",
+			},
+			&cli.StringFlag{
+				Name:        "skip-conforming",
+				Usage:       "Skip the files whose names already match the provided regular expression pattern\n\t\t\t\tso that files that conform to the desired naming are left out of the renaming operation\n\t\t\t\t(including the numbering of indexing variables).",
+				DefaultText: "<pattern>",
 			},
 			&cli.StringFlag{
 				Name: "sort",
 				Usage: `Sort the matches in ascending order according to the provided '<sort>'.
 					Allowed sort values:
-						'default' : alphabetical order.
-						'size'    : sort by file size.
-						'mtime'   : sort by file last modified time.
-						'btime'   : sort by file creation time.
-						'atime'   : sort by file last access time.
-						'ctime'   : sort by file metadata last change time.
+						'default'   : alphabetical order.
+						'size'      : sort by file size.
+						'extension' : sort by file extension and then by name.
+						'mtime'     : sort by file last modified time.
+						'btime'     : sort by file creation time.
+						'atime'     : sort by file last access time.
+						'ctime'     : sort by file metadata last change time.
 
         To sort results in reverse or descending order, use the --sortr flag. Also,
         this flag overrides --sortr. 
@@ -365,16 +691,60 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				Usage:       "Same options as --sort but presents the matches in the reverse order.",
 				DefaultText: "<sort>",
 			},
+			&cli.StringFlag{
+				Name:        "start-step",
+				Usage:       "Set the start number and the step of the indexing variables (such as %03d) that do not specify their own.\n\t\t\t\tThe value is in the form START:STEP and a missing part defaults to 1. E.g: '--start-step 10:5' numbers the matches 10, 15, 20...",
+				DefaultText: "<start:step>",
+			},
+			&cli.Int64Flag{
+				Name:        "stat-max-bytes",
+				Usage:       "The maximum size of the files whose lines and words are counted for the\n\t\t\t\t{{stat.lines}} and {{stat.words}} variables. Larger files resolve to an empty string.",
+				Value:       10 << 20,
+				DefaultText: "<integer>",
+			},
+			&cli.BoolFlag{
+				Name:  "stdin-names",
+				Usage: "Read the new names from the standard input (one per line) instead of using a replacement string.\n\t\t\t\tThe names are assigned to the matches in sorted order (see --sort)\n\t\t\t\tso the number of lines must be equal to the number of matches.",
+			},
 			&cli.BoolFlag{
 				Name:    "string-mode",
 				Aliases: []string{"s"},
 				Usage:   "Treats the search pattern (specified by -f/--find) as a non-regex string.",
 			},
+			&cli.IntFlag{
+				Name:        "trim-to",
+				Usage:       "Shorten the new names to at most the specified number of characters (excluding the extension).\n\t\t\t\tThe names are cut at the last word boundary before the limit so that words are kept whole.\n\t\t\t\tThe {{.trim:N}} transform can be used to do the same for specific parts of the name.",
+				DefaultText: "<integer>",
+			},
+			&cli.BoolFlag{
+				Name:  "unaccent",
+				Usage: "Remove diacritics from the new names (e.g. Mötley Crüe becomes Motley Crue) without any other changes.\n\t\t\t\tThe {{.unaccent}} transform can be used to do the same for specific parts of the name.",
+			},
+			&cli.StringFlag{
+				Name:        "validate-with",
+				Usage:       "Run the specified command for each proposed change and leave the changes that it rejects (with a non-zero exit status) unchanged.\n\t\t\t\tThe {{source}} and {{target}} placeholders in the command are replaced with the old and new paths.\n\t\t\t\tThe commands are run concurrently (see --workers) before conflicts are detected, including in dry-run mode.\n\t\t\t\tThey are not run through a shell, but they can do anything that you can so only use a validator that you trust and that has no side effects.",
+				DefaultText: "<command>",
+			},
 			&cli.BoolFlag{
 				Name:    "verbose",
 				Aliases: []string{"V"},
 				Usage:   "Enable verbose output during the renaming operation.",
 			},
+			&cli.BoolFlag{
+				Name:  "with-xattrs",
+				Usage: "Rename the AppleDouble file (._name) of each matched file along with it so that its extended attributes\n\t\t\t\tand resource fork are not left behind on filesystems that cannot store them natively.",
+			},
+			&cli.IntFlag{
+				Name:        "workers",
+				Usage:       "The number of files whose hashes, exif data, id3 tags, or text statistics are read concurrently\n\t\t\t\tbefore the variables in the replacement are substituted. Also the number of --validate-with commands that are run concurrently.\n\t\t\t\tDefaults to the number of CPUs.",
+				Value:       runtime.NumCPU(),
+				DefaultText: "<integer>",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Commit the renaming operation without confirming that existing paths may be overwritten.\n\t\t\t\tThe confirmation is required in quiet, JSON, and HTML mode unless this option is set.",
+			},
 		},
 		UseShortOptionHandling: true,
 		Action: func(ctx *cli.Context) error {
@@ -400,44 +770,87 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 			report.Stdout = conf.Stdout
 			report.Stderr = conf.Stderr
 
+			for _, msg := range conf.Warnings {
+				report.Warning(msg)
+			}
+
+			if conf.PrintConfig {
+				report.Config(conf)
+				return nil
+			}
+
 			jsonOpts := &internaljson.OutputOpts{
 				WorkingDir: conf.WorkingDir,
 				Date:       conf.Date,
 				Exec:       conf.Exec,
 				Print:      conf.JSON,
+				Stream:     conf.JSONStream,
+				HTML:       conf.OutputFormat == config.FormatHTML,
+				Tree:       conf.OutputFormat == config.FormatTree,
+				ShowIndex:  conf.ShowIndex,
 			}
 
 			if conf.Revert {
-				return rename.Undo(
-					conf.Exec,
-					conf.IncludeDir,
-					conf.Quiet,
-					conf.Revert,
-					conf.Verbose,
-					jsonOpts,
-				)
+				return rename.Undo(conf, jsonOpts)
 			}
 
-			matches, err := find.Find(conf)
-			if err != nil {
-				return err
-			}
+			var changes []*file.Change
 
-			if len(matches) == 0 {
-				report.NoMatches(jsonOpts)
-				return nil
-			}
+			var counter *rename.Counter
 
-			changes, err := replace.Replace(conf, matches)
-			if err != nil {
-				return err
+			if conf.ApplyPlan != "" {
+				changes, err = rename.ReadPlan(conf.ApplyPlan)
+				if err != nil {
+					return err
+				}
+			} else {
+				var matches internalpath.Collection
+
+				if conf.SinceLastRun {
+					conf.ModifiedSince, err = rename.LastRun(conf.WorkingDir)
+					if err != nil {
+						return err
+					}
+				}
+
+				matches, err = find.Find(conf)
+				if err != nil {
+					return err
+				}
+
+				if conf.Count {
+					report.Count(matches, conf.Quiet, jsonOpts)
+					return nil
+				}
+
+				if conf.FindDuplicateNames {
+					report.DuplicateNames(matches, conf.Quiet, jsonOpts)
+					return nil
+				}
+
+				if len(matches) == 0 {
+					report.NoMatches(jsonOpts)
+					return ErrNoMatches
+				}
+
+				if conf.CounterFile != "" {
+					counter, err = rename.LockCounter(conf.CounterFile)
+					if err != nil {
+						return err
+					}
+
+					defer counter.Unlock()
+
+					conf.CounterValue = counter.Value
+				}
+
+				changes, err = replace.Replace(conf, matches)
+				if err != nil {
+					return err
+				}
 			}
 
-			conflicts := validate.Validate(
-				changes,
-				conf.AutoFixConflicts,
-				conf.AllowOverwrites,
-			)
+			conflicts := validate.Validate(changes, conf)
 			if len(conflicts) > 0 {
 				report.Conflicts(
 					conflicts,
@@ -446,7 +859,18 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				return errConflictDetected
 			}
 
+			if conf.Verbose && conf.AutoFixConflicts {
+				report.Fixes(changes)
+			}
+
 			if !conf.Exec {
+				if conf.SavePlan {
+					err = rename.SavePlan(changes, jsonOpts)
+					if err != nil {
+						return err
+					}
+				}
+
 				report.Dry(
 					changes,
 					conf.IncludeDir,
@@ -457,16 +881,52 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				return nil
 			}
 
-			renameErrs := rename.Execute(
+			backupPath, err := rename.BackupPath(
+				conf.WorkingDir,
+				conf.BackupFallback,
+			)
+			if err != nil {
+				return err
+			}
+
+			renameErrs, err := rename.Execute(
 				changes,
-				conf.SimpleMode,
-				conf.Quiet,
-				conf.Revert,
-				conf.Verbose,
+				conf,
+				backupPath,
 				jsonOpts,
 			)
+			if err != nil {
+				return err
+			}
+
+			if counter != nil {
+				err = counter.Save(replace.HighestIndex())
+				if err != nil {
+					return err
+				}
+			}
+
+			if conf.MtimeRegex != nil {
+				rename.SetModTimes(changes, conf.MtimeRegex, conf.MtimeLayout)
+			}
 
-			if conf.JSON && !conf.SimpleMode || len(renameErrs) > 0 {
+			var hookErr error
+			if len(conf.ExecAfter) > 0 {
+				hookErr = rename.RunAfterHook(
+					changes,
+					conf.ExecAfter,
+					conf.ExecAfterFailFast,
+				)
+			}
+
+			machineOutput := conf.JSON || jsonOpts.HTML
+
+			switch {
+			case conf.SimpleMode && machineOutput:
+				// The changes were printed for review before they were
+				// committed so only the failures are reported here
+				report.Failures(changes)
+			case machineOutput || len(renameErrs) > 0:
 				report.Changes(
 					changes,
 					renameErrs,
@@ -479,7 +939,7 @@ or: f2 FIND [REPLACE] [PATHS TO FILES OR DIRECTORIES...]`
 				return errRenameFailed
 			}
 
-			return nil
+			return hookErr
 		},
 		OnUsageError: func(context *cli.Context, err error, isSubcommand bool) error {
 			return err
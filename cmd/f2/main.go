@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/pterm/pterm"
@@ -12,6 +13,10 @@ func main() {
 	app := f2.GetApp(os.Stdin, os.Stdout)
 
 	err := app.Run(os.Args)
+	if errors.Is(err, f2.ErrNoMatches) {
+		os.Exit(f2.ExitCodeNoMatches)
+	}
+
 	if err != nil {
 		pterm.EnableOutput()
 		pterm.Fprintln(os.Stderr, pterm.Error.Sprint(err))
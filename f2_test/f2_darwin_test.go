@@ -3,9 +3,56 @@
 
 package f2_test
 
-import "testing"
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestDarwin(t *testing.T) {
 	cases := retrieveTestCases(t, "darwin.json")
 	runTestCases(t, cases)
 }
+
+func TestWithXattrsExec(t *testing.T) {
+	testDir := setupFileSystem(t, "TestWithXattrsExec")
+
+	appleDouble := filepath.Join(testDir, "images", "._dsc-001.arw")
+
+	err := os.WriteFile(appleDouble, []byte("xattrs"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestWithXattrsExec",
+		"--with-xattrs -f dsc-001 -r photo -x images",
+	)
+
+	_, err = executeTest(args)
+	if err != nil {
+		t.Fatalf("Test (TestWithXattrsExec) — Unexpected error: %v", err)
+	}
+
+	if _, err = os.Stat(appleDouble); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf(
+			"Test (TestWithXattrsExec) -> Expected '%s' to be renamed, but got: %v",
+			appleDouble,
+			err,
+		)
+	}
+
+	b, err := os.ReadFile(filepath.Join(testDir, "images", "._photo.arw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "xattrs" {
+		t.Fatalf(
+			"Test (TestWithXattrsExec) -> Expected the AppleDouble file to be kept, but got: %q",
+			b,
+		)
+	}
+}
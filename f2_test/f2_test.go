@@ -3,7 +3,10 @@ package f2_test
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
@@ -15,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,16 +26,20 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	shellquote "github.com/kballard/go-shellquote"
+	"github.com/pterm/pterm"
 	"github.com/sebdah/goldie/v2"
 	"golang.org/x/exp/slices"
 
 	"github.com/ayoisaiah/f2/internal/file"
 	internaljson "github.com/ayoisaiah/f2/internal/json"
 	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/validate"
 
 	"github.com/ayoisaiah/f2"
+	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/conflict"
 	internalos "github.com/ayoisaiah/f2/internal/os"
+	"github.com/ayoisaiah/f2/internal/ratelimit"
 )
 
 func init() {
@@ -156,9 +164,13 @@ func setupFileSystem(tb testing.TB, testName string) string {
 }
 
 func executeTest(args []string) ([]byte, error) {
+	return executeTestWithReader(args, os.Stdin)
+}
+
+func executeTestWithReader(args []string, reader io.Reader) ([]byte, error) {
 	var buf bytes.Buffer
 
-	app := f2.GetApp(os.Stdin, &buf)
+	app := f2.GetApp(reader, &buf)
 
 	err := app.Run(args)
 	if err != nil {
@@ -174,7 +186,7 @@ func sortChanges(s []*file.Change) {
 	})
 }
 
-func parseArgs(t *testing.T, name, args string) []string {
+func parseArgs(t testing.TB, name, args string) []string {
 	t.Helper()
 
 	result := make([]string, len(os.Args))
@@ -215,6 +227,7 @@ type TestCase struct {
 	Conflicts   conflict.Collection `json:"conflicts"`
 	DefaultOpts string              `json:"default_opts"`
 	GoldenFile  string              `json:"golden_file"`
+	Error       string              `json:"error"` // part of the expected error message
 	Setup       []string            `json:"setup"`
 }
 
@@ -442,9 +455,25 @@ func runTestCases(t *testing.T, cases []TestCase) {
 			argsSlice := preTestSetup(t, &tc)
 
 			result, err := executeTest(argsSlice)
+			if tc.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.Error) {
+					t.Fatalf(
+						"Test (%s) -> Expected an error that contains: %s, but got: %v",
+						tc.Name,
+						tc.Error,
+						err,
+					)
+				}
+
+				return
+			}
+
 			if err != nil {
+				noMatchesExpected := errors.Is(err, f2.ErrNoMatches) &&
+					len(tc.Changes) == 0
+
 				if len(tc.Conflicts) == 0 &&
-					tc.GoldenFile == "" {
+					tc.GoldenFile == "" && !noMatchesExpected {
 					t.Log(string(result))
 					t.Fatal(err)
 				}
@@ -507,6 +536,7 @@ func assertJSON(t *testing.T, tc *TestCase, result []byte) {
 		tc.Changes,
 		output.Changes,
 		cmpopts.IgnoreUnexported(file.Change{}),
+		cmpopts.IgnoreFields(file.Change{}, "Fixes", "ModTime", "Applied"),
 	) &&
 		len(tc.Changes) != 0 {
 		t.Fatalf(
@@ -536,3 +566,4470 @@ func TestShortHelp(t *testing.T) {
 	)
 	g.Assert(t, "help", []byte(help))
 }
+
+func TestNoMatches(t *testing.T) {
+	testDir := setupFileSystem(t, "TestNoMatches")
+
+	args := parseArgs(
+		t,
+		"TestNoMatches",
+		"-f nothing-matches-this --json "+testDir,
+	)
+
+	result, err := executeTest(args)
+	if !errors.Is(err, f2.ErrNoMatches) {
+		t.Fatalf(
+			"Test (TestNoMatches) -> Expected error: %v, but got: %v\n",
+			f2.ErrNoMatches,
+			err,
+		)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !output.NoMatches || len(output.Changes) != 0 {
+		t.Fatalf(
+			"Test (TestNoMatches) -> Expected a no matches indicator, but got: %s\n",
+			string(result),
+		)
+	}
+}
+
+func TestMatchCount(t *testing.T) {
+	testDir := setupFileSystem(t, "TestMatchCount")
+
+	args := parseArgs(t, "TestMatchCount", "-f pdf --count -R --json "+testDir)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.CountOutput
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		filepath.Join(testDir, "ebooks"): 2,
+	}
+
+	if output.Count != 2 || !cmp.Equal(want, output.Directories) {
+		t.Fatalf(
+			"Test (TestMatchCount) -> Expected 2 matches in %v, but got: %d in %v\n",
+			want,
+			output.Count,
+			output.Directories,
+		)
+	}
+}
+
+func TestWorkingDirRenameConflict(t *testing.T) {
+	testDir := setupFileSystem(t, "TestWorkingDirRenameConflict")
+
+	// images becomes the working directory so that renaming it
+	// (through the parent directory argument) must be prevented
+	err := os.Chdir(filepath.Join(testDir, "images"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestWorkingDirRenameConflict",
+		"-f images -r pictures -d --json . ..",
+	)
+
+	result, err := executeTest(args)
+	if err == nil {
+		t.Fatal("expected renaming the working directory to be prevented")
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := conflict.Collection{
+		conflict.WorkingDirRename: []conflict.Conflict{
+			{
+				Sources: []string{filepath.Join("..", "images")},
+				Target:  filepath.Join("..", "pictures"),
+			},
+		},
+	}
+
+	if !cmp.Equal(want, output.Conflicts) {
+		t.Fatalf(
+			"Test (TestWorkingDirRenameConflict) -> Expected: %+v, got: %+v\n",
+			want,
+			output.Conflicts,
+		)
+	}
+}
+
+func TestBackupFileOutput(t *testing.T) {
+	testDir := setupFileSystem(t, "TestBackupFileOutput")
+
+	args := parseArgs(
+		t,
+		"TestBackupFileOutput",
+		"-f pdf -r epub -x --json "+filepath.Join(testDir, "ebooks"),
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output.BackupFile == "" {
+		t.Fatal("Test (TestBackupFileOutput) -> Expected backup file to be set")
+	}
+
+	t.Cleanup(func() {
+		_ = os.Remove(output.BackupFile)
+	})
+
+	if _, err := os.Stat(output.BackupFile); err != nil {
+		t.Fatalf(
+			"Test (TestBackupFileOutput) -> Expected backup file to exist at: %s, but got: %v\n",
+			output.BackupFile,
+			err,
+		)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	testDir := setupFileSystem(t, "TestManifest")
+
+	manifest := filepath.Join(testDir, "manifest.jsonl")
+
+	runs := []string{
+		"-f pdf -r epub -x --json --manifest '%s' '%s'",
+		"-f mobi -r azw3 -x --json --manifest '%s' '%s'",
+	}
+
+	for _, run := range runs {
+		args := parseArgs(
+			t,
+			"TestManifest",
+			fmt.Sprintf(run, manifest, filepath.Join(testDir, "ebooks")),
+		)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			_ = os.Remove(output.BackupFile)
+		})
+	}
+
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ebooks/1984.pdf|ebooks/1984.epub",
+		"ebooks/atomic-habits.pdf|ebooks/atomic-habits.epub",
+		"ebooks/green-mile_1996.mobi|ebooks/green-mile_1996.azw3",
+	}
+
+	var got []string
+
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var entry struct {
+			Date    string `json:"date"`
+			Command string `json:"command"`
+			Source  string `json:"source"`
+			Target  string `json:"target"`
+		}
+
+		err = json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.Date == "" || entry.Command == "" {
+			t.Fatalf(
+				"Test (TestManifest) -> Expected date and command to be recorded, but got: %s\n",
+				line,
+			)
+		}
+
+		source, _ := filepath.Rel(testDir, entry.Source)
+		target, _ := filepath.Rel(testDir, entry.Target)
+
+		got = append(
+			got,
+			filepath.ToSlash(source)+"|"+filepath.ToSlash(target),
+		)
+	}
+
+	sort.Strings(got)
+
+	if !cmp.Equal(want, got) {
+		t.Fatalf(
+			"Test (TestManifest) -> Expected: %v, but got: %v\n",
+			want,
+			got,
+		)
+	}
+}
+
+func TestOverwritePolicy(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name       string
+		policy     string
+		sourceTime time.Time
+		targetTime time.Time
+		sourceSize int
+		targetSize int
+		want       status.Status
+	}{
+		{
+			name:       "overwrite older target",
+			policy:     "newer",
+			sourceTime: now,
+			targetTime: now.Add(-time.Hour),
+			want:       status.Overwriting,
+		},
+		{
+			name:       "skip newer target",
+			policy:     "newer",
+			sourceTime: now.Add(-time.Hour),
+			targetTime: now,
+			want:       status.Unchanged,
+		},
+		{
+			name:       "overwrite smaller target",
+			policy:     "larger",
+			sourceSize: 10,
+			targetSize: 5,
+			want:       status.Overwriting,
+		},
+		{
+			name:       "skip larger target",
+			policy:     "larger",
+			sourceSize: 5,
+			targetSize: 10,
+			want:       status.Unchanged,
+		},
+		{
+			name:       "always overwrite target",
+			policy:     "always",
+			sourceTime: now.Add(-time.Hour),
+			targetTime: now,
+			want:       status.Overwriting,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestOverwritePolicy")
+
+			source := filepath.Join(testDir, "images", "dsc-001.arw")
+			target := filepath.Join(testDir, "images", "dsc-002.arw")
+
+			for path, size := range map[string]int{
+				source: tc.sourceSize,
+				target: tc.targetSize,
+			} {
+				err := os.WriteFile(path, make([]byte, size), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !tc.sourceTime.IsZero() {
+				for path, mtime := range map[string]time.Time{
+					source: tc.sourceTime,
+					target: tc.targetTime,
+				} {
+					err := os.Chtimes(path, mtime, mtime)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			args := parseArgs(
+				t,
+				tc.name,
+				fmt.Sprintf(
+					"-f 001 -r 002 --overwrite-if %s --json '%s'",
+					tc.policy,
+					filepath.Join(testDir, "images"),
+				),
+			)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var o internaljson.Output
+
+			err = json.Unmarshal(result, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(o.Changes) != 1 || o.Changes[0].Status != tc.want {
+				t.Fatalf(
+					"Test (%s) — Expected status: %s, got: %s",
+					tc.name,
+					tc.want,
+					prettyPrint(o.Changes),
+				)
+			}
+		})
+	}
+}
+
+func TestRestoreTimes(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		restore bool
+	}{
+		{
+			name:    "restore modification times on undo",
+			args:    "-u -x --restore-times",
+			restore: true,
+		},
+		{
+			name: "keep modification times on undo",
+			args: "-u -x",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestRestoreTimes")
+
+			source := filepath.Join(testDir, "ebooks", "1984.pdf")
+			target := filepath.Join(testDir, "ebooks", "1985.pdf")
+
+			original := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+			err := os.Chtimes(source, original, original)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := parseArgs(t, tc.name, "-f 1984 -r 1985 -x ebooks")
+
+			_, err = executeTest(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// simulate a modification after the renaming operation
+			modified := time.Now()
+
+			err = os.Chtimes(target, modified, modified)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args = parseArgs(t, tc.name, tc.args)
+
+			_, err = executeTest(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fileInfo, err := os.Stat(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := modified
+			if tc.restore {
+				want = original
+			}
+
+			if fileInfo.ModTime().Unix() != want.Unix() {
+				t.Fatalf(
+					"Test (%s) -> Expected modification time: %v, but got: %v\n",
+					tc.name,
+					want,
+					fileInfo.ModTime(),
+				)
+			}
+		})
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	testDir := setupFileSystem(t, "TestFailFast")
+
+	// Renaming into a path whose parent is an existing file fails so only
+	// atomic-habits.pdf is renamed before the operation is stopped
+	args := parseArgs(
+		t,
+		"TestFailFast",
+		"-f '^(at)|^(an|19)' -r '${1}green-mile_1996.mobi/${2}' -x --fail-fast --json "+
+			filepath.Join(testDir, "ebooks"),
+	)
+
+	result, err := executeTest(args)
+	if err == nil {
+		t.Fatal("Test (TestFailFast) -> Expected the renaming operation to fail")
+	}
+
+	var output struct {
+		BackupFile string `json:"backup_file"`
+		Changes    []struct {
+			Status status.Status `json:"status"`
+			Source string        `json:"source"`
+		} `json:"changes"`
+		Errors []int `json:"errors"`
+	}
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(output.Errors) != 1 {
+		t.Fatalf(
+			"Test (TestFailFast) -> Expected only one error, but got: %v\n",
+			output.Errors,
+		)
+	}
+
+	for _, ch := range output.Changes {
+		if ch.Source == "1984.pdf" && ch.Status != status.Skipped {
+			t.Fatalf(
+				"Test (TestFailFast) -> Expected %s to be skipped, but got: %s\n",
+				ch.Source,
+				ch.Status,
+			)
+		}
+	}
+
+	t.Cleanup(func() {
+		_ = os.Remove(output.BackupFile)
+	})
+
+	b, err := os.ReadFile(output.BackupFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backup internaljson.Output
+
+	err = json.Unmarshal(b, &backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backup.Changes) != 1 ||
+		backup.Changes[0].Source != "atomic-habits.pdf" {
+		t.Fatalf(
+			"Test (TestFailFast) -> Expected only atomic-habits.pdf to be backed up, but got: %s\n",
+			prettyPrint(backup.Changes),
+		)
+	}
+}
+
+func TestAutoFixReasons(t *testing.T) {
+	testDir := setupFileSystem(t, "TestAutoFixReasons")
+
+	cases := []struct {
+		args string
+		want map[string][]file.Fix
+	}{
+		{
+			args: "-f 001 -r 002 -F --json",
+			want: map[string][]file.Fix{
+				"dsc-001.arw": {
+					{
+						Target: "dsc-002.arw",
+						Reason: "appended a number since the path already exists",
+					},
+				},
+			},
+		},
+		{
+			args: "-f '.*' -F --json",
+			want: map[string][]file.Fix{
+				"dsc-001.arw": {
+					{Target: ".", Reason: "empty file name: left unchanged"},
+				},
+				"dsc-002.arw": {
+					{Target: ".", Reason: "empty file name: left unchanged"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		args := parseArgs(
+			t,
+			"TestAutoFixReasons",
+			tc.args+" "+filepath.Join(testDir, "images"),
+		)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string][]file.Fix)
+
+		for _, ch := range output.Changes {
+			if len(ch.Fixes) > 0 {
+				got[ch.Source] = ch.Fixes
+			}
+		}
+
+		if !cmp.Equal(tc.want, got) {
+			t.Fatalf(
+				"Test (TestAutoFixReasons) -> Expected fixes to be: %s, but got: %s\n",
+				prettyPrint(tc.want),
+				prettyPrint(got),
+			)
+		}
+	}
+}
+
+func TestStdinNames(t *testing.T) {
+	testDir := setupFileSystem(t, "TestStdinNames")
+
+	cases := []struct {
+		name  string
+		input string
+		want  []string
+		err   bool
+	}{
+		{
+			name:  "assign names read from stdin",
+			input: "1.txt\n2.txt\n3.txt\n",
+			want: []string{
+				"music/Overgrown (2013)/01 Overgrown.flac|1.txt",
+				"music/Overgrown (2013)/02 I Am Sold.flac|2.txt",
+				"music/Overgrown (2013)/Cover.jpg|3.txt",
+			},
+		},
+		{
+			name:  "fewer names than matches",
+			input: "1.txt\n2.txt\n",
+			err:   true,
+		},
+		{
+			name:  "more names than matches",
+			input: "1.txt\n2.txt\n3.txt\n4.txt\n",
+			err:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			args := parseArgs(
+				t,
+				tc.name,
+				fmt.Sprintf(
+					"--stdin-names -R --json '%s'",
+					filepath.Join(testDir, "music"),
+				),
+			)
+
+			result, err := executeTestWithReader(
+				args,
+				strings.NewReader(tc.input),
+			)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("Test (%s) — Expected an error but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var o internaljson.Output
+
+			err = json.Unmarshal(result, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, len(o.Changes))
+			for i, ch := range o.Changes {
+				got[i] = fmt.Sprintf(
+					"%s|%s",
+					filepath.ToSlash(
+						filepath.Join(
+							strings.TrimPrefix(ch.BaseDir, testDir+string(os.PathSeparator)),
+							ch.Source,
+						),
+					),
+					ch.Target,
+				)
+			}
+
+			sort.Strings(got)
+
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf("Test (%s) — Expected: %v, got: %v", tc.name, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == internalos.Windows {
+		t.Skip("the editor is simulated with a shell script")
+	}
+
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+		err    bool
+	}{
+		{
+			name:   "rename with the edited names",
+			script: `sed -i.bak -e 's/Cover.jpg/cover.jpg/' -e 's/01 /1. /' "$1" && rm -f "$1.bak"`,
+			want: []string{
+				"music/Overgrown (2013)/01 Overgrown.flac|1. Overgrown.flac",
+				"music/Overgrown (2013)/02 I Am Sold.flac|02 I Am Sold.flac",
+				"music/Overgrown (2013)/Cover.jpg|cover.jpg",
+			},
+		},
+		{
+			name:   "skip files whose lines are deleted",
+			script: `sed -i.bak -e '/Overgrown.flac/d' -e 's/Cover.jpg/cover.jpg/' "$1" && rm -f "$1.bak"`,
+			want: []string{
+				"music/Overgrown (2013)/02 I Am Sold.flac|02 I Am Sold.flac",
+				"music/Overgrown (2013)/Cover.jpg|cover.jpg",
+			},
+		},
+		{
+			name:   "editor exits with an error",
+			script: `exit 1`,
+			err:    true,
+		},
+		{
+			name:   "empty file aborts the operation",
+			script: `: > "$1"`,
+			err:    true,
+		},
+		{
+			name:   "line without a match number",
+			script: `echo "cover.jpg" > "$1"`,
+			err:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestEdit")
+
+			editor := filepath.Join(testDir, "editor.sh")
+
+			err := os.WriteFile(
+				editor,
+				[]byte("#!/bin/sh\n"+tc.script+"\n"),
+				0o755,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Setenv("VISUAL", "")
+			t.Setenv("EDITOR", editor)
+
+			args := parseArgs(
+				t,
+				tc.name,
+				fmt.Sprintf(
+					"--edit -R --json '%s'",
+					filepath.Join(testDir, "music"),
+				),
+			)
+
+			result, err := executeTest(args)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("Test (%s) — Expected an error but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var o internaljson.Output
+
+			err = json.Unmarshal(result, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, len(o.Changes))
+			for i, ch := range o.Changes {
+				got[i] = fmt.Sprintf(
+					"%s|%s",
+					filepath.ToSlash(
+						filepath.Join(
+							strings.TrimPrefix(ch.BaseDir, testDir+string(os.PathSeparator)),
+							ch.Source,
+						),
+					),
+					ch.Target,
+				)
+			}
+
+			sort.Strings(got)
+
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf("Test (%s) — Expected: %v, got: %v", tc.name, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOrderFile(t *testing.T) {
+	testDir := setupFileSystem(t, "TestOrderFile")
+
+	musicDir := filepath.Join(testDir, "music", "Overgrown (2013)")
+
+	cases := []struct {
+		name    string
+		order   string
+		pathArg string // defaults to the absolute path of the music directory
+		want    []string
+	}{
+		{
+			name:  "order by file names",
+			order: "Cover.jpg\n02 I Am Sold.flac\n",
+			want: []string{
+				"Cover.jpg|01-Cover.jpg",
+				"02 I Am Sold.flac|02-02 I Am Sold.flac",
+				"01 Overgrown.flac|03-01 Overgrown.flac",
+			},
+		},
+		{
+			name:  "order by paths relative to the order file",
+			order: "\nmusic/Overgrown (2013)/02 I Am Sold.flac\nCover.jpg\n",
+			want: []string{
+				"02 I Am Sold.flac|01-02 I Am Sold.flac",
+				"Cover.jpg|02-Cover.jpg",
+				"01 Overgrown.flac|03-01 Overgrown.flac",
+			},
+		},
+		{
+			name:    "order by paths with a relative path argument",
+			order:   "music/Overgrown (2013)/Cover.jpg\nmusic/Overgrown (2013)/02 I Am Sold.flac\n",
+			pathArg: filepath.Join("music", "Overgrown (2013)"),
+			want: []string{
+				"Cover.jpg|01-Cover.jpg",
+				"02 I Am Sold.flac|02-02 I Am Sold.flac",
+				"01 Overgrown.flac|03-01 Overgrown.flac",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			orderFile := filepath.Join(testDir, "order.txt")
+
+			err := os.WriteFile(orderFile, []byte(tc.order), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pathArg := musicDir
+			if tc.pathArg != "" {
+				pathArg = tc.pathArg
+			}
+
+			args := parseArgs(
+				t,
+				tc.name,
+				fmt.Sprintf(
+					"-f '^' -r '{%%02d}-' --order-file '%s' --json '%s'",
+					orderFile,
+					pathArg,
+				),
+			)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var o internaljson.Output
+
+			err = json.Unmarshal(result, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, len(o.Changes))
+			for i, ch := range o.Changes {
+				got[i] = ch.Source + "|" + ch.Target
+			}
+
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf("Test (%s) — Expected: %v, got: %v", tc.name, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	testDir := setupFileSystem(t, "TestExplain")
+
+	ebooks := filepath.Join(testDir, "ebooks")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := os.Stderr
+	os.Stderr = w
+
+	t.Cleanup(func() {
+		os.Stderr = stderr
+	})
+
+	args := parseArgs(
+		t,
+		"TestExplain",
+		fmt.Sprintf(
+			"-f pdf -r epub -E atomic --ext pdf,mobi --explain --json '%s'",
+			ebooks,
+		),
+	)
+
+	_, err = executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	os.Stderr = stderr
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		fmt.Sprintf(
+			"Skipped '%s': directories are not included (use -d/--include-dir)",
+			filepath.Join(ebooks, ".banned"),
+		),
+		fmt.Sprintf(
+			"Skipped '%s': matches the exclude pattern 'atomic'",
+			filepath.Join(ebooks, "atomic-habits.pdf"),
+		),
+		fmt.Sprintf(
+			"Skipped '%s': does not match the find pattern 'pdf'",
+			filepath.Join(ebooks, "green-mile_1996.mobi"),
+		),
+		fmt.Sprintf(
+			"Skipped '%s': extension is not one of: pdf, mobi",
+			filepath.Join(ebooks, "animal-farm.epub"),
+		),
+	}
+
+	for _, v := range want {
+		if !strings.Contains(string(b), v) {
+			t.Fatalf(
+				"Test (TestExplain) -> Expected explanation: %s\nGot:\n%s",
+				v,
+				string(b),
+			)
+		}
+	}
+
+	// Matched files should not be explained
+	if strings.Contains(string(b), "1984.pdf") {
+		t.Fatalf(
+			"Test (TestExplain) -> Unexpected explanation for a match:\n%s",
+			string(b),
+		)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	testDir := setupFileSystem(t, "TestSummary")
+
+	images := filepath.Join(testDir, "images")
+
+	args := parseArgs(
+		t,
+		"TestSummary",
+		"-f 'dsc-' -r 'raw/new/dsc-' -x '"+images+"'",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Renamed 2 paths, skipped 0, failed 0, created 2 directories in "
+	if !strings.Contains(string(result), want) {
+		t.Fatalf(
+			"Test (TestSummary) -> Expected output to contain: %s\nGot:\n%s",
+			want,
+			string(result),
+		)
+	}
+
+	testDir = setupFileSystem(t, "TestSummary")
+
+	args = parseArgs(
+		t,
+		"TestSummary",
+		"-f 'dsc-00(1|3)' -r 'image' -R -x --json '"+filepath.Join(
+			testDir,
+			"images",
+		)+"'",
+	)
+
+	result, err = executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output.Stats == nil || output.Stats.Renamed != 2 ||
+		output.Stats.Failed != 0 || output.Stats.DirectoriesCreated != 0 ||
+		output.Stats.Elapsed == "" {
+		t.Fatalf(
+			"Test (TestSummary) -> Unexpected stats in the JSON output: %+v",
+			output.Stats,
+		)
+	}
+}
+
+func TestUndoFile(t *testing.T) {
+	testDir := setupFileSystem(t, "TestUndoFile")
+
+	source := filepath.Join(testDir, "ebooks", "1984.pdf")
+	target := filepath.Join(testDir, "ebooks", "1985.pdf")
+
+	args := parseArgs(t, "TestUndoFile", "-f 1984 -r 1985 -x --json ebooks")
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the backup file out of its usual location so that
+	// it cannot be found through the working directory
+	undoFile := filepath.Join(t.TempDir(), "backup.json")
+
+	err = os.Rename(output.BackupFile, undoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args = parseArgs(t, "TestUndoFile", "--undo-file '"+undoFile+"' -x")
+
+	_, err = executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(source); err != nil {
+		t.Fatalf("Test (TestUndoFile) -> Expected %s to be restored", source)
+	}
+
+	if _, err = os.Stat(target); err == nil {
+		t.Fatalf("Test (TestUndoFile) -> Expected %s to be reverted", target)
+	}
+
+	if _, err = os.Stat(undoFile); err != nil {
+		t.Fatalf(
+			"Test (TestUndoFile) -> Expected the undo file to be kept: %v",
+			err,
+		)
+	}
+
+	invalidFile := filepath.Join(t.TempDir(), "invalid.json")
+
+	err = os.WriteFile(invalidFile, []byte("not json"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		invalidFile,
+		filepath.Join(t.TempDir(), "missing.json"),
+	} {
+		args = parseArgs(t, "TestUndoFile", "--undo-file '"+path+"' -x")
+
+		_, err = executeTest(args)
+		if err == nil || !strings.Contains(err.Error(), path) {
+			t.Fatalf(
+				"Test (TestUndoFile) -> Expected an error for %s, but got: %v",
+				path,
+				err,
+			)
+		}
+	}
+}
+
+func TestUndoDryRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     string
+		existing string
+		conflict bool
+	}{
+		{
+			name: "undo without exec previews the reversal",
+			args: "-u --json",
+		},
+		{
+			name: "undo dry run takes precedence over exec",
+			args: "--undo-dry-run -x --json",
+		},
+		{
+			name:     "undo reports a conflict with a recreated source",
+			args:     "-u --json",
+			existing: "dsc-001.arw",
+			conflict: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestUndoDryRun")
+			imagesDir := filepath.Join(testDir, "images")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			args := parseArgs(t, tc.name, "-f dsc -r photo -x --json images")
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.existing != "" {
+				err = os.WriteFile(
+					filepath.Join(imagesDir, tc.existing),
+					nil,
+					0o600,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			before, err := os.ReadDir(imagesDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args = parseArgs(t, tc.name, tc.args)
+
+			result, err = executeTest(args)
+			if tc.conflict {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error", tc.name)
+				}
+			} else if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var undoOutput internaljson.Output
+
+			err = json.Unmarshal(result, &undoOutput)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.conflict {
+				if len(undoOutput.Conflicts[conflict.FileExists]) == 0 {
+					t.Fatalf(
+						"Test (%s) -> Expected a %s conflict, but got: %v",
+						tc.name,
+						conflict.FileExists,
+						undoOutput.Conflicts,
+					)
+				}
+			} else {
+				if !undoOutput.DryRun || len(undoOutput.Changes) != 2 {
+					t.Fatalf(
+						"Test (%s) -> Expected a dry run of 2 changes, but got: %s",
+						tc.name,
+						string(result),
+					)
+				}
+
+				for _, ch := range undoOutput.Changes {
+					if !strings.HasPrefix(ch.Source, "photo") ||
+						!strings.HasPrefix(ch.Target, "dsc") {
+						t.Fatalf(
+							"Test (%s) -> Expected the source and target to be swapped, but got: %s -> %s",
+							tc.name,
+							ch.Source,
+							ch.Target,
+						)
+					}
+
+					if ch.Applied {
+						t.Fatalf(
+							"Test (%s) -> Expected %s not to be reported as applied",
+							tc.name,
+							ch.Source,
+						)
+					}
+				}
+			}
+
+			after, err := os.ReadDir(imagesDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(before) != len(after) {
+				t.Fatalf("Test (%s) -> Expected the filesystem to be unchanged", tc.name)
+			}
+
+			for i := range before {
+				if before[i].Name() != after[i].Name() {
+					t.Fatalf(
+						"Test (%s) -> Expected the filesystem to be unchanged, but %s became %s",
+						tc.name,
+						before[i].Name(),
+						after[i].Name(),
+					)
+				}
+			}
+
+			if _, err = os.Stat(output.BackupFile); err != nil {
+				t.Fatalf(
+					"Test (%s) -> Expected the backup file to be kept: %v",
+					tc.name,
+					err,
+				)
+			}
+		})
+	}
+}
+
+func TestHTMLOutput(t *testing.T) {
+	cases := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{
+			name: "render the changes as html",
+			args: "-f dsc -r photo --output-format html images",
+			want: []string{
+				"<h2>Changes</h2>",
+				"photo-001.arw</td>",
+				`<td class="ok">ok</td>`,
+			},
+		},
+		{
+			name: "render the conflicts as html",
+			args: "-f 001 -r 002 --output-format html images",
+			want: []string{
+				"<h2>Conflicts</h2>",
+				`<td class="error">path already exists</td>`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestHTMLOutput")
+
+		args := parseArgs(t, tc.name, tc.args)
+
+		result, _ := executeTest(args)
+
+		out := string(result)
+
+		if !strings.HasPrefix(out, "<!DOCTYPE html>") {
+			t.Fatalf(
+				"Test (%s) -> Expected an HTML document, but got:\n%s",
+				tc.name,
+				out,
+			)
+		}
+
+		want := append(tc.want, "Working directory: <code>"+testDir+"</code>")
+
+		for _, v := range want {
+			if !strings.Contains(out, v) {
+				t.Fatalf(
+					"Test (%s) -> Expected output to contain: %s\nGot:\n%s",
+					tc.name,
+					v,
+					out,
+				)
+			}
+		}
+
+		// Ensure that every element is properly closed
+		decoder := xml.NewDecoder(strings.NewReader(out))
+		decoder.Entity = xml.HTMLEntity
+
+		for {
+			_, err := decoder.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) -> Invalid HTML: %v\n%s", tc.name, err, out)
+			}
+		}
+	}
+}
+
+func TestTreeOutput(t *testing.T) {
+	testDir := setupFileSystem(t, "TestTreeOutput")
+
+	args := parseArgs(
+		t,
+		"TestTreeOutput",
+		"-f 'dsc|1984' -r photo -R --output-format tree --no-color images ebooks",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		testDir,
+		"├── ebooks",
+		"│   └── 1984.pdf → photo.pdf ok",
+		"└── images",
+		"    ├── dsc-001.arw → photo-001.arw ok",
+		"    ├── dsc-002.arw → photo-002.arw ok",
+		"    └── sony",
+		"        └── dsc-003.arw → photo-003.arw ok",
+	}, "\n") + "\n"
+
+	if out := string(result); !strings.HasPrefix(out, want) {
+		t.Fatalf(
+			"Test (TestTreeOutput) -> Expected the tree:\n%s\nGot:\n%s",
+			want,
+			out,
+		)
+	}
+}
+
+func TestReverseGraphemes(t *testing.T) {
+	// The accent is a combining character and the emoji has a skin tone
+	// modifier so reversing the code points would detach both
+	source := "cafe\u0301 \U0001F44D\U0001F3FD.txt"
+	want := "\U0001F44D\U0001F3FD e\u0301fac.txt"
+
+	testDir := setupFileSystem(t, "TestReverseGraphemes")
+
+	notes := filepath.Join(testDir, "notes")
+
+	err := os.Mkdir(notes, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(notes, source), nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestReverseGraphemes",
+		"-f '.*' -r '{{f.reverse}}{{ext}}' --json "+notes,
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(output.Changes) != 1 || output.Changes[0].Target != want {
+		t.Fatalf(
+			"Test (TestReverseGraphemes) -> Expected target: %q, but got: %s",
+			want,
+			string(result),
+		)
+	}
+}
+
+func TestStatVariables(t *testing.T) {
+	fixtures := map[string][]byte{
+		"report.txt": []byte("one two three\nfour five\nsix\n"),
+		"todo.md":    []byte("alpha beta"),
+		"blob.bin":   {0x00, 0x01, '\n', 0xff},
+	}
+
+	cases := []struct {
+		want    map[string]string
+		name    string
+		args    string
+		wantErr bool
+	}{
+		{
+			name: "lines and words are counted in text files",
+			args: "",
+			want: map[string]string{
+				"report.txt": "report-3-6.txt",
+				"todo.md":    "todo-1-2.md",
+				"blob.bin":   "blob--.bin",
+			},
+		},
+		{
+			name: "files larger than the cap resolve to an empty string",
+			args: "--stat-max-bytes 10",
+			want: map[string]string{
+				"report.txt": "report--.txt",
+				"todo.md":    "todo-1-2.md",
+				"blob.bin":   "blob--.bin",
+			},
+		},
+		{
+			name:    "the cap must be a positive integer",
+			args:    "--stat-max-bytes 0",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestStatVariables")
+
+		notes := filepath.Join(testDir, "notes")
+
+		err := os.Mkdir(notes, os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range fixtures {
+			err = os.WriteFile(filepath.Join(notes, name), content, 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		args := parseArgs(
+			t,
+			"TestStatVariables",
+			"-f '(.*)\\.(.*)' -r '$1-{{stat.lines}}-{{stat.words}}.$2' --json "+
+				tc.args+" "+notes,
+		)
+
+		result, err := executeTest(args)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf(
+					"Test (%s) — Expected an error but got nil",
+					tc.name,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, ch := range output.Changes {
+			got[ch.Source] = ch.Target
+		}
+
+		if !cmp.Equal(tc.want, got) {
+			t.Fatalf(
+				"Test (%s) -> Expected targets to be: %s, but got: %s\n",
+				tc.name,
+				prettyPrint(tc.want),
+				prettyPrint(got),
+			)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	cases := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "a depth limit of zero is reported",
+			args: "-f dsc -r photo -R --max-depth 0 --json images",
+			want: "--max-depth 0 limits the search to the current level",
+		},
+		{
+			name: "failing to record the manifest is reported",
+			args: "-f 1984 -r nineteen -x --json --manifest missing/manifest.jsonl ebooks",
+			want: "Failed to record renaming operation in the manifest",
+		},
+		{
+			name: "a chain that does not change any name is reported",
+			args: "-f dsc -r img -f img -r dsc --json images",
+			want: "the find and replace chain does not change any of the matched names",
+		},
+		{
+			name: "a chain that changes some of the names is not reported",
+			args: "-f dsc -r img -f img-001 -r dsc-001 --json images",
+		},
+		{
+			name: "no warnings are reported",
+			args: "-f dsc -r photo --json images",
+		},
+	}
+
+	for _, tc := range cases {
+		setupFileSystem(t, "TestWarnings")
+
+		args := parseArgs(t, "TestWarnings", tc.args)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tc.want == "" {
+			if len(output.Warnings) != 0 {
+				t.Fatalf(
+					"Test (%s) -> Expected no warnings, but got: %v",
+					tc.name,
+					output.Warnings,
+				)
+			}
+
+			continue
+		}
+
+		if len(output.Warnings) != 1 ||
+			!strings.HasPrefix(output.Warnings[0], tc.want) {
+			t.Fatalf(
+				"Test (%s) -> Expected a warning that starts with: %s, but got: %v",
+				tc.name,
+				tc.want,
+				output.Warnings,
+			)
+		}
+	}
+}
+
+func TestSafe(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		env     string
+		wantErr bool
+	}{
+		{
+			name:    "exec is refused with the safe flag",
+			args:    "-f dsc -r photo -x --safe",
+			wantErr: true,
+		},
+		{
+			name:    "exec is refused with the environmental variable",
+			args:    "-f dsc -r photo -x",
+			env:     "true",
+			wantErr: true,
+		},
+		{
+			name: "a dry run is allowed with the safe flag",
+			args: "-f dsc -r photo --safe",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(f2.EnvForbidExec, tc.env)
+			}
+
+			testDir := setupFileSystem(t, "TestSafe")
+
+			args := parseArgs(
+				t,
+				"TestSafe",
+				tc.args+" "+filepath.Join(testDir, "images"),
+			)
+
+			_, err := executeTest(args)
+			if tc.wantErr && err == nil {
+				t.Fatalf("Test (%s) — Expected an error but got nil", tc.name)
+			}
+
+			if !tc.wantErr && err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			_, err = os.Stat(filepath.Join(testDir, "images", "dsc-001.arw"))
+			if err != nil {
+				t.Fatalf(
+					"Test (%s) — Expected the filesystem to be unchanged: %v",
+					tc.name,
+					err,
+				)
+			}
+		})
+	}
+}
+
+func TestConfirmOverwrites(t *testing.T) {
+	cases := []struct {
+		name        string
+		args        string
+		input       string
+		defaultOpts string
+		wantErr     bool
+	}{
+		{
+			name:  "overwriting is confirmed at the prompt",
+			args:  "-f 001 -r 002 --allow-overwrites -x",
+			input: "yes\n",
+		},
+		{
+			name:    "overwriting is not confirmed at the prompt",
+			args:    "-f 001 -r 002 --allow-overwrites -x",
+			input:   "no\n",
+			wantErr: true,
+		},
+		{
+			name: "the prompt is bypassed with yes",
+			args: "-f 001 -r 002 --allow-overwrites -x --yes",
+		},
+		{
+			name:    "overwriting requires yes in JSON mode",
+			args:    "-f 001 -r 002 --allow-overwrites -x --json",
+			input:   "yes\n",
+			wantErr: true,
+		},
+		{
+			name: "overwriting is allowed with yes in JSON mode",
+			args: "-f 001 -r 002 --allow-overwrites -x --json -y",
+		},
+		{
+			name:        "yes is ignored in the default options",
+			args:        "-f 001 -r 002 --allow-overwrites -x --json",
+			defaultOpts: "--yes",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestConfirmOverwrites")
+
+		t.Setenv(f2.EnvDefaultOpts, tc.defaultOpts)
+
+		images := filepath.Join(testDir, "images")
+
+		args := parseArgs(t, "TestConfirmOverwrites", tc.args+" "+images)
+
+		_, err := executeTestWithReader(args, strings.NewReader(tc.input))
+		if tc.wantErr && err == nil {
+			t.Fatalf("Test (%s) — Expected an error but got nil", tc.name)
+		}
+
+		if !tc.wantErr && err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		// The source is only renamed if overwriting was confirmed
+		_, err = os.Stat(filepath.Join(images, "dsc-001.arw"))
+		if tc.wantErr != (err == nil) {
+			t.Fatalf(
+				"Test (%s) — Unexpected state of the source file: %v",
+				tc.name,
+				err,
+			)
+		}
+	}
+}
+
+func TestExtRules(t *testing.T) {
+	rules := `{
+	"rules": [
+		{"extensions": ["arw"], "find": "dsc", "replace": "photo"},
+		{"extensions": [".PDF"], "replace": "{{f.up}}{{ext}}"}
+	]
+}`
+
+	cases := []struct {
+		want    map[string]string
+		name    string
+		args    string
+		config  string
+		env     bool
+		wantErr bool
+	}{
+		{
+			name:   "files are renamed according to their extension",
+			args:   "--json images ebooks",
+			config: rules,
+			want: map[string]string{
+				"dsc-001.arw":       "photo-001.arw",
+				"dsc-002.arw":       "photo-002.arw",
+				"1984.pdf":          "1984.pdf",
+				"atomic-habits.pdf": "ATOMIC-HABITS.pdf",
+			},
+		},
+		{
+			name:   "the config file can be set through the environment",
+			args:   "--json images",
+			config: rules,
+			env:    true,
+			want: map[string]string{
+				"dsc-001.arw": "photo-001.arw",
+				"dsc-002.arw": "photo-002.arw",
+			},
+		},
+		{
+			name:   "the command line overrides the extension rules",
+			args:   "-f dsc -r img --json images",
+			config: rules,
+			want: map[string]string{
+				"dsc-001.arw": "img-001.arw",
+				"dsc-002.arw": "img-002.arw",
+			},
+		},
+		{
+			name:    "each rule must specify an extension",
+			args:    "--json images",
+			config:  `{"rules": [{"replace": "photo"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestExtRules")
+
+		configFile := filepath.Join(testDir, "config.json")
+
+		err := os.WriteFile(configFile, []byte(tc.config), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		args := tc.args
+		if tc.env {
+			t.Setenv(f2.EnvConfig, configFile)
+		} else {
+			args = "--config " + configFile + " " + args
+		}
+
+		result, err := executeTest(parseArgs(t, "TestExtRules", args))
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf(
+					"Test (%s) — Expected an error but got nil",
+					tc.name,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, ch := range output.Changes {
+			got[ch.Source] = ch.Target
+		}
+
+		if !cmp.Equal(tc.want, got) {
+			t.Fatalf(
+				"Test (%s) -> Expected targets to be: %s, but got: %s\n",
+				tc.name,
+				prettyPrint(tc.want),
+				prettyPrint(got),
+			)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	pipelines := `{
+	"pipelines": {
+		"normalize-media": [
+			{"find": "\\s*\\(\\d{4}\\)", "replace": ""},
+			{"find": "\\.1080p", "replace": ""},
+			{"replace": "{{.up}}"}
+		],
+		"broken": [
+			{"find": "green", "replace": "red"},
+			{"find": "(", "replace": ""}
+		]
+	}
+}`
+
+	cases := []struct {
+		want    map[string]string
+		name    string
+		args    string
+		config  string
+		wantErr string
+	}{
+		{
+			// Like a chain of find and replace pairs, the matches are the
+			// paths that match the first step
+			name:   "the steps of the pipeline are applied in order",
+			args:   "--pipeline normalize-media -e --json movies",
+			config: pipelines,
+			want: map[string]string{
+				"No Pressure (2021) S1.E1.1080p.mkv": "NO PRESSURE S1.E1.mkv",
+				"No Pressure (2021) S1.E2.1080p.mkv": "NO PRESSURE S1.E2.mkv",
+				"No Pressure (2021) S1.E3.1080p.mkv": "NO PRESSURE S1.E3.mkv",
+			},
+		},
+		{
+			name:    "every step is compiled before any file is matched",
+			args:    "--pipeline broken --json movies",
+			config:  pipelines,
+			wantErr: "Invalid pipeline pattern #2",
+		},
+		{
+			name:    "the pipeline must be defined in the config file",
+			args:    "--pipeline unknown --json movies",
+			config:  pipelines,
+			wantErr: "is not defined",
+		},
+		{
+			name:    "a pipeline cannot be combined with find and replace",
+			args:    "--pipeline normalize-media -f green -r red --json movies",
+			config:  pipelines,
+			wantErr: "cannot be combined",
+		},
+		{
+			name:    "each pipeline must have a step",
+			args:    "--pipeline empty --json movies",
+			config:  `{"pipelines": {"empty": []}}`,
+			wantErr: "must have at least one step",
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestPipeline")
+
+		configFile := filepath.Join(testDir, "config.json")
+
+		err := os.WriteFile(configFile, []byte(tc.config), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		args := "--config " + configFile + " " + tc.args
+
+		result, err := executeTest(parseArgs(t, "TestPipeline", args))
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf(
+					"Test (%s) -> Expected an error that contains: %s, but got: %v",
+					tc.name,
+					tc.wantErr,
+					err,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, ch := range output.Changes {
+			got[ch.Source] = ch.Target
+		}
+
+		if !cmp.Equal(tc.want, got) {
+			t.Fatalf(
+				"Test (%s) -> Expected targets to be: %s, but got: %s\n",
+				tc.name,
+				prettyPrint(tc.want),
+				prettyPrint(got),
+			)
+		}
+	}
+}
+
+func TestDirConfig(t *testing.T) {
+	globalConfig := `{
+	"rules": [
+		{"extensions": ["arw"], "find": "dsc", "replace": "global"},
+		{"extensions": ["pdf"], "replace": "{{f.up}}{{ext}}"}
+	]
+}`
+
+	dirConfig := `{
+	"rules": [
+		{"extensions": ["arw"], "find": "dsc", "replace": "local"}
+	]
+}`
+
+	cases := []struct {
+		want      map[string]string
+		name      string
+		args      string
+		global    string
+		dirConfig string
+		wantErr   string
+	}{
+		{
+			name:      "the directory config file is loaded automatically",
+			args:      "--json images",
+			dirConfig: dirConfig,
+			want: map[string]string{
+				"dsc-001.arw": "local-001.arw",
+				"dsc-002.arw": "local-002.arw",
+			},
+		},
+		{
+			name:      "the directory config file takes precedence over the global one",
+			args:      "--json images ebooks",
+			global:    globalConfig,
+			dirConfig: dirConfig,
+			want: map[string]string{
+				"dsc-001.arw":       "local-001.arw",
+				"dsc-002.arw":       "local-002.arw",
+				"1984.pdf":          "1984.pdf",
+				"atomic-habits.pdf": "ATOMIC-HABITS.pdf",
+			},
+		},
+		{
+			name:      "the command line takes precedence over the directory config file",
+			args:      "-f dsc -r img --json images",
+			dirConfig: dirConfig,
+			want: map[string]string{
+				"dsc-001.arw": "img-001.arw",
+				"dsc-002.arw": "img-002.arw",
+			},
+		},
+		{
+			name:      "the location of a syntax error is reported",
+			args:      "--json images",
+			dirConfig: "{\n\t\"rules\": [\n\t\t{\"extensions\": [\"arw\"],}\n\t]\n}",
+			wantErr:   ".f2.json' is invalid: line 3, column 26",
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestDirConfig")
+
+		err := os.WriteFile(
+			filepath.Join(testDir, ".f2.json"),
+			[]byte(tc.dirConfig),
+			0o600,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		args := tc.args
+
+		if tc.global != "" {
+			configFile := filepath.Join(testDir, "config.json")
+
+			err = os.WriteFile(configFile, []byte(tc.global), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args = "--config " + configFile + " " + args
+		}
+
+		result, err := executeTest(parseArgs(t, "TestDirConfig", args))
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf(
+					"Test (%s) -> Expected an error that contains: %s, but got: %v",
+					tc.name,
+					tc.wantErr,
+					err,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, ch := range output.Changes {
+			got[ch.Source] = ch.Target
+		}
+
+		if !cmp.Equal(tc.want, got) {
+			t.Fatalf(
+				"Test (%s) -> Expected targets to be: %s, but got: %s\n",
+				tc.name,
+				prettyPrint(tc.want),
+				prettyPrint(got),
+			)
+		}
+	}
+}
+
+func TestFindDuplicateNames(t *testing.T) {
+	testDir := setupFileSystem(t, "TestFindDuplicateNames")
+
+	err := os.WriteFile(
+		filepath.Join(testDir, "images", "sony", "dsc-001.arw"),
+		nil,
+		0o600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestFindDuplicateNames",
+		"--find-duplicate-names -R --json images",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.DuplicatesOutput
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"dsc-001.arw": {
+			filepath.Join("images", "dsc-001.arw"),
+			filepath.Join("images", "sony", "dsc-001.arw"),
+		},
+	}
+
+	if !cmp.Equal(want, output.Duplicates) {
+		t.Fatalf(
+			"Test (TestFindDuplicateNames) -> Expected duplicates to be: %s, but got: %s\n",
+			prettyPrint(want),
+			prettyPrint(output.Duplicates),
+		)
+	}
+
+	args = parseArgs(
+		t,
+		"TestFindDuplicateNames",
+		"--find-duplicate-names -R images",
+	)
+
+	result, err = executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(result), "Duplicate names: 1") {
+		t.Fatalf(
+			"Test (TestFindDuplicateNames) -> Unexpected output:\n%s",
+			string(result),
+		)
+	}
+}
+
+func TestUnwritableBackupDir(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     string
+		renamed  bool
+		wantErr  string
+		wantWarn string
+	}{
+		{
+			name:    "the operation is aborted before any file is renamed",
+			args:    "-f 1984 -r nineteen -x ebooks",
+			wantErr: "Use --backup-fallback",
+		},
+		{
+			name:     "the backup file is written to the temporary directory",
+			args:     "-f 1984 -r nineteen -x --backup-fallback --json ebooks",
+			renamed:  true,
+			wantWarn: "The backup directory is not writable",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestUnwritableBackupDir")
+
+			// The data directories are nested under a regular file so that
+			// they cannot be created regardless of the user's permissions
+			blocker := filepath.Join(testDir, "blocker")
+
+			err := os.WriteFile(blocker, nil, 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(blocker, "data"))
+			t.Setenv("XDG_DATA_DIRS", filepath.Join(blocker, "share"))
+			xdg.Reload()
+
+			args := parseArgs(t, "TestUnwritableBackupDir", tc.args)
+
+			result, err := executeTest(args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf(
+						"Test (%s) -> Expected an error that contains: %s, but got: %v",
+						tc.name,
+						tc.wantErr,
+						err,
+					)
+				}
+			} else if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			_, err = os.Stat(filepath.Join(testDir, "ebooks", "nineteen.pdf"))
+			if renamed := err == nil; renamed != tc.renamed {
+				t.Fatalf(
+					"Test (%s) -> Expected renamed to be: %t, but got: %t",
+					tc.name,
+					tc.renamed,
+					renamed,
+				)
+			}
+
+			if tc.wantWarn == "" {
+				return
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(output.Warnings) != 1 ||
+				!strings.HasPrefix(output.Warnings[0], tc.wantWarn) {
+				t.Fatalf(
+					"Test (%s) -> Expected a warning that starts with: %s, but got: %v",
+					tc.name,
+					tc.wantWarn,
+					output.Warnings,
+				)
+			}
+		})
+	}
+}
+
+func TestTwoPhaseRename(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		setup   string // a directory that is created in the way of the target
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "case only changes are renamed through temporary names",
+			args: "-f dsc -r DSC -x images",
+			want: []string{"DSC-001.arw", "DSC-002.arw", "canon", "sony"},
+		},
+		{
+			name:    "the source is restored if the second phase fails",
+			args:    "-f dsc-001 -r DSC-001 -x --allow-overwrites --yes images",
+			setup:   filepath.Join("images", "DSC-001.arw", "file.txt"),
+			want:    []string{"DSC-001.arw", "canon", "dsc-001.arw", "dsc-002.arw", "sony"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestTwoPhaseRename")
+
+			if tc.setup != "" {
+				setupPath := filepath.Join(testDir, tc.setup)
+
+				err := os.MkdirAll(filepath.Dir(setupPath), os.ModePerm)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.WriteFile(setupPath, nil, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := parseArgs(t, "TestTwoPhaseRename", tc.args)
+
+			_, err := executeTest(args)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			entries, err := os.ReadDir(filepath.Join(testDir, "images"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+
+			// No temporary names should be left behind
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf(
+					"Test (%s) -> Expected the images directory to contain: %s, but got: %s",
+					tc.name,
+					prettyPrint(tc.want),
+					prettyPrint(got),
+				)
+			}
+		})
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	cases := []struct {
+		name        string
+		args        string
+		wantSources []string
+		wantExec    bool
+		noMatches   bool
+	}{
+		{
+			name:        "each change is printed on a separate line",
+			args:        "-f dsc -r photo --json-stream images",
+			wantSources: []string{"dsc-001.arw", "dsc-002.arw"},
+		},
+		{
+			name:        "changes are streamed after they are committed",
+			args:        "-f dsc -r photo --json-stream -x images",
+			wantSources: []string{"dsc-001.arw", "dsc-002.arw"},
+			wantExec:    true,
+		},
+		{
+			name:      "only the summary is printed if there are no matches",
+			args:      "-f no-such-file -r photo --json-stream images",
+			noMatches: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupFileSystem(t, "TestJSONStream")
+
+			args := parseArgs(t, "TestJSONStream", tc.args)
+
+			result, err := executeTest(args)
+			if err != nil && !(tc.noMatches && errors.Is(err, f2.ErrNoMatches)) {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+
+			var sources []string
+
+			for _, line := range lines[:len(lines)-1] {
+				var change internaljson.StreamChange
+
+				err = json.Unmarshal([]byte(line), &change)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if change.Type != internaljson.StreamTypeChange {
+					t.Fatalf(
+						"Test (%s) -> Expected a change but got: %s",
+						tc.name,
+						line,
+					)
+				}
+
+				sources = append(sources, change.Source)
+			}
+
+			var summary internaljson.StreamSummary
+
+			err = json.Unmarshal([]byte(lines[len(lines)-1]), &summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Type != internaljson.StreamTypeSummary ||
+				summary.Count != len(tc.wantSources) ||
+				summary.DryRun == tc.wantExec ||
+				summary.NoMatches != tc.noMatches ||
+				(summary.Stats != nil) != tc.wantExec {
+				t.Fatalf(
+					"Test (%s) -> Unexpected summary: %s",
+					tc.name,
+					lines[len(lines)-1],
+				)
+			}
+
+			sort.Strings(sources)
+
+			if !cmp.Equal(tc.wantSources, sources, cmpopts.EquateEmpty()) {
+				t.Fatalf(
+					"Test (%s) -> Expected sources %v, but got: %v",
+					tc.name,
+					tc.wantSources,
+					sources,
+				)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	cases := []struct {
+		name    string
+		setup   string // a file that is created after the plan is saved
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "the saved plan is applied",
+			want: []string{"canon", "photo-001.arw", "photo-002.arw", "sony"},
+		},
+		{
+			name:    "conflicts are checked again before the plan is applied",
+			setup:   "photo-001.arw",
+			want:    []string{"canon", "dsc-001.arw", "dsc-002.arw", "photo-001.arw", "sony"},
+			wantErr: "conflict",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestPlan")
+			imagesDir := filepath.Join(testDir, "images")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			args := parseArgs(t, "TestPlan", "-f dsc -r photo --save-plan --json images")
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output.PlanFile == "" {
+				t.Fatalf("Test (%s) -> Expected the plan file to be reported", tc.name)
+			}
+
+			if tc.setup != "" {
+				err = os.WriteFile(filepath.Join(imagesDir, tc.setup), nil, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args = parseArgs(t, "TestPlan", "--apply-plan "+output.PlanFile)
+
+			_, err = executeTest(args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf(
+						"Test (%s) -> Expected an error that contains: %s, but got: %v",
+						tc.name,
+						tc.wantErr,
+						err,
+					)
+				}
+			} else if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			entries, err := os.ReadDir(imagesDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf(
+					"Test (%s) -> Expected entries %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+
+			if tc.wantErr != "" {
+				return
+			}
+
+			// The plan cannot be applied again once its sources are renamed
+			_, err = executeTest(args)
+			if err == nil || !strings.Contains(err.Error(), "out of date") {
+				t.Fatalf(
+					"Test (%s) -> Expected an out of date plan error, but got: %v",
+					tc.name,
+					err,
+				)
+			}
+		})
+	}
+}
+
+func TestSetMtimeFrom(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     string
+		source   string
+		target   string
+		wantTime time.Time
+		wantWarn string
+	}{
+		{
+			name:     "the modification time is set to the date in the new name",
+			args:     "-f dsc-001 -r IMG_20220115 --set-mtime-from '(\\d{8}):20060102' -x --json images",
+			target:   filepath.Join("images", "IMG_20220115.arw"),
+			wantTime: time.Date(2022, 1, 15, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "the whole match is parsed if there is no capture group",
+			args:     "-f dsc-001 -r '2021-12-31 23.59' --set-mtime-from '\\d{4}-\\d{2}-\\d{2} \\d{2}\\.\\d{2}:2006-01-02 15.04' -x --json images",
+			target:   filepath.Join("images", "2021-12-31 23.59.arw"),
+			wantTime: time.Date(2021, 12, 31, 23, 59, 0, 0, time.Local),
+		},
+		{
+			name:     "files whose date cannot be parsed are left untouched",
+			args:     "-f dsc-001 -r IMG_20221345 --set-mtime-from '(\\d{8}):20060102' -x --json images",
+			target:   filepath.Join("images", "IMG_20221345.arw"),
+			wantWarn: "could not be parsed as a date",
+		},
+		{
+			name:     "files whose name has no date are left untouched",
+			args:     "-f dsc-001 -r photo --set-mtime-from '(\\d{8}):20060102' -x --json images",
+			target:   filepath.Join("images", "photo.arw"),
+			wantWarn: "does not match",
+		},
+		{
+			name:   "files that are not renamed are left untouched",
+			args:   "-f 'No Pressure|green-mile' -r Pressure --replace-if '^No' --set-mtime-from '(\\d{4}):2006' -x --json movies",
+			source: filepath.Join("movies", "green-mile_1999.mp4"),
+			target: filepath.Join("movies", "green-mile_1999.mp4"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestSetMtimeFrom")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			source := tc.source
+			if source == "" {
+				source = filepath.Join("images", "dsc-001.arw")
+			}
+
+			sourceInfo, err := os.Stat(filepath.Join(testDir, source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := parseArgs(t, "TestSetMtimeFrom", tc.args)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			info, err := os.Stat(filepath.Join(testDir, tc.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantTime := tc.wantTime
+			if wantTime.IsZero() {
+				wantTime = sourceInfo.ModTime()
+			}
+
+			if !info.ModTime().Equal(wantTime) {
+				t.Fatalf(
+					"Test (%s) -> Expected modification time: %v, but got: %v",
+					tc.name,
+					wantTime,
+					info.ModTime(),
+				)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.wantWarn == "" && len(output.Warnings) > 0 ||
+				tc.wantWarn != "" && (len(output.Warnings) != 1 ||
+					!strings.Contains(output.Warnings[0], tc.wantWarn)) {
+				t.Fatalf(
+					"Test (%s) -> Expected a warning that contains: %q, but got: %v",
+					tc.name,
+					tc.wantWarn,
+					output.Warnings,
+				)
+			}
+		})
+	}
+}
+
+func TestSinceLastRun(t *testing.T) {
+	cases := []struct {
+		name    string
+		lastRun bool
+		want    []string
+	}{
+		{
+			name: "every file is matched without a prior run",
+			want: []string{"dsc-001.arw", "dsc-002.arw"},
+		},
+		{
+			name:    "only the files modified since the prior run are matched",
+			lastRun: true,
+			want:    []string{"dsc-002.arw"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestSinceLastRun")
+			imagesDir := filepath.Join(testDir, "images")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			lastRun := time.Date(2021, time.March, 14, 10, 0, 0, 0, time.UTC)
+
+			if tc.lastRun {
+				args := parseArgs(t, tc.name, "-f 1984 -r 1985 -x --json ebooks")
+
+				result, err := executeTest(args)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var output internaljson.Output
+
+				err = json.Unmarshal(result, &output)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// simulate a renaming operation at an earlier date
+				output.Date = lastRun.Format(time.RFC3339)
+
+				b, err := json.Marshal(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.WriteFile(output.BackupFile, b, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			modTimes := map[string]time.Time{
+				"dsc-001.arw": lastRun.Add(-time.Hour),
+				"dsc-002.arw": lastRun.Add(time.Hour),
+			}
+
+			for name, modTime := range modTimes {
+				err := os.Chtimes(
+					filepath.Join(imagesDir, name),
+					modTime,
+					modTime,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := parseArgs(
+				t,
+				tc.name,
+				"-f dsc -r photo --since-last-run --json images",
+			)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(output.Changes))
+			for _, ch := range output.Changes {
+				got = append(got, ch.Source)
+			}
+
+			sort.Strings(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected matches: %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestNormalizeSeparators(t *testing.T) {
+	cases := []struct {
+		name      string
+		files     []string
+		args      string
+		want      []string
+		conflicts bool
+		wantErr   bool
+	}{
+		{
+			name: "runs of mixed separators are collapsed",
+			files: []string{
+				"my file-name_v1.txt",
+				"another__file  name.txt",
+				"tidy-name.txt",
+			},
+			args: "--normalize-separators-to -",
+			want: []string{
+				"another__file  name.txt|another-file-name.txt|ok",
+				"my file-name_v1.txt|my-file-name-v1.txt|ok",
+				"tidy-name.txt|tidy-name.txt|unchanged",
+			},
+		},
+		{
+			name:  "separators are normalized to spaces",
+			files: []string{"a_b-c.txt"},
+			args:  "--normalize-separators-to ' '",
+			want:  []string{"a_b-c.txt|a b c.txt|ok"},
+		},
+		{
+			name:      "names that become identical are reported as conflicts",
+			files:     []string{"a b.txt", "a_b.txt"},
+			args:      "--normalize-separators-to -",
+			conflicts: true,
+		},
+		{
+			name:    "the separator must be a single character",
+			files:   []string{"a b.txt"},
+			args:    "--normalize-separators-to __",
+			wantErr: true,
+		},
+		{
+			name:    "the separator cannot be combined with a find pattern",
+			files:   []string{"a b.txt"},
+			args:    "--normalize-separators-to - -f a",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestNormalizeSeparators")
+
+			notes := filepath.Join(testDir, "notes")
+
+			err := os.Mkdir(notes, os.ModePerm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tc.files {
+				err = os.WriteFile(filepath.Join(notes, name), nil, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := parseArgs(t, tc.name, tc.args+" --json notes")
+
+			result, err := executeTest(args)
+			if tc.wantErr || tc.conflicts {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error but got nil", tc.name)
+				}
+			} else if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			if tc.wantErr {
+				return
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.conflicts {
+				if len(output.Conflicts[conflict.OverwritingNewPath]) == 0 {
+					t.Fatalf(
+						"Test (%s) -> Expected a %s conflict, but got: %v",
+						tc.name,
+						conflict.OverwritingNewPath,
+						output.Conflicts,
+					)
+				}
+
+				return
+			}
+
+			got := make([]string, 0, len(output.Changes))
+			for _, ch := range output.Changes {
+				got = append(
+					got,
+					ch.Source+"|"+ch.Target+"|"+string(ch.Status),
+				)
+			}
+
+			sort.Strings(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected changes: %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	longName := strings.Repeat("é", 128) + ".txt"
+
+	cases := []struct {
+		name string
+		goos string
+		want []validate.ValidationIssue
+	}{
+		{name: "photo.jpg", goos: "linux"},
+		{name: "photo.jpg", goos: internalos.Windows},
+		{name: "a:b.jpg", goos: "linux"},
+		{
+			name: "a:b.jpg",
+			goos: internalos.Darwin,
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.InvalidCharacters, Cause: ":"},
+			},
+		},
+		{
+			name: "a<b>.jpg",
+			goos: internalos.Windows,
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.InvalidCharacters, Cause: "<,>"},
+			},
+		},
+		{name: "CON.txt", goos: "linux"},
+		{
+			name: "CON.txt",
+			goos: internalos.Windows,
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.ReservedName, Cause: "CON.txt"},
+			},
+		},
+		{
+			name: `docs\nul`,
+			goos: internalos.Windows,
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.ReservedName, Cause: "nul"},
+			},
+		},
+		{name: "docs./report.txt", goos: "linux"},
+		{
+			name: "docs./report.txt",
+			goos: internalos.Windows,
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.TrailingPeriod},
+			},
+		},
+		{
+			// 132 characters but 260 bytes
+			name: longName,
+			goos: "linux",
+			want: []validate.ValidationIssue{
+				{
+					Conflict: conflict.MaxFilenameLengthExceeded,
+					Cause:    "255 bytes",
+				},
+			},
+		},
+		{name: longName, goos: internalos.Windows},
+		{
+			name: "",
+			goos: "linux",
+			want: []validate.ValidationIssue{
+				{Conflict: conflict.EmptyFilename},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got := validate.ValidateNameForOS(tc.name, tc.goos)
+
+		want := tc.want
+		if want == nil {
+			want = []validate.ValidationIssue{}
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf(
+				"Test (%s on %s) -> Expected issues: %v, but got: %v",
+				tc.name,
+				tc.goos,
+				want,
+				got,
+			)
+		}
+	}
+}
+
+func TestTargetOS(t *testing.T) {
+	cases := []struct {
+		name     string
+		goos     string
+		args     string
+		conflict conflict.Name
+		want     string
+	}{
+		{
+			name:     "windows forbidden characters are detected on any platform",
+			goos:     internalos.Windows,
+			args:     "-f dsc-001 -r 'a<b>'",
+			conflict: conflict.InvalidCharacters,
+		},
+		{
+			name: "windows forbidden characters are removed on any platform",
+			goos: internalos.Windows,
+			args: "-f dsc-001 -r 'a<b>' -F",
+			want: "ab.arw",
+		},
+		{
+			name:     "windows trailing periods are detected on any platform",
+			goos:     internalos.Windows,
+			args:     "-f 'dsc-001.arw' -r 'photo.'",
+			conflict: conflict.TrailingPeriod,
+		},
+		{
+			name:     "macos forbidden characters are detected on any platform",
+			goos:     internalos.Darwin,
+			args:     "-f dsc-001 -r 'a:b'",
+			conflict: conflict.InvalidCharacters,
+		},
+		{
+			name: "linux allows the characters that are forbidden elsewhere",
+			goos: "linux",
+			args: "-f dsc-001 -r 'a<b>:c'",
+			want: "a<b>:c.arw",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			setupFileSystem(t, "TestTargetOS")
+
+			original := validate.TargetOS
+
+			t.Cleanup(func() {
+				validate.TargetOS = original
+			})
+
+			validate.TargetOS = tc.goos
+
+			args := parseArgs(t, tc.name, tc.args+" --json images")
+
+			result, err := executeTest(args)
+			if tc.conflict != "" && err == nil {
+				t.Fatalf("Test (%s) -> Expected a conflict, but got nil", tc.name)
+			}
+
+			if tc.conflict == "" && err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.conflict != "" {
+				if len(output.Conflicts[tc.conflict]) == 0 {
+					t.Fatalf(
+						"Test (%s) -> Expected a %s conflict, but got: %v",
+						tc.name,
+						tc.conflict,
+						output.Conflicts,
+					)
+				}
+
+				return
+			}
+
+			if len(output.Changes) != 1 ||
+				output.Changes[0].Target != tc.want {
+				t.Fatalf(
+					"Test (%s) -> Expected the target to be %s, but got: %s",
+					tc.name,
+					tc.want,
+					string(result),
+				)
+			}
+		})
+	}
+}
+
+func TestCounterFile(t *testing.T) {
+	testDir := setupFileSystem(t, "TestCounterFile")
+
+	t.Cleanup(xdg.Reload)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+	xdg.Reload()
+
+	counterFile := filepath.Join(testDir, "counter.txt")
+
+	cases := []struct {
+		name    string
+		args    string
+		want    []string
+		counter string
+	}{
+		{
+			name:    "the numbering starts from one without a counter file",
+			args:    "-r 'photo-{%03d}' --default-stem -x images",
+			want:    []string{"photo-001.arw", "photo-002.arw"},
+			counter: "2",
+		},
+		{
+			name: "the counter file is not updated in a dry run",
+			args: "-r 'book-{%03d}' --default-stem ebooks",
+			want: []string{
+				"book-003.pdf",
+				"book-004.epub",
+				"book-005.pdf",
+				"book-006.EPUB",
+				"book-007.mobi",
+			},
+			counter: "2",
+		},
+		{
+			name: "the numbering continues from the previous run",
+			args: "-r 'book-{%03d}' --default-stem -x ebooks",
+			want: []string{
+				"book-003.pdf",
+				"book-004.epub",
+				"book-005.pdf",
+				"book-006.EPUB",
+				"book-007.mobi",
+			},
+			counter: "7",
+		},
+		{
+			name:    "a higher starting number takes precedence",
+			args:    "-f 'photo-00' -r 'img-{20%03d}-' -x images",
+			want:    []string{"img-020-1.arw", "img-021-2.arw"},
+			counter: "21",
+		},
+	}
+
+	for _, tc := range cases {
+		args := parseArgs(
+			t,
+			tc.name,
+			"--json --counter-file "+counterFile+" "+tc.args,
+		)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, ch := range output.Changes {
+			got = append(got, ch.Target)
+		}
+
+		// The order of the changes in the output depends on the operation
+		slices.Sort(got)
+
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf(
+				"Test (%s) -> Expected targets to be %v, but got: %v",
+				tc.name,
+				tc.want,
+				got,
+			)
+		}
+
+		b, err := os.ReadFile(counterFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.TrimSpace(string(b)) != tc.counter {
+			t.Fatalf(
+				"Test (%s) -> Expected the counter to be %s, but got: %s",
+				tc.name,
+				tc.counter,
+				string(b),
+			)
+		}
+
+		if _, err := os.Stat(counterFile + ".lock"); err == nil {
+			t.Fatalf("Test (%s) -> Expected the counter file to be unlocked", tc.name)
+		}
+	}
+}
+
+func TestMoveIntoSourceDir(t *testing.T) {
+	testDir := setupFileSystem(t, "TestMoveIntoSourceDir")
+
+	t.Cleanup(xdg.Reload)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+	xdg.Reload()
+
+	args := parseArgs(
+		t,
+		"TestMoveIntoSourceDir",
+		"-f '^' -r '../images/' -x --json images ebooks",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ch := range output.Changes {
+		if ch.BaseDir != "images" {
+			continue
+		}
+
+		if ch.Status != status.Unchanged || ch.Target != ch.Source {
+			t.Fatalf(
+				"Test (TestMoveIntoSourceDir) -> Expected %s to be unchanged, but got: %s (%s)",
+				ch.Source,
+				ch.Target,
+				ch.Status,
+			)
+		}
+	}
+
+	for _, name := range []string{"dsc-001.arw", "dsc-002.arw", "1984.pdf"} {
+		_, err := os.Stat(filepath.Join(testDir, "images", name))
+		if err != nil {
+			t.Fatalf(
+				"Test (TestMoveIntoSourceDir) -> Expected %s to be in the images directory: %v",
+				name,
+				err,
+			)
+		}
+	}
+}
+
+func TestJSONOutputStreams(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		stderr  string
+		wantErr bool
+	}{
+		{
+			name:   "verbose output of a renaming operation",
+			args:   "-f 1984 -r 1985 -x -V --json ebooks",
+			stderr: "Renamed",
+		},
+		{
+			name:    "no matches",
+			args:    "-f xyz -r abc --json ebooks",
+			wantErr: true,
+		},
+		{
+			name:    "conflicts",
+			args:    "-f dsc-001 -r dsc-002 --json images",
+			wantErr: true,
+		},
+		{
+			name:    "conflicts in a stream",
+			args:    "-f dsc-001 -r dsc-002 --json-stream images",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestJSONOutputStreams")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			// Anything written to the default output of pterm bypasses
+			// the writer that the app is configured with
+			var stray bytes.Buffer
+
+			pterm.SetDefaultOutput(&stray)
+
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stderr := os.Stderr
+			os.Stderr = w
+
+			t.Cleanup(func() {
+				os.Stderr = stderr
+
+				pterm.SetDefaultOutput(os.Stdout)
+			})
+
+			args := parseArgs(t, tc.name, tc.args)
+
+			result, err := executeTest(args)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			w.Close()
+
+			os.Stderr = stderr
+
+			errOutput, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stray.Len() > 0 {
+				t.Fatalf(
+					"Test (%s) -> Expected nothing outside the configured writers, but got: %s",
+					tc.name,
+					stray.String(),
+				)
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(result))
+
+			for decoder.More() {
+				var v any
+
+				err = decoder.Decode(&v)
+				if err != nil {
+					t.Fatalf(
+						"Test (%s) -> Expected only JSON in the standard output, but got: %s",
+						tc.name,
+						string(result),
+					)
+				}
+			}
+
+			if !strings.Contains(string(errOutput), tc.stderr) {
+				t.Fatalf(
+					"Test (%s) -> Expected the standard error to contain %q, but got: %s",
+					tc.name,
+					tc.stderr,
+					string(errOutput),
+				)
+			}
+		})
+	}
+}
+
+func TestJSONOutputOnce(t *testing.T) {
+	cases := []struct {
+		name        string
+		args        string
+		defaultOpts string
+	}{
+		{
+			name: "a successful renaming operation",
+			args: "-f 1984 -r 1985 -x --json ebooks",
+		},
+		{
+			name:        "a successful renaming operation in simple mode",
+			args:        "1984 1985 ebooks",
+			defaultOpts: "--json",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestJSONOutputOnce")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			t.Setenv(f2.EnvDefaultOpts, tc.defaultOpts)
+
+			args := parseArgs(t, tc.name, tc.args)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			// Unmarshal fails if there is anything after the first document
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatalf(
+					"Test (%s) -> Expected a single JSON document, but got: %s",
+					tc.name,
+					string(result),
+				)
+			}
+
+			if output.DryRun || len(output.Changes) != 1 {
+				t.Fatalf(
+					"Test (%s) -> Expected the executed change, but got: %s",
+					tc.name,
+					string(result),
+				)
+			}
+
+			_, err = os.Stat(filepath.Join(testDir, "ebooks", "1985.pdf"))
+			if err != nil {
+				t.Fatalf("Test (%s) -> Expected 1984.pdf to be renamed: %v", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "only empty files are matched",
+			args: "--empty -f '^' -r 'x-' images",
+			want: []string{"dsc-002.arw"},
+		},
+		{
+			name: "only non-empty files are matched",
+			args: "--non-empty -f '^' -r 'x-' images",
+			want: []string{"dsc-001.arw"},
+		},
+		{
+			name: "directories without entries are empty",
+			args: "--empty -d -f '^' -r 'x-' images",
+			want: []string{"dsc-002.arw", "empty"},
+		},
+		{
+			name: "directories with entries are not empty",
+			args: "--non-empty -d -f '^' -r 'x-' images",
+			want: []string{"canon", "dsc-001.arw", "sony"},
+		},
+		{
+			name:    "the filters cannot be combined",
+			args:    "--empty --non-empty -f '^' -r 'x-' images",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestEmptyFilter")
+
+			err := os.WriteFile(
+				filepath.Join(testDir, "images", "dsc-001.arw"),
+				[]byte("raw"),
+				0o600,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = os.Mkdir(filepath.Join(testDir, "images", "empty"), 0o750)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := parseArgs(t, tc.name, "--json "+tc.args)
+
+			result, err := executeTest(args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error, but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ch := range output.Changes {
+				got = append(got, ch.Source)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the matches to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+// frozenClock is a clock that does not advance so that the time each
+// rate-limited operation waits for is its offset from the start.
+type frozenClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *frozenClock) Now() time.Time {
+	return c.now
+}
+
+func (c *frozenClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sleeps = append(c.sleeps, d)
+}
+
+func TestRateLimit(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       string
+		operations int // the number of rate-limited operations
+	}{
+		{
+			name:       "renames are limited",
+			args:       "--rate 4 -f '^' -r 'x-' -x ebooks",
+			operations: 5,
+		},
+		{
+			name:       "concurrent reads share the limit with the renames",
+			args:       "--rate 4 --workers 3 -f '^' -r '{{hash.md5}}-' -x ebooks",
+			operations: 10,
+		},
+		{
+			name: "nothing is limited without a rate",
+			args: "-f '^' -r 'x-' -x ebooks",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestRateLimit")
+
+			t.Cleanup(xdg.Reload)
+			t.Setenv("XDG_DATA_HOME", filepath.Join(testDir, "data"))
+			xdg.Reload()
+
+			clock := &frozenClock{
+				now: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}
+
+			now, sleep := ratelimit.Now, ratelimit.Sleep
+
+			t.Cleanup(func() {
+				ratelimit.Now, ratelimit.Sleep = now, sleep
+			})
+
+			ratelimit.Now, ratelimit.Sleep = clock.Now, clock.Sleep
+
+			args := parseArgs(t, tc.name, tc.args)
+
+			_, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			// At 4 operations per second, the first operation proceeds
+			// immediately and each of the others is a quarter of a second
+			// after the previous one
+			var want []time.Duration
+			for i := 1; i < tc.operations; i++ {
+				want = append(want, time.Duration(i)*time.Second/4)
+			}
+
+			slices.Sort(clock.sleeps)
+
+			if !slices.Equal(clock.sleeps, want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the operations to wait for %v, but got: %v",
+					tc.name,
+					want,
+					clock.sleeps,
+				)
+			}
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	testDir := setupFileSystem(t, "TestPrintConfig")
+
+	globalConfig := `{
+	"pipelines": {
+		"clean": [{"find": " ", "replace": "_"}],
+		"lower": [{"replace": "{{f.lw}}{{ext}}"}]
+	}
+}`
+
+	dirConfig := `{
+	"pipelines": {
+		"clean": [{"find": "-", "replace": "_"}]
+	}
+}`
+
+	configFile := filepath.Join(testDir, "config.json")
+
+	err := os.WriteFile(configFile, []byte(globalConfig), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(
+		filepath.Join(testDir, ".f2.json"),
+		[]byte(dirConfig),
+		0o600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(f2.EnvDefaultOpts, "--hidden --workers 2 --sort size")
+
+	args := parseArgs(
+		t,
+		"TestPrintConfig",
+		"--print-config --config "+configFile+" --workers 3 -f dsc -r img --replace-if '^dsc' --set-mtime-from '(\\d+):2006' -x images",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got config.Config
+
+	err = json.Unmarshal(result, &got)
+	if err != nil {
+		t.Fatalf(
+			"Test (TestPrintConfig) -> Expected the config in JSON format, but got: %s",
+			string(result),
+		)
+	}
+
+	want := config.Config{
+		IncludeHidden: true, // F2_DEFAULT_OPTS
+		Workers:       3,    // the command line over F2_DEFAULT_OPTS
+		Sort:          "size",
+		Exec:          true,
+		FindSlice:     []string{"dsc"},
+		ReplaceIf:     "^dsc", // compiled options report their source pattern
+		MtimePattern:  `(\d+)`,
+		MtimeLayout:   "2006",
+		Pipelines: map[string][]config.PipelineStep{
+			// the directory config file over the global one
+			"clean": {{Find: "-", Replace: "_"}},
+			"lower": {{Replace: "{{f.lw}}{{ext}}"}},
+		},
+	}
+
+	if got.IncludeHidden != want.IncludeHidden ||
+		got.Workers != want.Workers ||
+		got.Sort != want.Sort ||
+		got.Exec != want.Exec ||
+		!slices.Equal(got.FindSlice, want.FindSlice) ||
+		got.ReplaceIf != want.ReplaceIf ||
+		got.MtimePattern != want.MtimePattern ||
+		got.MtimeLayout != want.MtimeLayout ||
+		!cmp.Equal(got.Pipelines, want.Pipelines) {
+		t.Fatalf(
+			"Test (TestPrintConfig) -> Expected the effective config to match the layered options, but got: %s",
+			string(result),
+		)
+	}
+
+	// Nothing is renamed even though -x is set
+	_, err = os.Stat(filepath.Join(testDir, "images", "dsc-001.arw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRespectGitignore(t *testing.T) {
+	repo := map[string]string{
+		".git/info/exclude":     "secret.txt\n",
+		".gitignore":            "*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/draft.txt\n",
+		"a.txt":                 "",
+		"top.txt":               "",
+		"secret.txt":            "",
+		"debug.log":             "",
+		"keep.log":              "",
+		"build/out.txt":         "",
+		"build/nested/deep.txt": "",
+		"docs/draft.txt":        "",
+		"docs/a/b/draft.txt":    "",
+		"docs/a/guide.txt":      "",
+		"sub/.gitignore":        "*.md\n!readme.md\n",
+		"sub/notes.md":          "",
+		"sub/readme.md":         "",
+		"sub/top.txt":           "",
+		"sub/trace.log":         "",
+		"sub/deep/x.md":         "",
+	}
+
+	cases := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{
+			name: "ignored paths are skipped when searching recursively",
+			args: "--respect-gitignore -R repo",
+			want: []string{
+				"repo/a.txt",
+				"repo/docs/a/guide.txt",
+				"repo/keep.log",
+				"repo/sub/readme.md",
+				"repo/sub/top.txt",
+			},
+		},
+		{
+			name: "ignored directories are skipped",
+			args: "--respect-gitignore -d repo",
+			want: []string{
+				"repo/a.txt",
+				"repo/docs",
+				"repo/keep.log",
+				"repo/sub",
+			},
+		},
+		{
+			name: "the contents of ignored directories are skipped even if searched explicitly",
+			args: "--respect-gitignore -R repo/build repo/debug.log",
+			want: []string{
+				"repo/debug.log",
+			},
+		},
+		{
+			name: "ignored paths are matched if specified as arguments",
+			args: "--respect-gitignore repo/build/out.txt repo/build/nested/deep.txt",
+			want: []string{
+				"repo/build/nested/deep.txt",
+				"repo/build/out.txt",
+			},
+		},
+		{
+			name: "all paths are matched without the flag",
+			args: "-R repo/build repo/sub",
+			want: []string{
+				"repo/build/nested/deep.txt",
+				"repo/build/out.txt",
+				"repo/sub/deep/x.md",
+				"repo/sub/notes.md",
+				"repo/sub/readme.md",
+				"repo/sub/top.txt",
+				"repo/sub/trace.log",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestRespectGitignore")
+
+			for name, content := range repo {
+				path := filepath.Join(testDir, "repo", filepath.FromSlash(name))
+
+				err := os.MkdirAll(filepath.Dir(path), 0o750)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.WriteFile(path, []byte(content), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := parseArgs(t, tc.name, "--json -f '^' -r 'x-' "+tc.args)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ch := range output.Changes {
+				got = append(
+					got,
+					filepath.ToSlash(filepath.Join(ch.BaseDir, ch.Source)),
+				)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the matches to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestMapFile(t *testing.T) {
+	cases := []struct {
+		name         string
+		mappings     string
+		args         string
+		want         []string
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			name:     "only the listed files are renamed",
+			mappings: "dsc-001.arw=first.arw\n\n# comment\ndsc-003.arw = third.arw\n",
+			args:     "-R images",
+			want: []string{
+				"dsc-001.arw|first.arw",
+				"dsc-003.arw|third.arw",
+			},
+		},
+		{
+			name:     "the names that do not match any file are reported",
+			mappings: "dsc-001.arw=first.arw\nmissing.arw=none.arw\nstartrails1.jpg=sky.jpg\n",
+			args:     "images",
+			want: []string{
+				"dsc-001.arw|first.arw",
+			},
+			wantWarnings: []string{
+				"'missing.arw' in the map file does not match any file",
+				"'startrails1.jpg' in the map file does not match any file",
+			},
+		},
+		{
+			name:     "a line without a separator is rejected",
+			mappings: "dsc-001.arw=first.arw\ndsc-002.arw\n",
+			args:     "images",
+			wantErr:  true,
+		},
+		{
+			name:     "the map file cannot be combined with a find pattern",
+			mappings: "dsc-001.arw=first.arw\n",
+			args:     "-f dsc images",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestMapFile")
+
+			mapFile := filepath.Join(testDir, "map.txt")
+
+			err := os.WriteFile(mapFile, []byte(tc.mappings), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := parseArgs(
+				t,
+				tc.name,
+				"--json --map-file "+mapFile+" "+tc.args,
+			)
+
+			result, err := executeTest(args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error, but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ch := range output.Changes {
+				got = append(got, ch.Source+"|"+ch.Target)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the changes to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+
+			if !slices.Equal(output.Warnings, tc.wantWarnings) {
+				t.Fatalf(
+					"Test (%s) -> Expected the warnings to be %v, but got: %v",
+					tc.name,
+					tc.wantWarnings,
+					output.Warnings,
+				)
+			}
+		})
+	}
+}
+
+func TestAppliedStatus(t *testing.T) {
+	testDir := setupFileSystem(t, "TestAppliedStatus")
+
+	// The target directory of dsc-001.arw cannot be created
+	// since a file exists at its path
+	err := os.WriteFile(filepath.Join(testDir, "images", "photo-1"), nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestAppliedStatus",
+		`-f 'dsc-00(\d)' -r 'photo-$1/x' -x --json images`,
+	)
+
+	result, err := executeTest(args)
+	if err == nil {
+		t.Fatal("Test (TestAppliedStatus) -> Expected an error, but got nil")
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(output.Changes))
+	for _, ch := range output.Changes {
+		got = append(
+			got,
+			fmt.Sprintf("%s|%t|%t", ch.Source, ch.Applied, ch.ErrorMessage != ""),
+		)
+	}
+
+	slices.Sort(got)
+
+	want := []string{
+		"dsc-001.arw|false|true",
+		"dsc-002.arw|true|false",
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf(
+			"Test (TestAppliedStatus) -> Expected the changes to be %v, but got: %v",
+			want,
+			got,
+		)
+	}
+}
+
+func TestHashList(t *testing.T) {
+	const (
+		oneSHA256 = "7692c3ad3540bb803c020b3aee66cd8887123234ea0c6e7143c0add73ff431ed"
+		twoMD5    = "B8A9F715DBB64FD5C56E7783C6820A61"
+	)
+
+	cases := []struct {
+		name    string
+		flag    string
+		list    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "only the files whose hash is in the list are matched",
+			flag: "--hash-in",
+			list: oneSHA256 + "  images/dsc-001.arw\n",
+			args: "-f dsc -r img -R images",
+			want: []string{"dsc-001.arw|img-001.arw"},
+		},
+		{
+			name: "only the files whose hash is not in the list are matched",
+			flag: "--hash-not-in",
+			list: "# known files\n" + oneSHA256 + "\n\n" + twoMD5 + "\n",
+			args: "-f dsc -r img -R images",
+			want: []string{"dsc-003.arw|img-003.arw"},
+		},
+		{
+			name: "the hashes computed by the filter are used for the hash variables",
+			flag: "--hash-in",
+			list: oneSHA256 + "\n",
+			args: "-f dsc-001 -r {hash.sha256} images",
+			want: []string{"dsc-001.arw|" + oneSHA256 + ".arw"},
+		},
+		{
+			name:    "a line that does not start with a hash is rejected",
+			flag:    "--hash-in",
+			list:    oneSHA256 + "\nnot-a-hash\n",
+			args:    "-f dsc -r img images",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestHashList")
+
+			for name, content := range map[string]string{
+				"hashes.txt":         tc.list,
+				"images/dsc-001.arw": "one",
+				"images/dsc-002.arw": "two",
+			} {
+				err := os.WriteFile(
+					filepath.Join(testDir, name),
+					[]byte(content),
+					0o600,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := parseArgs(
+				t,
+				tc.name,
+				"--json "+tc.flag+" hashes.txt "+tc.args,
+			)
+
+			result, err := executeTest(args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error, but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ch := range output.Changes {
+				got = append(got, ch.Source+"|"+ch.Target)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the changes to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestWithXattrs(t *testing.T) {
+	cases := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{
+			name: "the AppleDouble file is renamed along with its file",
+			args: "--with-xattrs -f dsc -r img images",
+			want: []string{
+				"._dsc-001.arw|._img-001.arw",
+				"dsc-001.arw|img-001.arw",
+				"dsc-002.arw|img-002.arw",
+			},
+		},
+		{
+			name: "a matched AppleDouble file is not renamed twice",
+			args: "--with-xattrs -H -f dsc -r img images",
+			want: []string{
+				"._dsc-001.arw|._img-001.arw",
+				"dsc-001.arw|img-001.arw",
+				"dsc-002.arw|img-002.arw",
+			},
+		},
+		{
+			name: "the AppleDouble file is moved along with its file",
+			args: "--with-xattrs -f dsc-001 -r raw/photo images",
+			want: []string{
+				"._dsc-001.arw|raw/._photo.arw",
+				"dsc-001.arw|raw/photo.arw",
+			},
+		},
+		{
+			name: "the AppleDouble file follows the fixed target of its file",
+			args: "--with-xattrs -F -f dsc-001 -r dsc-002 images",
+			want: []string{
+				"._dsc-001.arw|._dsc-002 (2).arw",
+				"dsc-001.arw|dsc-002 (2).arw",
+			},
+		},
+		{
+			name: "the AppleDouble file is left alone without the flag",
+			args: "-f dsc -r img images",
+			want: []string{
+				"dsc-001.arw|img-001.arw",
+				"dsc-002.arw|img-002.arw",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestWithXattrs")
+
+			err := os.WriteFile(
+				filepath.Join(testDir, "images", "._dsc-001.arw"),
+				nil,
+				0o600,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := parseArgs(t, tc.name, "--json "+tc.args)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ch := range output.Changes {
+				got = append(got, ch.Source+"|"+filepath.ToSlash(ch.Target))
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the changes to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestShowIndex(t *testing.T) {
+	setupFileSystem(t, "TestShowIndex")
+
+	args := parseArgs(
+		t,
+		"TestShowIndex",
+		"--show-index -f dsc -r img --sortr name --no-color images",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []*regexp.Regexp{
+		regexp.MustCompile(`\|\s*INDEX\s*\|\s*ORIGINAL\s*\|`),
+		regexp.MustCompile(`\|\s*0\s*\|\s*images[/\\]dsc-002\.arw\s*\|`),
+		regexp.MustCompile(`\|\s*1\s*\|\s*images[/\\]dsc-001\.arw\s*\|`),
+	} {
+		if !want.Match(result) {
+			t.Fatalf(
+				"Test (TestShowIndex) -> Expected the table to match %s\nGot:\n%s",
+				want,
+				string(result),
+			)
+		}
+	}
+
+	args = parseArgs(
+		t,
+		"TestShowIndex",
+		"--show-index --json -f dsc -r img --sortr name images",
+	)
+
+	result, err = executeTest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(output.Changes))
+	for _, ch := range output.Changes {
+		if ch.ShownIndex == nil {
+			t.Fatalf(
+				"Test (TestShowIndex) -> Expected an index for '%s'",
+				ch.Source,
+			)
+		}
+
+		got = append(got, fmt.Sprintf("%d|%s", *ch.ShownIndex, ch.Source))
+	}
+
+	want := []string{"0|dsc-002.arw", "1|dsc-001.arw"}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf(
+			"Test (TestShowIndex) -> Expected the indexes to be %v, but got: %v",
+			want,
+			got,
+		)
+	}
+}
+
+func TestRandomVars(t *testing.T) {
+	setupFileSystem(t, "TestRandomVars")
+
+	cases := []struct {
+		replacement string
+		want        *regexp.Regexp
+	}{
+		{
+			replacement: "IMG_{{random.8}}{ext}",
+			want:        regexp.MustCompile(`^IMG_[a-z0-9]{8}\.(arw|jpg)$`),
+		},
+		{
+			replacement: "{random.3.d.up}{ext}",
+			want:        regexp.MustCompile(`^[0-9]{3}\.(arw|jpg)$`),
+		},
+		{
+			// five files get a different digit each
+			replacement: "{random.1.d}{ext}",
+			want:        regexp.MustCompile(`^[0-9]\.(arw|jpg)$`),
+		},
+		{
+			// a default value does not use up any random values
+			replacement: "{random.1.d|none}{ext}",
+			want:        regexp.MustCompile(`^[0-9]\.(arw|jpg)$`),
+		},
+	}
+
+	for _, tc := range cases {
+		args := parseArgs(
+			t,
+			"TestRandomVars",
+			fmt.Sprintf("--json -f '.*' -r '%s' -R images", tc.replacement),
+		)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]bool)
+
+		for _, ch := range output.Changes {
+			if !tc.want.MatchString(ch.Target) {
+				t.Fatalf(
+					"Test (TestRandomVars) -> Expected the target of '%s' to match %s, but got: %s",
+					ch.Source,
+					tc.want,
+					ch.Target,
+				)
+			}
+
+			value := strings.TrimSuffix(ch.Target, filepath.Ext(ch.Target))
+			if seen[value] {
+				t.Fatalf(
+					"Test (TestRandomVars) -> Expected the random values to be unique, but '%s' was generated twice for %s",
+					value,
+					tc.replacement,
+				)
+			}
+
+			seen[value] = true
+		}
+	}
+
+	// a single character cannot be unique across the five files
+	args := parseArgs(
+		t,
+		"TestRandomVars",
+		"-f '.*' -r '{1r<o>}{ext}' -R images",
+	)
+
+	_, err := executeTest(args)
+	if err == nil {
+		t.Fatal("Test (TestRandomVars) — Expected an error but got nil")
+	}
+}
+
+func TestProtectedPaths(t *testing.T) {
+	testDir := setupFileSystem(t, "TestProtectedPaths")
+
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	protected := []string{"/", "/usr", "/etc", home}
+	if runtime.GOOS == internalos.Windows {
+		protected = []string{`C:\`, `C:\Windows`, `C:\Program Files`, home}
+	}
+
+	for _, path := range protected {
+		args := parseArgs(
+			t,
+			"TestProtectedPaths",
+			fmt.Sprintf("-f '^' -r _ '%s'", path),
+		)
+
+		_, err := executeTest(args)
+		if err == nil || !strings.Contains(err.Error(), "--force") {
+			t.Fatalf(
+				"Test (TestProtectedPaths) -> Expected '%s' to be refused, but got: %v",
+				path,
+				err,
+			)
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(home, "notes.txt"), nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestProtectedPaths",
+		fmt.Sprintf("--force -f '^' -r _ '%s'", home),
+	)
+
+	_, err = executeTest(args)
+	if err != nil {
+		t.Fatalf(
+			"Test (TestProtectedPaths) -> Expected --force to allow '%s', but got: %v",
+			home,
+			err,
+		)
+	}
+
+	// More paths can be protected through the config file
+	configFile := filepath.Join(t.TempDir(), "config.json")
+
+	b, err := json.Marshal(map[string][]string{
+		"protected_paths": {filepath.Join(testDir, "images")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(configFile, b, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, wantErr := range map[string]bool{
+		"images": true,
+		"movies": false,
+	} {
+		args = parseArgs(
+			t,
+			"TestProtectedPaths",
+			fmt.Sprintf("--config '%s' -f '^' -r _ %s", configFile, path),
+		)
+
+		_, err = executeTest(args)
+		if (err != nil) != wantErr {
+			t.Fatalf(
+				"Test (TestProtectedPaths) -> Expected an error for '%s': %t, but got: %v",
+				path,
+				wantErr,
+				err,
+			)
+		}
+	}
+}
+
+// setupLargeFileSystem creates a directory tree containing many files of
+// different types and returns the absolute path to its root.
+func setupLargeFileSystem(b *testing.B) string {
+	b.Helper()
+
+	testDir := b.TempDir()
+
+	exts := []string{"jpg", "png", "txt", "mp4", "pdf"}
+
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(
+			testDir,
+			fmt.Sprintf("dir-%d", i),
+			fmt.Sprintf("subdir-%d", i),
+		)
+
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for j := 0; j < 200; j++ {
+			pathToFile := filepath.Join(
+				dir,
+				fmt.Sprintf("file-%d.%s", j, exts[j%len(exts)]),
+			)
+
+			f, err := os.Create(pathToFile)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			f.Close()
+		}
+	}
+
+	return testDir
+}
+
+func BenchmarkExtFilter(b *testing.B) {
+	testDir := setupLargeFileSystem(b)
+
+	cases := []struct {
+		name string
+		args string
+	}{
+		{
+			name: "filter by find pattern",
+			args: `-f '\.jpg$' -r '.jpeg' -R --json`,
+		},
+		{
+			name: "filter by extension during traversal",
+			args: `-f '\.jpg$' -r '.jpeg' -R --json --ext jpg`,
+		},
+	}
+
+	for _, bc := range cases {
+		args := parseArgs(b, bc.name, bc.args+" '"+testDir+"'")
+
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := executeTest(args)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHashVariables(b *testing.B) {
+	testDir := b.TempDir()
+
+	content := bytes.Repeat([]byte("f2"), 256*1024)
+
+	for i := 0; i < 100; i++ {
+		err := os.WriteFile(
+			filepath.Join(testDir, fmt.Sprintf("file-%d.bin", i)),
+			append(content, byte(i)),
+			0o600,
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		args string
+	}{
+		{
+			name: "serial hashing",
+			args: `-r '{{hash.sha256}}' --json --workers 1`,
+		},
+		{
+			name: "concurrent hashing",
+			args: fmt.Sprintf(
+				`-r '{{hash.sha256}}' --json --workers %d`,
+				runtime.NumCPU(),
+			),
+		},
+	}
+
+	for _, bc := range cases {
+		args := parseArgs(b, bc.name, bc.args+" '"+testDir+"'")
+
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := executeTest(args)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
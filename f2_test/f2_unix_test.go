@@ -3,7 +3,25 @@
 
 package f2_test
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ayoisaiah/f2"
+	"github.com/ayoisaiah/f2/internal/conflict"
+	internaljson "github.com/ayoisaiah/f2/internal/json"
+	"github.com/ayoisaiah/f2/report"
+)
 
 // dummy function necessary for compilation in Unix.
 func setHidden(path string) error {
@@ -14,3 +32,631 @@ func TestUnix(t *testing.T) {
 	cases := retrieveTestCases(t, "unix.json")
 	runTestCases(t, cases)
 }
+
+func TestOwnerFilter(t *testing.T) {
+	currentUser, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid := strconv.Itoa(os.Getuid())
+	gid := strconv.Itoa(os.Getgid())
+	otherUID := strconv.Itoa(os.Getuid() + 1)
+
+	cases := []struct {
+		wantErr error
+		name    string
+		args    string
+		want    int
+	}{
+		{
+			name: "match files owned by the current user id",
+			args: "--owner " + uid,
+			want: 2,
+		},
+		{
+			name: "match files owned by the current user name",
+			args: "--owner " + currentUser.Username,
+			want: 2,
+		},
+		{
+			name: "match files that belong to the current group id",
+			args: "--group " + gid,
+			want: 2,
+		},
+		{
+			name:    "files owned by a different user are not matched",
+			args:    "--owner " + otherUID + " --group " + gid,
+			wantErr: f2.ErrNoMatches,
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestOwnerFilter")
+
+		args := parseArgs(
+			t,
+			"TestOwnerFilter",
+			"-f dsc -r photo --json "+tc.args+" "+filepath.Join(
+				testDir,
+				"images",
+			),
+		)
+
+		result, err := executeTest(args)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf(
+					"Test (%s) -> Expected error: %v, but got: %v",
+					tc.name,
+					tc.wantErr,
+					err,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(output.Changes) != tc.want {
+			t.Fatalf(
+				"Test (%s) -> Expected %d matches, but got: %d",
+				tc.name,
+				tc.want,
+				len(output.Changes),
+			)
+		}
+	}
+}
+
+func TestUnknownOwner(t *testing.T) {
+	testDir := setupFileSystem(t, "TestUnknownOwner")
+
+	args := parseArgs(
+		t,
+		"TestUnknownOwner",
+		"-f dsc -r photo --owner f2-no-such-user "+testDir,
+	)
+
+	_, err := executeTest(args)
+	if err == nil {
+		t.Fatal("Test (TestUnknownOwner) -> Expected an error but got nil")
+	}
+}
+
+func TestSymlinkFilter(t *testing.T) {
+	cases := []struct {
+		name string
+		args string
+		want int
+	}{
+		{
+			name: "symbolic links are matched by default",
+			args: "",
+			want: 4,
+		},
+		{
+			name: "match only symbolic links",
+			args: "--match-symlinks-only",
+			want: 2,
+		},
+		{
+			name: "match only broken symbolic links",
+			args: "--broken-symlinks",
+			want: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestSymlinkFilter")
+		imagesDir := filepath.Join(testDir, "images")
+
+		err := os.Symlink(
+			filepath.Join(imagesDir, "dsc-001.arw"),
+			filepath.Join(imagesDir, "dsc-link.arw"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Symlink(
+			filepath.Join(imagesDir, "missing.arw"),
+			filepath.Join(imagesDir, "dsc-broken.arw"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		args := parseArgs(
+			t,
+			"TestSymlinkFilter",
+			"-f dsc -r photo --json "+tc.args+" "+imagesDir,
+		)
+
+		result, err := executeTest(args)
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		var output internaljson.Output
+
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(output.Changes) != tc.want {
+			t.Fatalf(
+				"Test (%s) -> Expected %d matches, but got: %d",
+				tc.name,
+				tc.want,
+				len(output.Changes),
+			)
+		}
+	}
+
+	testDir := setupFileSystem(t, "TestSymlinkFilter")
+	imagesDir := filepath.Join(testDir, "images")
+	target := filepath.Join(imagesDir, "missing.arw")
+
+	err := os.Symlink(target, filepath.Join(imagesDir, "dsc-broken.arw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestSymlinkFilter",
+		"-f dsc -r photo --broken-symlinks -x "+imagesDir,
+	)
+
+	_, err = executeTest(args)
+	if err != nil {
+		t.Fatalf("Test (rename broken link) — Unexpected error: %v", err)
+	}
+
+	link, err := os.Readlink(filepath.Join(imagesDir, "photo-broken.arw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link != target {
+		t.Fatalf(
+			"Test (rename broken link) -> Expected link to %s, but got: %s",
+			target,
+			link,
+		)
+	}
+
+	if _, err := os.Lstat(filepath.Join(imagesDir, "dsc-001.arw")); err != nil {
+		t.Fatalf("Test (rename broken link) -> Regular file was renamed: %v", err)
+	}
+}
+
+func TestWorkingDirRenameConflictSymlink(t *testing.T) {
+	testDir := setupFileSystem(t, "TestWorkingDirRenameConflictSymlink")
+
+	// The parent of images is reached through a link so that the source
+	// path differs from the working directory until the link is resolved
+	link := filepath.Join(t.TempDir(), "link")
+
+	err := os.Symlink(testDir, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(filepath.Join(testDir, "images"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestWorkingDirRenameConflictSymlink",
+		"-f images -r pictures -d --json "+link,
+	)
+
+	result, err := executeTest(args)
+	if err == nil {
+		t.Fatal("expected renaming the working directory to be prevented")
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(output.Conflicts[conflict.WorkingDirRename]) != 1 {
+		t.Fatalf(
+			"Test (TestWorkingDirRenameConflictSymlink) -> Expected a working directory conflict, got: %+v\n",
+			output.Conflicts,
+		)
+	}
+}
+
+func TestSymlinkCycle(t *testing.T) {
+	testDir := setupFileSystem(t, "TestSymlinkCycle")
+	imagesDir := filepath.Join(testDir, "images")
+
+	// pics is an alias of images and images/canon/up loops back to it
+	err := os.Symlink(imagesDir, filepath.Join(testDir, "pics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink("..", filepath.Join(imagesDir, "canon", "up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := parseArgs(
+		t,
+		"TestSymlinkCycle",
+		"-f '^' -r x- -R --json images pics images/canon/up",
+	)
+
+	result, err := executeTest(args)
+	if err != nil {
+		t.Fatalf("Test (TestSymlinkCycle) — Unexpected error: %v", err)
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(output.Changes))
+	for _, ch := range output.Changes {
+		got = append(got, filepath.Join(ch.BaseDir, ch.Source))
+	}
+
+	slices.Sort(got)
+
+	want := []string{
+		"images/canon/startrails1.jpg",
+		"images/canon/startrails2.jpg",
+		"images/canon/up",
+		"images/dsc-001.arw",
+		"images/dsc-002.arw",
+		"images/sony/dsc-003.arw",
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf(
+			"Test (TestSymlinkCycle) -> Expected each file to be matched once %v, but got: %v",
+			want,
+			got,
+		)
+	}
+}
+
+func TestExecAfter(t *testing.T) {
+	cases := []struct {
+		name         string
+		args         string
+		wantDone     []string
+		wantWarnings int
+		wantErr      bool
+	}{
+		{
+			name: "the command is run for each renamed file",
+			args: "-x --exec-after 'touch {{target}}.done'",
+			wantDone: []string{
+				"images/photo-001.arw.done",
+				"images/photo-002.arw.done",
+			},
+		},
+		{
+			name: "the command is not run in dry-run mode",
+			args: "--exec-after 'touch {{target}}.done'",
+		},
+		{
+			name:         "failures are reported without aborting the other commands",
+			args:         "-x --exec-after 'ls {{source}}'",
+			wantWarnings: 2,
+		},
+		{
+			name:    "the first failure is returned with --exec-after-fail-fast",
+			args:    "-x --exec-after 'ls {{source}}' --exec-after-fail-fast",
+			wantErr: true,
+		},
+	}
+
+	stderr := report.Stderr
+	report.Stderr = io.Discard
+
+	t.Cleanup(func() {
+		report.Stderr = stderr
+	})
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestExecAfter")
+
+			args := parseArgs(
+				t,
+				tc.name,
+				"-f dsc -r photo --json "+tc.args+" images",
+			)
+
+			result, err := executeTest(args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error, but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(output.Warnings) != tc.wantWarnings {
+				t.Fatalf(
+					"Test (%s) -> Expected %d warnings, but got: %v",
+					tc.name,
+					tc.wantWarnings,
+					output.Warnings,
+				)
+			}
+
+			done, err := filepath.Glob(
+				filepath.Join(testDir, "images", "*.done"),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, path := range done {
+				rel, err := filepath.Rel(testDir, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, rel)
+			}
+
+			if !slices.Equal(got, tc.wantDone) {
+				t.Fatalf(
+					"Test (%s) -> Expected the command to run for %v, but got: %v",
+					tc.name,
+					tc.wantDone,
+					got,
+				)
+			}
+		})
+	}
+}
+
+func TestValidateWith(t *testing.T) {
+	cases := []struct {
+		name         string
+		validator    string
+		want         []string
+		wantWarnings int
+	}{
+		{
+			name:      "the changes approved by the validator are kept",
+			validator: "true",
+			want: []string{
+				"dsc-001.arw|photo-001.arw|ok",
+				"dsc-002.arw|photo-002.arw|ok",
+			},
+		},
+		{
+			name:      "the changes rejected by the validator are left unchanged",
+			validator: "test {{target}} != images/photo-002.arw",
+			want: []string{
+				"dsc-001.arw|photo-001.arw|ok",
+				"dsc-002.arw|dsc-002.arw|unchanged",
+			},
+			wantWarnings: 1,
+		},
+		{
+			name:      "a validator that cannot be run rejects every change",
+			validator: "f2-missing-validator {{source}}",
+			want: []string{
+				"dsc-001.arw|dsc-001.arw|unchanged",
+				"dsc-002.arw|dsc-002.arw|unchanged",
+			},
+			wantWarnings: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			setupFileSystem(t, "TestValidateWith")
+
+			args := parseArgs(
+				t,
+				tc.name,
+				"-f dsc -r photo --json --workers 2 --validate-with '"+tc.validator+"' images",
+			)
+
+			result, err := executeTest(args)
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(output.Changes))
+			for _, ch := range output.Changes {
+				got = append(
+					got,
+					ch.Source+"|"+ch.Target+"|"+string(ch.Status),
+				)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf(
+					"Test (%s) -> Expected the changes to be %v, but got: %v",
+					tc.name,
+					tc.want,
+					got,
+				)
+			}
+
+			if len(output.Warnings) != tc.wantWarnings {
+				t.Fatalf(
+					"Test (%s) -> Expected %d warnings, but got: %v",
+					tc.name,
+					tc.wantWarnings,
+					output.Warnings,
+				)
+			}
+		})
+	}
+}
+
+func TestPathLength(t *testing.T) {
+	// 20 directories of 210 bytes each exceed PATH_MAX
+	longDir := strings.Repeat(strings.Repeat("d", 210)+"/", 20)
+
+	testDir := setupFileSystem(t, "TestPathLength")
+
+	args := parseArgs(
+		t,
+		"TestPathLength",
+		"-f dsc-001 -r "+longDir+"photo-001 --json "+filepath.Join(
+			testDir,
+			"images",
+		),
+	)
+
+	result, err := executeTest(args)
+	if err == nil {
+		t.Fatal("Test (TestPathLength) -> Expected an error but got nil")
+	}
+
+	var output internaljson.Output
+
+	err = json.Unmarshal(result, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(output.Conflicts[conflict.MaxPathLengthExceeded]) != 1 {
+		t.Fatalf(
+			"Test (TestPathLength) -> Expected a path length conflict, but got: %v",
+			output.Conflicts,
+		)
+	}
+
+	_, err = os.Stat(filepath.Join(testDir, "images", "dsc-001.arw"))
+	if err != nil {
+		t.Fatalf("Test (TestPathLength) -> Source was renamed: %v", err)
+	}
+}
+
+func TestDirMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{
+			name: "created directories default to 0750",
+			args: "-f dsc-001 -r raw/dsc-001 -x images",
+			want: 0o750,
+		},
+		{
+			name: "created directories use the provided mode",
+			args: "-f dsc-001 -r raw/dsc-001 -x --dir-mode 0755 images",
+			want: 0o755,
+		},
+		{
+			name: "the mode may omit the leading zero",
+			args: "-f dsc-001 -r raw/dsc-001 -x --dir-mode 700 images",
+			want: 0o700,
+		},
+		{
+			name:    "the mode must be octal",
+			args:    "-f dsc-001 -r raw/dsc-001 -x --dir-mode 0789 images",
+			wantErr: true,
+		},
+		{
+			name:    "the mode must only contain permission bits",
+			args:    "-f dsc-001 -r raw/dsc-001 -x --dir-mode 01777 images",
+			wantErr: true,
+		},
+	}
+
+	// The mode of the created directories is subject to the umask
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			testDir := setupFileSystem(t, "TestDirMode")
+
+			args := parseArgs(t, tc.name, tc.args)
+
+			_, err := executeTest(args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Test (%s) -> Expected an error but got nil", tc.name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+			}
+
+			fileInfo, err := os.Stat(filepath.Join(testDir, "images", "raw"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tc.want &^ os.FileMode(umask)
+
+			if fileInfo.Mode().Perm() != want {
+				t.Fatalf(
+					"Test (%s) -> Expected directory mode: %v, but got: %v",
+					tc.name,
+					want,
+					fileInfo.Mode().Perm(),
+				)
+			}
+		})
+	}
+}
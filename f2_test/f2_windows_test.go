@@ -4,8 +4,15 @@
 package f2_test
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
+
+	"github.com/ayoisaiah/f2/internal/conflict"
+	internaljson "github.com/ayoisaiah/f2/internal/json"
 )
 
 func setHidden(path string) error {
@@ -26,3 +33,68 @@ func TestWindows(t *testing.T) {
 	cases := retrieveTestCases(t, "windows.json")
 	runTestCases(t, cases)
 }
+
+func TestPathLength(t *testing.T) {
+	// 5 directories of 60 characters each exceed MAX_PATH regardless of
+	// the location of the test directory
+	longDir := strings.Repeat(strings.Repeat("d", 60)+`\`, 5)
+
+	cases := []struct {
+		name       string
+		args       string
+		wantTarget string
+		wantErr    bool
+	}{
+		{
+			name:    "detect a target path longer than MAX_PATH",
+			args:    "-f dsc-001 -r " + longDir + "photo-001 --json images",
+			wantErr: true,
+		},
+		{
+			name:       "create a long target path with extended-length paths",
+			args:       "-f dsc-001 -r " + longDir + "photo-001 --long-paths -x images",
+			wantTarget: filepath.Join("images", longDir, "photo-001.arw"),
+		},
+	}
+
+	for _, tc := range cases {
+		testDir := setupFileSystem(t, "TestPathLength")
+
+		args := parseArgs(t, "TestPathLength", tc.args)
+
+		result, err := executeTest(args)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("Test (%s) -> Expected an error but got nil", tc.name)
+			}
+
+			var output internaljson.Output
+
+			err = json.Unmarshal(result, &output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(output.Conflicts[conflict.MaxPathLengthExceeded]) != 1 {
+				t.Fatalf(
+					"Test (%s) -> Expected a path length conflict, but got: %v",
+					tc.name,
+					output.Conflicts,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Test (%s) — Unexpected error: %v", tc.name, err)
+		}
+
+		// The absolute path is converted to an extended-length path
+		// by the os package
+		_, err = os.Stat(filepath.Join(testDir, tc.wantTarget))
+		if err != nil {
+			t.Fatalf("Test (%s) -> Expected the target to exist: %v", tc.name, err)
+		}
+	}
+}
@@ -5,27 +5,131 @@ package find
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/exp/slices"
 
 	"github.com/ayoisaiah/f2/internal/config"
+	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/gitignore"
+	"github.com/ayoisaiah/f2/internal/hash"
+	internalos "github.com/ayoisaiah/f2/internal/os"
 	internalpath "github.com/ayoisaiah/f2/internal/path"
+	"github.com/ayoisaiah/f2/internal/pattern"
+	"github.com/ayoisaiah/f2/report"
 )
 
 const (
 	dotCharacter = 46
 )
 
+// Reasons reported by --explain for paths that are not matched.
+const (
+	skipDir      = "directories are not included (use -d/--include-dir)"
+	skipNotDir   = "only directories are included (-D/--only-dir)"
+	skipHidden   = "hidden paths are not included (use -H/--hidden)"
+	skipExcluded = "matches the exclude pattern '%s'"
+	skipNoMatch  = "does not match the find pattern '%s'"
+	skipExt      = "extension is not one of: %s"
+	skipMinDepth = "is shallower than the minimum depth (%d)"
+	skipConform  = "already conforms to the pattern '%s'"
+	skipOwner    = "is not owned by the specified owner or group"
+	skipExtRule  = "no extension rule in the config file applies to it"
+	skipSymlink  = "is not a symbolic link (--match-symlinks-only)"
+	skipBroken   = "is not a broken symbolic link (--broken-symlinks)"
+	skipModTime  = "was not modified since the last run (--since-last-run)"
+	skipNotEmpty = "is not empty (--empty)"
+	skipEmpty    = "is empty (--non-empty)"
+	skipIgnored  = "is ignored by git (--respect-gitignore)"
+	skipUnmapped = "is not listed in the map file (--map-file)"
+	skipVisited  = "is the same directory as '%s' (through a symbolic link)"
+	skipHashDir  = "directories have no content hash (--hash-in/--hash-not-in)"
+	skipHashIn   = "its hash is not in '%s' (--hash-in)"
+	skipHashOut  = "its hash is in '%s' (--hash-not-in)"
+)
+
 // csvRows keeps track of each row in a CSV file so that it can be associated
 // with a file renaming change. The key is the absolute path of the source file
 // and the value is the correspoding row in the CSV file.
 var csvRows = make(map[string][]string)
 
+// appleDoubles keeps track of the matches that have an AppleDouble file
+// (see --with-xattrs). The key is the path of the match (joined with the
+// directory it was found in) and the value is the name of its AppleDouble
+// file.
+var appleDoubles = make(map[string]string)
+
+var (
+	caseInsensitiveFS     bool
+	caseInsensitiveFSOnce sync.Once
+)
+
+// isCaseInsensitiveFS reports whether the filesystem of the working directory
+// is case insensitive. It is detected once by checking if the working
+// directory can be accessed with its case changed. If that cannot be
+// determined, the default for the OS is assumed.
+func isCaseInsensitiveFS() bool {
+	caseInsensitiveFSOnce.Do(func() {
+		caseInsensitiveFS = runtime.GOOS == internalos.Windows ||
+			runtime.GOOS == internalos.Darwin
+
+		wd, err := os.Getwd()
+		if err != nil {
+			return
+		}
+
+		alt := strings.ToUpper(wd)
+		if alt == wd {
+			alt = strings.ToLower(wd)
+		}
+
+		if alt == wd {
+			return
+		}
+
+		wdInfo, err := os.Stat(wd)
+		if err != nil {
+			return
+		}
+
+		altInfo, err := os.Stat(alt)
+
+		caseInsensitiveFS = err == nil && os.SameFile(wdInfo, altInfo)
+	})
+
+	return caseInsensitiveFS
+}
+
+// pathKey returns the form of the path that is used to detect duplicate
+// paths. It ignores the case of the path on case insensitive filesystems.
+func pathKey(path string) string {
+	if isCaseInsensitiveFS() {
+		return strings.ToLower(path)
+	}
+
+	return path
+}
+
+// sameName reports whether the file names refer to the same file within a
+// directory.
+func sameName(a, b string) bool {
+	if isCaseInsensitiveFS() {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
 func readCSVFile(filePath string) ([][]string, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -45,13 +149,30 @@ func readCSVFile(filePath string) ([][]string, error) {
 }
 
 // filterMatches filters out files that do not match the find string or one
-// that matches any exclusion patterns.
+// that matches any exclusion patterns. Files whose names already conform to
+// the skipConforming pattern (if any) are also filtered out.
 func filterMatches(
 	pathsToFilter internalpath.Collection,
 	pathsToSearch []string,
-	searchRegex *regexp.Regexp, excludeFilterInput []string,
-	includeDir, includeHidden, onlyDir, ignoreExt bool,
+	searchRegex, dirSearchRegex pattern.Regexp,
+	excludeFilterInput []string,
+	skipConforming string,
+	includeDir, includeHidden, onlyDir, findIncludesExt, explain bool,
 ) error {
+	// Compile each pattern separately first so that
+	// the offending one can be identified
+	for i, exclude := range excludeFilterInput {
+		_, err := regexp.Compile(exclude)
+		if err != nil {
+			return &config.PatternError{
+				Err:     err,
+				Kind:    "exclude",
+				Pattern: exclude,
+				Index:   i + 1,
+			}
+		}
+	}
+
 	excludeFilter := strings.Join(excludeFilterInput, "|")
 
 	excludeMatchRegex, err := regexp.Compile(excludeFilter)
@@ -59,6 +180,16 @@ func filterMatches(
 		return err
 	}
 
+	conformingRegex, err := regexp.Compile(skipConforming)
+	if err != nil {
+		return &config.PatternError{
+			Err:     err,
+			Kind:    "skip-conforming",
+			Pattern: skipConforming,
+			Index:   1,
+		}
+	}
+
 	for path, dirEntry := range pathsToFilter {
 		filteredDirEntry := dirEntry[:0]
 
@@ -67,11 +198,21 @@ func filterMatches(
 
 			entryIsDir := entry.IsDir()
 
+			entryPath := filepath.Join(path, filename)
+
 			if entryIsDir && !includeDir {
+				if explain {
+					report.Skipped(entryPath, skipDir)
+				}
+
 				continue
 			}
 
 			if onlyDir && !entryIsDir {
+				if explain {
+					report.Skipped(entryPath, skipNotDir)
+				}
+
 				continue
 			}
 
@@ -104,22 +245,57 @@ func filterMatches(
 					}
 
 					if shouldSkip {
+						if explain {
+							report.Skipped(entryPath, skipHidden)
+						}
+
 						continue
 					}
 				}
 			}
 
-			if ignoreExt && !entryIsDir {
+			if !findIncludesExt && !entryIsDir {
 				filename = internalpath.FilenameWithoutExtension(filename)
 			}
 
 			if excludeFilter != "" && excludeMatchRegex.MatchString(filename) {
+				if explain {
+					report.Skipped(
+						entryPath,
+						fmt.Sprintf(
+							skipExcluded,
+							matchingPattern(excludeFilterInput, filename),
+						),
+					)
+				}
+
+				continue
+			}
+
+			if skipConforming != "" && conformingRegex.MatchString(filename) {
+				if explain {
+					report.Skipped(
+						entryPath,
+						fmt.Sprintf(skipConform, skipConforming),
+					)
+				}
+
 				continue
 			}
 
-			matched := searchRegex.MatchString(filename)
+			regex := searchRegex
+			if entryIsDir && dirSearchRegex != nil {
+				regex = dirSearchRegex
+			}
+
+			matched := regex.MatchString(filename)
 			if matched {
 				filteredDirEntry = append(filteredDirEntry, entry)
+			} else if explain {
+				report.Skipped(
+					entryPath,
+					fmt.Sprintf(skipNoMatch, regex.String()),
+				)
 			}
 
 			pathsToFilter[path] = filteredDirEntry
@@ -133,6 +309,67 @@ func filterMatches(
 	return nil
 }
 
+// matchingPattern returns the first of the patterns that matches
+// the file name.
+func matchingPattern(patterns []string, filename string) string {
+	for _, p := range patterns {
+		if regexp.MustCompile(p).MatchString(filename) {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// hasExt reports whether the file name ends with any of the provided
+// extensions. The leading dot is optional and the comparison is case
+// insensitive so that `jpg`, `.jpg` and `JPG` are all equivalent.
+func hasExt(filename string, exts []string) bool {
+	filename = strings.ToLower(filename)
+
+	for _, ext := range exts {
+		ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+
+		if strings.HasSuffix(filename, ext) && filename != ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterByExt removes the files whose extension is not present in
+// `exts` so that they are discarded before any other work is done on them.
+// Directories are always retained so that they may still be traversed.
+func filterByExt(
+	dir string,
+	de []os.DirEntry,
+	exts []string,
+	explain bool,
+) []os.DirEntry {
+	if len(exts) == 0 {
+		return de
+	}
+
+	filtered := make([]os.DirEntry, 0, len(de))
+
+	for _, e := range de {
+		if e.IsDir() || hasExt(e.Name(), exts) {
+			filtered = append(filtered, e)
+			continue
+		}
+
+		if explain {
+			report.Skipped(
+				filepath.Join(dir, e.Name()),
+				fmt.Sprintf(skipExt, strings.Join(exts, ", ")),
+			)
+		}
+	}
+
+	return filtered
+}
+
 func removeHidden(
 	de []os.DirEntry,
 	baseDir string,
@@ -151,10 +388,61 @@ func removeHidden(
 	return ret, nil
 }
 
+// realPath returns the normalized absolute path of the directory with
+// any symbolic links resolved.
+func realPath(dir string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	absPath, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	return pathKey(absPath), nil
+}
+
+// visit records that the directory was searched. It reports false if the
+// same directory was already searched through a different path (such as a
+// symbolic link to it) so that it is not searched twice or in a loop. The
+// keys of visited are the real paths of the directories and the values are
+// the paths that they were searched through.
+func visit(
+	dir string,
+	visited map[string]string,
+	explain bool,
+) (bool, error) {
+	realDir, err := realPath(dir)
+	if err != nil {
+		return false, err
+	}
+
+	if prev, ok := visited[realDir]; ok && prev != dir {
+		if explain {
+			report.Skipped(dir, fmt.Sprintf(skipVisited, prev))
+		}
+
+		return false, nil
+	}
+
+	visited[realDir] = dir
+
+	return true, nil
+}
+
+// walk adds the contents of the directories in paths recursively up to the
+// maximum depth. Directories ignored by git (if ignore is not nil) or
+// already searched through a different path (see visit) are not
+// traversed.
 func walk(
 	paths internalpath.Collection,
+	extFilter []string,
 	maxDepth int,
-	includeHidden bool,
+	ignore *gitignore.Matcher,
+	visited map[string]string,
+	includeHidden, explain bool,
 ) error {
 	var recursedPaths []string
 
@@ -183,12 +471,33 @@ loop:
 		for _, entry := range dirContents {
 			if entry.IsDir() {
 				fp := filepath.Join(dir, entry.Name())
+
+				if ignore != nil {
+					ignored, err := ignore.Ignored(fp, true)
+					if err != nil {
+						return err
+					}
+
+					if ignored {
+						continue
+					}
+				}
+
+				firstVisit, err := visit(fp, visited, explain)
+				if err != nil {
+					return err
+				}
+
+				if !firstVisit {
+					continue
+				}
+
 				dirEntry, err := os.ReadDir(fp)
 				if err != nil {
 					return err
 				}
 
-				currentLevel[fp] = dirEntry
+				currentLevel[fp] = filterByExt(fp, dirEntry, extFilter, explain)
 			}
 		}
 
@@ -206,7 +515,7 @@ loop:
 		}
 
 		currentDepth++
-		if !(maxDepth > 0 && currentDepth == maxDepth) {
+		if maxDepth < 0 || currentDepth < maxDepth {
 			goto loop
 		}
 	}
@@ -214,12 +523,392 @@ loop:
 	return nil
 }
 
+// filterEntries removes the entries that keep rejects from each directory
+// and the directories that are left with no entries. The reason that keep
+// returns for each rejected entry is reported if explain is set.
+func filterEntries(
+	paths internalpath.Collection,
+	explain bool,
+	keep func(dir string, entry fs.DirEntry) (bool, string, error),
+) error {
+	for dir, dirContents := range paths {
+		filteredContents := dirContents[:0]
+
+		for _, entry := range dirContents {
+			ok, reason, err := keep(dir, entry)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				filteredContents = append(filteredContents, entry)
+				continue
+			}
+
+			if explain {
+				report.Skipped(filepath.Join(dir, entry.Name()), reason)
+			}
+		}
+
+		if len(filteredContents) == 0 {
+			delete(paths, dir)
+			continue
+		}
+
+		paths[dir] = filteredContents
+	}
+
+	return nil
+}
+
+// filterByGitignore removes the entries that are ignored by git. The files
+// that were specified as path arguments are always retained.
+func filterByGitignore(
+	paths internalpath.Collection,
+	pathsToSearch []string,
+	ignore *gitignore.Matcher,
+	explain bool,
+) error {
+	pathArgs := make(map[string]bool, len(pathsToSearch))
+	for _, pathArg := range pathsToSearch {
+		pathArgs[pathKey(filepath.Clean(pathArg))] = true
+	}
+
+	return filterEntries(
+		paths,
+		explain,
+		func(dir string, entry fs.DirEntry) (bool, string, error) {
+			entryPath := filepath.Join(dir, entry.Name())
+
+			ignored, err := ignore.Ignored(entryPath, entry.IsDir())
+			if err != nil {
+				return false, "", err
+			}
+
+			return !ignored || pathArgs[pathKey(entryPath)], skipIgnored, nil
+		},
+	)
+}
+
+// filterByOwner removes the entries that are not owned by the specified
+// user or group. It has no effect on Windows.
+func filterByOwner(
+	paths internalpath.Collection,
+	owner, group string,
+	explain bool,
+) error {
+	uid, gid, err := lookupOwner(owner, group)
+	if err != nil {
+		return err
+	}
+
+	return filterEntries(
+		paths,
+		explain,
+		func(_ string, entry fs.DirEntry) (bool, string, error) {
+			owned, ownerErr := isOwnedBy(entry, uid, gid)
+
+			return owned, skipOwner, ownerErr
+		},
+	)
+}
+
+// filterBySymlinks removes the entries that are not symbolic links. If
+// brokenOnly is set, the links whose target exists are also removed.
+func filterBySymlinks(
+	paths internalpath.Collection,
+	brokenOnly, explain bool,
+) error {
+	return filterEntries(
+		paths,
+		explain,
+		func(dir string, entry fs.DirEntry) (bool, string, error) {
+			entryPath := filepath.Join(dir, entry.Name())
+
+			fileInfo, err := os.Lstat(entryPath)
+			if err != nil {
+				return false, "", err
+			}
+
+			if fileInfo.Mode()&os.ModeSymlink == 0 {
+				return false, skipSymlink, nil
+			}
+
+			if !brokenOnly {
+				return true, "", nil
+			}
+
+			_, err = os.Stat(entryPath)
+
+			return errors.Is(err, os.ErrNotExist), skipBroken, nil
+		},
+	)
+}
+
+// filterByModTime removes the entries that were last modified
+// before the specified time.
+func filterByModTime(
+	paths internalpath.Collection,
+	since time.Time,
+	explain bool,
+) error {
+	return filterEntries(
+		paths,
+		explain,
+		func(_ string, entry fs.DirEntry) (bool, string, error) {
+			fileInfo, err := entry.Info()
+			if err != nil {
+				return false, "", err
+			}
+
+			return !fileInfo.ModTime().Before(since), skipModTime, nil
+		},
+	)
+}
+
+// filterByHashes removes the entries whose content hash is not in the list
+// of the --hash-in flag or is in the list of the --hash-not-in flag.
+// Directories are also removed since they have no content to hash. The
+// hashes are cached so that they are not computed again for the hash
+// variables in the replacement.
+func filterByHashes(
+	paths internalpath.Collection,
+	conf *config.Config,
+) error {
+	return filterEntries(
+		paths,
+		conf.Explain,
+		func(dir string, entry fs.DirEntry) (bool, string, error) {
+			reason, err := hashSkipReason(
+				filepath.Join(dir, entry.Name()),
+				entry,
+				conf,
+			)
+
+			return reason == "", reason, err
+		},
+	)
+}
+
+// hashSkipReason returns the reason that filterByHashes removes an entry
+// or an empty string if it is kept.
+func hashSkipReason(
+	entryPath string,
+	entry fs.DirEntry,
+	conf *config.Config,
+) (string, error) {
+	if entry.IsDir() {
+		return skipHashDir, nil
+	}
+
+	if conf.HashInList != nil {
+		found, err := conf.HashInList.Contains(entryPath)
+		if err != nil {
+			return "", err
+		}
+
+		if !found {
+			return fmt.Sprintf(skipHashIn, conf.HashIn), nil
+		}
+	}
+
+	if conf.HashNotInList != nil {
+		found, err := conf.HashNotInList.Contains(entryPath)
+		if err != nil {
+			return "", err
+		}
+
+		if found {
+			return fmt.Sprintf(skipHashOut, conf.HashNotIn), nil
+		}
+	}
+
+	return "", nil
+}
+
+// pairAppleDoubles records the AppleDouble file (`._name`) of each entry so
+// that it is renamed along with the entry. The AppleDouble files that are
+// matched themselves (such as with -H/--hidden) are removed if their entry
+// is also matched since they follow the entry.
+func pairAppleDoubles(paths internalpath.Collection) error {
+	for dir, dirContents := range paths {
+		matched := make(map[string]bool, len(dirContents))
+		for _, entry := range dirContents {
+			matched[entry.Name()] = true
+		}
+
+		filteredContents := dirContents[:0]
+
+		for _, entry := range dirContents {
+			name := entry.Name()
+
+			if strings.HasPrefix(name, file.AppleDoublePrefix) &&
+				matched[strings.TrimPrefix(name, file.AppleDoublePrefix)] {
+				continue
+			}
+
+			filteredContents = append(filteredContents, entry)
+
+			entryPath := filepath.Join(dir, name)
+
+			_, err := os.Lstat(file.AppleDoublePath(entryPath))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			appleDoubles[entryPath] = file.AppleDoublePrefix + name
+		}
+
+		paths[dir] = filteredContents
+	}
+
+	return nil
+}
+
+// isEmpty reports whether the file is zero bytes in size
+// or the directory has no entries.
+func isEmpty(path string, entry fs.DirEntry) (bool, error) {
+	if !entry.IsDir() {
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return false, err
+		}
+
+		return fileInfo.Size() == 0, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// filterByEmptiness removes the entries that are not empty or (if nonEmpty
+// is set) those that are empty. See isEmpty.
+func filterByEmptiness(
+	paths internalpath.Collection,
+	nonEmpty, explain bool,
+) error {
+	reason := skipNotEmpty
+	if nonEmpty {
+		reason = skipEmpty
+	}
+
+	return filterEntries(
+		paths,
+		explain,
+		func(dir string, entry fs.DirEntry) (bool, string, error) {
+			empty, err := isEmpty(filepath.Join(dir, entry.Name()), entry)
+
+			return empty != nonEmpty, reason, err
+		},
+	)
+}
+
+// filterByExtRules removes the entries that none of the extension rules in
+// the config file apply to. Directories are also removed since the rules
+// only apply to files.
+func filterByExtRules(
+	paths internalpath.Collection,
+	conf *config.Config,
+) error {
+	return filterEntries(
+		paths,
+		conf.Explain,
+		func(_ string, entry fs.DirEntry) (bool, string, error) {
+			return !entry.IsDir() && conf.ExtRule(entry.Name()) != nil,
+				skipExtRule,
+				nil
+		},
+	)
+}
+
+// filterByMappings removes the entries whose name is not mapped to a new
+// name in the map file. A warning is reported for each mapping that does
+// not apply to any of the remaining entries.
+func filterByMappings(
+	paths internalpath.Collection,
+	mappings map[string]string,
+	explain bool,
+) error {
+	mapped := make(map[string]bool, len(mappings))
+
+	err := filterEntries(
+		paths,
+		explain,
+		func(_ string, entry fs.DirEntry) (bool, string, error) {
+			_, ok := mappings[entry.Name()]
+			if ok {
+				mapped[entry.Name()] = true
+			}
+
+			return ok, skipUnmapped, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	unmapped := make([]string, 0, len(mappings))
+
+	for source := range mappings {
+		if !mapped[source] {
+			unmapped = append(unmapped, source)
+		}
+	}
+
+	slices.Sort(unmapped)
+
+	for _, source := range unmapped {
+		report.Warning(
+			fmt.Sprintf("'%s' in the map file does not match any file", source),
+		)
+	}
+
+	return nil
+}
+
+// filterByMinDepth removes the contents of the directories that are
+// shallower than the minimum depth.
+func filterByMinDepth(
+	paths internalpath.Collection,
+	pathsToSearch []string,
+	minDepth int,
+	explain bool,
+) error {
+	reason := fmt.Sprintf(skipMinDepth, minDepth)
+
+	return filterEntries(
+		paths,
+		explain,
+		func(dir string, _ fs.DirEntry) (bool, string, error) {
+			return internalpath.Depth(dir, pathsToSearch) >= minDepth,
+				reason,
+				nil
+		},
+	)
+}
+
 // searchPaths groups the paths that will be searched and their
 // directory contents.
 func searchPaths(
-	pathsToSearch []string,
+	pathsToSearch, extFilter []string,
 	maxDepth int,
-	recursive, includeHidden bool,
+	ignore *gitignore.Matcher,
+	recursive, includeHidden, explain bool,
 ) (internalpath.Collection, error) {
 	paths := make(internalpath.Collection)
 
@@ -227,11 +916,21 @@ func searchPaths(
 		pathsToSearch = append(pathsToSearch, ".")
 	}
 
+	// keys maps the normalized form of each directory in paths to its key so
+	// that the same directory is not added twice with a different case
+	keys := make(map[string]string)
+
+	visited := make(map[string]string)
+
 	for _, path := range pathsToSearch {
 		var fileInfo os.FileInfo
 
 		path = filepath.Clean(path)
 
+		if key, ok := keys[pathKey(path)]; ok {
+			path = key
+		}
+
 		// Skip paths that have already been processed
 		if _, ok := paths[path]; ok {
 			continue
@@ -243,16 +942,49 @@ func searchPaths(
 		}
 
 		if fileInfo.IsDir() {
-			paths[path], err = os.ReadDir(path)
+			var firstVisit bool
+
+			firstVisit, err = visit(path, visited, explain)
+			if err != nil {
+				return nil, err
+			}
+
+			if !firstVisit {
+				continue
+			}
+
+			var dirEntry []fs.DirEntry
+
+			dirEntry, err = os.ReadDir(path)
 			if err != nil {
 				return nil, err
 			}
 
+			keys[pathKey(path)] = path
+			paths[path] = filterByExt(path, dirEntry, extFilter, explain)
+
+			continue
+		}
+
+		if len(extFilter) > 0 && !hasExt(fileInfo.Name(), extFilter) {
+			if explain {
+				report.Skipped(
+					path,
+					fmt.Sprintf(skipExt, strings.Join(extFilter, ", ")),
+				)
+			}
+
 			continue
 		}
 
 		dir := filepath.Dir(path)
 
+		if key, ok := keys[pathKey(dir)]; ok {
+			dir = key
+		} else {
+			keys[pathKey(dir)] = dir
+		}
+
 		var dirEntry []fs.DirEntry
 
 		dirEntry, err = os.ReadDir(dir)
@@ -262,11 +994,11 @@ func searchPaths(
 
 	entryLoop:
 		for _, entry := range dirEntry {
-			if entry.Name() == fileInfo.Name() {
+			if sameName(entry.Name(), fileInfo.Name()) {
 				// Ensure that the file is not already
 				// present in the directory entry
 				for _, e := range paths[dir] {
-					if e.Name() == fileInfo.Name() {
+					if sameName(e.Name(), entry.Name()) {
 						break entryLoop
 					}
 				}
@@ -278,8 +1010,16 @@ func searchPaths(
 		}
 	}
 
-	if recursive {
-		err := walk(paths, maxDepth, includeHidden)
+	if recursive && maxDepth != 0 {
+		err := walk(
+			paths,
+			extFilter,
+			maxDepth,
+			ignore,
+			visited,
+			includeHidden,
+			explain,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -385,33 +1125,139 @@ func Find(conf *config.Config) (internalpath.Collection, error) {
 		)
 	}
 
+	// Files may have changed since a previous renaming operation
+	hash.Reset()
+
+	appleDoubles = make(map[string]string)
+
+	var ignore *gitignore.Matcher
+	if conf.RespectGitignore {
+		ignore = gitignore.New()
+	}
+
 	paths, err := searchPaths(
 		conf.PathsToFilesOrDirs,
+		conf.ExtFilter,
 		conf.MaxDepth,
+		ignore,
 		conf.Recursive,
 		conf.IncludeHidden,
+		conf.Explain,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if ignore != nil {
+		err = filterByGitignore(
+			paths,
+			conf.PathsToFilesOrDirs,
+			ignore,
+			conf.Explain,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.MinDepth > 0 {
+		err = filterByMinDepth(
+			paths,
+			conf.PathsToFilesOrDirs,
+			conf.MinDepth,
+			conf.Explain,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = filterMatches(
 		paths,
 		conf.PathsToFilesOrDirs,
 		conf.SearchRegex,
+		conf.DirSearchRegex,
 		conf.ExcludeFilter,
+		conf.SkipConforming,
 		conf.IncludeDir,
 		conf.IncludeHidden,
 		conf.OnlyDir,
-		conf.IgnoreExt,
+		conf.FindIncludesExt,
+		conf.Explain,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if conf.HasExtRules() {
+		err = filterByExtRules(paths, conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Owner != "" || conf.Group != "" {
+		err = filterByOwner(paths, conf.Owner, conf.Group, conf.Explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.SymlinksOnly {
+		err = filterBySymlinks(paths, conf.BrokenSymlinks, conf.Explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !conf.ModifiedSince.IsZero() {
+		err = filterByModTime(paths, conf.ModifiedSince, conf.Explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Empty || conf.NonEmpty {
+		err = filterByEmptiness(paths, conf.NonEmpty, conf.Explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The files are hashed after the cheaper filters
+	// have narrowed down the entries
+	if conf.HashInList != nil || conf.HashNotInList != nil {
+		err = filterByHashes(paths, conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The mappings are applied last so that those that only match
+	// the paths left out by the other filters are reported
+	if conf.MapFile != "" {
+		err = filterByMappings(paths, conf.Mappings, conf.Explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.WithXattrs {
+		err = pairAppleDoubles(paths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return paths, nil
 }
 
 func GetCSVRows() map[string][]string {
 	return csvRows
 }
+
+// GetAppleDoubles returns the name of the AppleDouble file of each
+// match that has one (see --with-xattrs).
+func GetAppleDoubles() map[string]string {
+	return appleDoubles
+}
@@ -3,9 +3,88 @@
 
 package find
 
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+var errUnknownOwner = errors.New(
+	"Invalid argument: --owner must be an existing user name or a numeric id, got '%s'",
+)
+
+var errUnknownGroup = errors.New(
+	"Invalid argument: --group must be an existing group name or a numeric id, got '%s'",
+)
+
 // isHidden checks if a file is hidden on Unix operating systems
 // the nil error is returned to match the signature of the Windows
 // version of the function.
 func isHidden(filename, baseDir string) (bool, error) {
 	return filename[0] == dotCharacter, nil
 }
+
+// lookupOwner resolves the user and group (specified by name or numeric id)
+// to their ids. An id of -1 is returned for an unspecified user or group.
+func lookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+
+	if owner != "" {
+		uid, err = strconv.Atoi(owner)
+		if err != nil {
+			u, lookupErr := user.Lookup(owner)
+			if lookupErr != nil {
+				return uid, gid, fmt.Errorf(errUnknownOwner.Error(), owner)
+			}
+
+			uid, err = strconv.Atoi(u.Uid)
+			if err != nil {
+				return uid, gid, err
+			}
+		}
+	}
+
+	if group != "" {
+		gid, err = strconv.Atoi(group)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(group)
+			if lookupErr != nil {
+				return uid, gid, fmt.Errorf(errUnknownGroup.Error(), group)
+			}
+
+			gid, err = strconv.Atoi(g.Gid)
+			if err != nil {
+				return uid, gid, err
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// isOwnedBy reports whether the entry is owned by the specified user and
+// group ids. An id of -1 matches any user or group.
+func isOwnedBy(entry fs.DirEntry, uid, gid int) (bool, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return false, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true, nil
+	}
+
+	if uid != -1 && int(stat.Uid) != uid {
+		return false, nil
+	}
+
+	if gid != -1 && int(stat.Gid) != gid {
+		return false, nil
+	}
+
+	return true, nil
+}
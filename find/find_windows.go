@@ -4,6 +4,7 @@
 package find
 
 import (
+	"io/fs"
 	"path/filepath"
 	"syscall"
 )
@@ -38,3 +39,15 @@ func isHidden(filename, baseDir string) (bool, error) {
 
 	return attributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0, nil
 }
+
+// lookupOwner is a no-op on Windows since files are not filtered by their
+// owner. It returns -1 for both ids to indicate that any owner matches.
+func lookupOwner(_, _ string) (uid, gid int, err error) {
+	return -1, -1, nil
+}
+
+// isOwnedBy always reports true on Windows since ownership is not
+// represented by user and group ids.
+func isOwnedBy(_ fs.DirEntry, _, _ int) (bool, error) {
+	return true, nil
+}
@@ -6,6 +6,7 @@ require (
 	github.com/adrg/xdg v0.4.0
 	github.com/barasher/go-exiftool v1.8.0
 	github.com/dhowden/tag v0.0.0-20220618230019-adf36e896086
+	github.com/dlclark/regexp2 v1.10.0
 	github.com/google/go-cmp v0.5.9
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 	github.com/pterm/pterm v0.12.46
@@ -19,6 +20,7 @@ require (
 require (
 	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de
 	github.com/davecgh/go-spew v1.1.1
+	github.com/rivo/uniseg v0.4.2
 	github.com/sebdah/goldie/v2 v2.5.3
 	golang.org/x/exp v0.0.0-20221028150844-83b7d23a625f
 )
@@ -32,7 +34,6 @@ require (
 	github.com/lithammer/fuzzysearch v1.1.5 // indirect
 	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rivo/uniseg v0.4.2 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
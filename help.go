@@ -63,6 +63,9 @@ func envHelp() string {
       For example, you can enable execute mode and ignore file extensions by default:
       'export F2_DEFAULT_OPTS=--exec --ignore-ext'.
 
+  F2_MANIFEST: the path to a manifest file that records every renaming operation.
+      It is equivalent to the --manifest flag.
+
   F2_NO_COLOR, NO_COLOR: set to any value to disable coloured output.
 
   F2_UPDATE_NOTIFIER: set to any value to periodically check for updates.`
@@ -4,74 +4,348 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	shellquote "github.com/kballard/go-shellquote"
 	"github.com/urfave/cli/v2"
+
+	"github.com/ayoisaiah/f2/internal/conflict"
+	"github.com/ayoisaiah/f2/internal/hash"
+	"github.com/ayoisaiah/f2/internal/pattern"
+	"github.com/ayoisaiah/f2/internal/ratelimit"
 )
 
 var (
 	errInvalidArgument = errors.New(
-		"Invalid argument: one of `-f`, `-r`, `-csv` or `-u` must be present and set to a non empty string value. Use 'f2 --help' for more information",
+		"Invalid argument: one of `-f`, `-r`, `-csv`, `-u`, `--undo-dry-run`, `--undo-file`, `--count`, `--find-duplicate-names`, `--stdin-names`, `--edit`, `--pipeline`, `--map-file` or `--print-config` must be present and set to a non empty string value unless the config file has extension rules. Use 'f2 --help' for more information",
 	)
 
 	errInvalidSimpleModeArgs = errors.New(
 		"At least one argument must be specified in simple mode",
 	)
+
+	errInvalidOverwritePolicy = errors.New(
+		"Invalid argument: --overwrite-if must be one of 'always', 'newer' or 'larger'",
+	)
+
+	errInvalidOutputFormat = errors.New(
+		"Invalid argument: --output-format must be one of 'table', 'tree', 'json' or 'html'",
+	)
+
+	errInvalidRegexEngine = errors.New(
+		"Invalid argument: --regex-engine must be one of 're2' or 'pcre'",
+	)
+
+	errInvalidTrimTo = errors.New(
+		"Invalid argument: --trim-to must be a positive integer",
+	)
+
+	errInvalidCommand = errors.New(
+		"Invalid argument: --%s must be a command: %v",
+	)
+
+	errInvalidSetMtimeFrom = errors.New(
+		"Invalid argument: --set-mtime-from must be in the form REGEX:LAYOUT",
+	)
+
+	errInvalidDirMode = errors.New(
+		"Invalid argument: --dir-mode must be an octal permission between 0 and 0777",
+	)
+
+	errInvalidSeparator = errors.New(
+		"Invalid argument: --normalize-separators-to must be a single character other than a path separator",
+	)
+
+	errNormalizeWithFind = errors.New(
+		"Invalid argument: --normalize-separators-to cannot be combined with -f/--find, -r/--replace or --pipeline",
+	)
+
+	errMapFileWithFind = errors.New(
+		"Invalid argument: --map-file cannot be combined with -f/--find, -r/--replace, --csv, --pipeline or --stdin-names",
+	)
+
+	errInvalidMapFile = errors.New(
+		"Invalid map file '%s': line %d must be in the form SOURCE=TARGET",
+	)
+
+	errDirsFirstAndLast = errors.New(
+		"Invalid argument: --dirs-first and --dirs-last cannot be used together",
+	)
+
+	errEmptyAndNonEmpty = errors.New(
+		"Invalid argument: --empty and --non-empty cannot be used together",
+	)
+
+	errInvalidRate = errors.New(
+		"Invalid argument: --rate must be a positive integer",
+	)
+
+	errInvalidWorkers = errors.New(
+		"Invalid argument: --workers must be a positive integer",
+	)
+
+	errInvalidStartStep = errors.New(
+		"Invalid argument: --start-step must be in the form START:STEP (either may be omitted), got '%s'",
+	)
+
+	errInvalidReplaceLimit = errors.New(
+		"Invalid argument: --replace-limit must be an integer or in the form START:COUNT where START is a non-zero integer and COUNT is a positive integer, got '%s'",
+	)
+
+	errInvalidStatMaxBytes = errors.New(
+		"Invalid argument: --stat-max-bytes must be a positive integer",
+	)
+
+	errExecForbidden = errors.New(
+		"Refusing to commit the renaming operation because --safe (or F2_FORBID_EXEC) is set. Remove -x/--exec to preview the changes",
+	)
+
+	errInvalidMaxDepth = errors.New(
+		"Invalid argument: --max-depth must be a non-negative integer, -1, or 'unlimited'",
+	)
+
+	errInvalidMinDepth = errors.New(
+		"Invalid argument: --min-depth and --depth must be non-negative integers that do not exceed --max-depth",
+	)
+)
+
+const (
+	// UnlimitedDepth indicates that a recursive search should traverse
+	// directories without a depth limit.
+	UnlimitedDepth = -1
+
+	unlimitedDepthArg = "unlimited"
+)
+
+// Policies that determine when an existing path may be overwritten.
+const (
+	OverwriteAlways = "always"
+	OverwriteNewer  = "newer"  // only if the source is newer than the target
+	OverwriteLarger = "larger" // only if the source is larger than the target
+)
+
+// Formats in which the renaming operation may be reported.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatHTML  = "html"
+	FormatTree  = "tree"
 )
 
 var conf *Config
 
+// PatternError is returned when a find or exclude pattern cannot be
+// compiled into a regular expression.
+type PatternError struct {
+	Err     error
+	Kind    string // find, find-dir, pipeline, exclude or skip-conforming
+	Pattern string
+	Index   int // position of the pattern in the command (starting from 1)
+}
+
+func (e *PatternError) Error() string {
+	msg := fmt.Sprintf(
+		"Invalid %s pattern #%d '%s'",
+		e.Kind,
+		e.Index,
+		e.Pattern,
+	)
+
+	var syntaxErr *syntax.Error
+	if errors.As(e.Err, &syntaxErr) {
+		msg += ": " + string(syntaxErr.Code)
+
+		if pos := strings.Index(e.Pattern, syntaxErr.Expr); pos >= 0 &&
+			syntaxErr.Expr != "" {
+			msg += fmt.Sprintf(
+				" at position %d (%s)",
+				utf8.RuneCountInString(e.Pattern[:pos])+1,
+				syntaxErr.Expr,
+			)
+		}
+	} else {
+		msg += ": " + e.Err.Error()
+	}
+
+	if e.Kind == "find" || e.Kind == "find-dir" || e.Kind == "pipeline" {
+		msg += ". Use -s/--string-mode to match the pattern literally"
+	}
+
+	return msg
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Err
+}
+
 // Config represents the program configuration.
 type Config struct {
-	Date               time.Time
-	Stdin              io.Reader
-	Stderr             io.Writer
-	Stdout             io.Writer
-	SearchRegex        *regexp.Regexp
-	CSVFilename        string
-	Sort               string
-	Replacement        string
-	WorkingDir         string
-	FindSlice          []string
-	ExcludeFilter      []string
-	ReplacementSlice   []string
-	PathsToFilesOrDirs []string
-	NumberOffset       []int
-	MaxDepth           int
-	StartNumber        int
-	ReplaceLimit       int
-	Recursive          bool
-	IgnoreCase         bool
-	ReverseSort        bool
-	OnlyDir            bool
-	Revert             bool
-	IncludeDir         bool
-	IgnoreExt          bool
-	AllowOverwrites    bool
-	Verbose            bool
-	IncludeHidden      bool
-	Quiet              bool
-	AutoFixConflicts   bool
-	Exec               bool
-	StringLiteralMode  bool
-	SimpleMode         bool
-	JSON               bool
+	Date               time.Time                 `json:"-"`
+	ModifiedSince      time.Time                 `json:"modified_since"`
+	Stdin              io.Reader                 `json:"-"`
+	Stderr             io.Writer                 `json:"-"`
+	Warnings           []string                  `json:"-"` // reported once the output is set up
+	Stdout             io.Writer                 `json:"-"`
+	RateLimiter        *ratelimit.Limiter        `json:"-"`
+	HashInList         *hash.List                `json:"-"`
+	HashNotInList      *hash.List                `json:"-"`
+	SearchRegex        pattern.Regexp            `json:"-"`
+	DirSearchRegex     pattern.Regexp            `json:"-"`
+	MtimeRegex         *regexp.Regexp            `json:"-"`
+	ReplaceIfRegex     *regexp.Regexp            `json:"-"`
+	DirMode            os.FileMode               `json:"dir_mode"`
+	CSVFilename        string                    `json:"csv_filename"`
+	ConfigFile         string                    `json:"config_file"`
+	CounterFile        string                    `json:"counter_file"`
+	DirConfigFile      string                    `json:"dir_config_file"`
+	EmptyNameFallback  string                    `json:"empty_name_fallback"`
+	Manifest           string                    `json:"manifest"`
+	ApplyPlan          string                    `json:"apply_plan"`
+	MtimePattern       string                    `json:"mtime_pattern"`
+	MtimeLayout        string                    `json:"mtime_layout"`
+	OutputFormat       string                    `json:"output_format"`
+	RegexEngine        string                    `json:"regex_engine"`
+	Owner              string                    `json:"owner"`
+	Group              string                    `json:"group"`
+	ProtectPrefix      string                    `json:"protect_prefix"`
+	ProtectSuffix      string                    `json:"protect_suffix"`
+	SkipConforming     string                    `json:"skip_conforming"`
+	UndoFile           string                    `json:"undo_file"`
+	OverwriteIf        string                    `json:"overwrite_if"`
+	ReplaceIf          string                    `json:"replace_if"`
+	OrderFile          string                    `json:"order_file"`
+	MapFile            string                    `json:"map_file"`
+	StartStep          string                    `json:"start_step"`
+	HashIn             string                    `json:"hash_in"`
+	HashNotIn          string                    `json:"hash_not_in"`
+	Sort               string                    `json:"sort"`
+	Replacement        string                    `json:"-"`
+	WorkingDir         string                    `json:"working_dir"`
+	FindSlice          []string                  `json:"find"`
+	ExecAfter          []string                  `json:"exec_after"`
+	ValidateWith       []string                  `json:"validate_with"`
+	ExcludeFilter      []string                  `json:"exclude"`
+	ProtectedPaths     []string                  `json:"protected_paths"`
+	ExtRules           []ExtRule                 `json:"ext_rules"`
+	Pipelines          map[string][]PipelineStep `json:"pipelines"`
+	Mappings           map[string]string         `json:"mappings"`
+	ExtFilter          []string                  `json:"ext"`
+	Order              []string                  `json:"order"`
+	ReplacementSlice   []string                  `json:"replace"`
+	FindDirSlice       []string                  `json:"find_dir"`
+	ReplaceDirSlice    []string                  `json:"replace_dir"`
+	PathsToFilesOrDirs []string                  `json:"paths"`
+	NumberOffset       []int                     `json:"-"`
+	NoFix              map[conflict.Name]bool    `json:"no_fix"`
+	MaxDepth           int                       `json:"max_depth"`
+	MinDepth           int                       `json:"min_depth"`
+	StartNumber        int                       `json:"start_number"`
+	NumberStep         int                       `json:"number_step"`
+	CounterValue       int                       `json:"-"`
+	ReplaceLimit       int                       `json:"replace_limit"`
+	ReplaceStart       int                       `json:"replace_start"`
+	TrimTo             int                       `json:"trim_to"`
+	Workers            int                       `json:"workers"`
+	Rate               int                       `json:"rate"`
+	Safe               bool                      `json:"safe"`
+	Force              bool                      `json:"force"`
+	BackupFallback     bool                      `json:"backup_fallback"`
+	Yes                bool                      `json:"yes"`
+	StatMaxBytes       int64                     `json:"stat_max_bytes"`
+	Recursive          bool                      `json:"recursive"`
+	IgnoreCase         bool                      `json:"ignore_case"`
+	ReverseSort        bool                      `json:"reverse_sort"`
+	DirsFirst          bool                      `json:"dirs_first"`
+	DirsLast           bool                      `json:"dirs_last"`
+	OnlyDir            bool                      `json:"only_dir"`
+	Revert             bool                      `json:"revert"`
+	RestoreTimes       bool                      `json:"restore_times"`
+	SavePlan           bool                      `json:"save_plan"`
+	SinceLastRun       bool                      `json:"since_last_run"`
+	Count              bool                      `json:"count"`
+	FindDuplicateNames bool                      `json:"find_duplicate_names"`
+	SymlinksOnly       bool                      `json:"symlinks_only"`
+	BrokenSymlinks     bool                      `json:"broken_symlinks"`
+	Empty              bool                      `json:"empty"`
+	NonEmpty           bool                      `json:"non_empty"`
+	RespectGitignore   bool                      `json:"respect_gitignore"`
+	WithXattrs         bool                      `json:"with_xattrs"`
+	StdinNames         bool                      `json:"stdin_names"`
+	Edit               bool                      `json:"edit"`
+	IncludeDir         bool                      `json:"include_dir"`
+	IgnoreExt          bool                      `json:"ignore_ext"`
+	FindIncludesExt    bool                      `json:"find_includes_ext"`
+	IndexPerRoot       bool                      `json:"index_per_root"`
+	Unaccent           bool                      `json:"unaccent"`
+	PreserveSubdirs    bool                      `json:"preserve_subdirs"`
+	AllowMove          bool                      `json:"allow_move"`
+	AllowOverwrites    bool                      `json:"allow_overwrites"`
+	Verbose            bool                      `json:"verbose"`
+	Explain            bool                      `json:"explain"`
+	IncludeHidden      bool                      `json:"include_hidden"`
+	Quiet              bool                      `json:"quiet"`
+	AutoFixConflicts   bool                      `json:"auto_fix_conflicts"`
+	FailFast           bool                      `json:"fail_fast"`
+	ExecAfterFailFast  bool                      `json:"exec_after_fail_fast"`
+	Exec               bool                      `json:"exec"`
+	StringLiteralMode  bool                      `json:"string_literal_mode"`
+	SimpleMode         bool                      `json:"simple_mode"`
+	JSON               bool                      `json:"json"`
+	ShowIndex          bool                      `json:"show_index"`
+	JSONStream         bool                      `json:"json_stream"`
+	LongPaths          bool                      `json:"long_paths"`
+	DefaultStem        bool                      `json:"default_stem"`
+	PrintConfig        bool                      `json:"-"`
+	defaultFind        bool                      // the current find pattern is the implicit default
 }
 
 // SetFindStringRegex compiles a regular expression for the
 // find string of the corresponding replacement index (if any).
-// Otherwise, the created regex will match the entire file name.
+// Otherwise, the created regex will match the entire file name
+// (or only its stem if --default-stem is set).
 func (c *Config) SetFindStringRegex(replacementIndex int) error {
+	re, err := c.findRegex(c.FindSlice, replacementIndex, "find")
+	if err != nil {
+		return err
+	}
+
+	c.SearchRegex = re
+	c.defaultFind = len(c.FindSlice) <= replacementIndex
+
+	return nil
+}
+
+// StemOnly reports whether the current find pattern is matched against the
+// file name without its extension, in which case the original extension is
+// reattached to the new name.
+func (c *Config) StemOnly() bool {
+	return !c.FindIncludesExt || c.DefaultStem && c.defaultFind
+}
+
+// findRegex compiles the pattern at the specified index of findSlice.
+// The entire file name is matched if there is no pattern at the index.
+//
+//nolint:ireturn // the engine is only known at runtime
+func (c *Config) findRegex(
+	findSlice []string,
+	replacementIndex int,
+	kind string,
+) (pattern.Regexp, error) {
 	// findPattern is set to match the entire file name by default
 	// except if a find string for the corresponding replacement index
 	// is found
 	findPattern := ".*"
-	if len(c.FindSlice) > replacementIndex {
-		findPattern = c.FindSlice[replacementIndex]
+	if len(findSlice) > replacementIndex {
+		findPattern = findSlice[replacementIndex]
 
 		// Escape all regular expression metacharacters in string literal mode
 		if c.StringLiteralMode {
@@ -83,32 +357,243 @@ func (c *Config) SetFindStringRegex(replacementIndex int) error {
 		}
 	}
 
-	re, err := regexp.Compile(findPattern)
+	re, err := pattern.Compile(findPattern, c.RegexEngine)
+	if err != nil {
+		return nil, &PatternError{
+			Err:     err,
+			Kind:    kind,
+			Pattern: findSlice[replacementIndex],
+			Index:   replacementIndex + 1,
+		}
+	}
+
+	return re, nil
+}
+
+// HasDirReplacement reports whether directories are renamed with
+// a different find and replace chain from files.
+func (c *Config) HasDirReplacement() bool {
+	return len(c.FindDirSlice) > 0 || len(c.ReplaceDirSlice) > 0
+}
+
+// DirConfig returns a copy of the configuration whose find and replace chain
+// is the one that applies to directories.
+func (c *Config) DirConfig() (*Config, error) {
+	dirConf := *c
+	dirConf.FindSlice = c.FindDirSlice
+	dirConf.ReplacementSlice = c.ReplaceDirSlice
+	dirConf.FindDirSlice = nil
+	dirConf.ReplaceDirSlice = nil
+
+	err := dirConf.SetFindStringRegex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirConf, nil
+}
+
+// setConfigFile loads the extension rules and pipelines from the config
+// file specified on the command line or the one in the user's config
+// directory (if any). Those in the `.f2.json` file of the current
+// directory are added with a higher precedence.
+func (c *Config) setConfigFile(ctx *cli.Context) error {
+	c.ConfigFile = ctx.String("config")
+	if c.ConfigFile == "" {
+		c.ConfigFile = defaultConfigFile()
+	}
+
+	if c.ConfigFile != "" {
+		f, err := loadConfigFile(c.ConfigFile)
+		if err != nil {
+			return err
+		}
+
+		c.ExtRules = f.Rules
+		c.Pipelines = f.Pipelines
+		c.ProtectedPaths = f.ProtectedPaths
+	}
+
+	if _, err := os.Stat(dirConfigFile); err != nil {
+		return nil
+	}
+
+	dirConfigPath, err := filepath.Abs(dirConfigFile)
 	if err != nil {
 		return err
 	}
 
-	c.SearchRegex = re
+	f, err := loadConfigFile(dirConfigPath)
+	if err != nil {
+		return err
+	}
+
+	c.DirConfigFile = dirConfigPath
+	c.mergeDirConfig(f)
 
 	return nil
 }
 
 func (c *Config) setOptions(ctx *cli.Context) error {
+	err := c.setConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+
 	if len(ctx.StringSlice("find")) == 0 &&
 		len(ctx.StringSlice("replace")) == 0 &&
+		len(ctx.StringSlice("find-dir")) == 0 &&
+		len(ctx.StringSlice("replace-dir")) == 0 &&
 		ctx.String("csv") == "" &&
-		!ctx.Bool("undo") {
+		!ctx.Bool("undo") &&
+		!ctx.Bool("undo-dry-run") &&
+		ctx.String("undo-file") == "" &&
+		ctx.String("apply-plan") == "" &&
+		ctx.String("pipeline") == "" &&
+		ctx.String("normalize-separators-to") == "" &&
+		!ctx.Bool("count") &&
+		!ctx.Bool("find-duplicate-names") &&
+		!ctx.Bool("stdin-names") &&
+		!ctx.Bool("edit") &&
+		!ctx.Bool("print-config") &&
+		ctx.String("map-file") == "" &&
+		len(c.ExtRules) == 0 {
 		return errInvalidArgument
 	}
 
 	c.FindSlice = ctx.StringSlice("find")
 	c.ReplacementSlice = ctx.StringSlice("replace")
+	c.FindDirSlice = ctx.StringSlice("find-dir")
+	c.ReplaceDirSlice = ctx.StringSlice("replace-dir")
+
+	if ctx.String("pipeline") != "" {
+		err = c.setPipeline(ctx.String("pipeline"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.IsSet("normalize-separators-to") {
+		err = c.setNormalizeSeparators(ctx.String("normalize-separators-to"))
+		if err != nil {
+			return err
+		}
+	}
+
 	c.CSVFilename = ctx.String("csv")
-	c.Revert = ctx.Bool("undo")
+	c.UndoFile = ctx.String("undo-file")
+	c.Revert = ctx.Bool("undo") || ctx.Bool("undo-dry-run") ||
+		c.UndoFile != ""
+	c.RestoreTimes = ctx.Bool("restore-times")
+	c.Count = ctx.Bool("count")
+	c.FindDuplicateNames = ctx.Bool("find-duplicate-names")
+	c.StdinNames = ctx.Bool("stdin-names")
+	c.Edit = ctx.Bool("edit")
+	c.Manifest = ctx.String("manifest")
+	c.ApplyPlan = ctx.String("apply-plan")
+	c.SinceLastRun = ctx.Bool("since-last-run")
+	c.CounterFile = ctx.String("counter-file")
+	c.PrintConfig = ctx.Bool("print-config")
+
+	if ctx.String("map-file") != "" {
+		err = c.setMapFile(ctx.String("map-file"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("hash-in") != "" {
+		c.HashIn = ctx.String("hash-in")
+
+		c.HashInList, err = hash.ReadList(c.HashIn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("hash-not-in") != "" {
+		c.HashNotIn = ctx.String("hash-not-in")
+
+		c.HashNotInList, err = hash.ReadList(c.HashNotIn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("start-step") != "" {
+		err = c.setStartStep(ctx.String("start-step"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("replace-if") != "" {
+		err = c.setReplaceIf(ctx.String("replace-if"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("exec-after") != "" {
+		c.ExecAfter, err = splitCommand("exec-after", ctx.String("exec-after"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.String("validate-with") != "" {
+		c.ValidateWith, err = splitCommand(
+			"validate-with",
+			ctx.String("validate-with"),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.ExecAfterFailFast = ctx.Bool("exec-after-fail-fast")
+
+	if ctx.String("set-mtime-from") != "" {
+		err = c.setMtimeFrom(ctx.String("set-mtime-from"))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.setOrder(ctx)
+	if err != nil {
+		return err
+	}
 	c.PathsToFilesOrDirs = ctx.Args().Slice()
-	c.Exec = ctx.Bool("exec")
+	c.Exec = ctx.Bool("exec") || c.ApplyPlan != ""
 
-	c.setDefaultOpts(ctx)
+	// Previewing an undo must never modify the filesystem even if -x is
+	// set explicitly or through the default options
+	if ctx.Bool("undo-dry-run") {
+		c.Exec = false
+	}
+
+	err = c.setDefaultOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A plan is committed exactly as it was reviewed so conflicts are
+	// reported instead of being fixed
+	if c.ApplyPlan != "" {
+		c.AutoFixConflicts = false
+	}
+
+	// Every step of a pipeline is compiled upfront so that an invalid
+	// pattern is reported before any file is matched
+	if ctx.String("pipeline") != "" {
+		for i := range c.FindSlice {
+			_, err = c.findRegex(c.FindSlice, i, "pipeline")
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	// Ensure that each findString has a corresponding replacement.
 	// The replacement defaults to an empty string if unset
@@ -116,9 +601,180 @@ func (c *Config) setOptions(ctx *cli.Context) error {
 		c.ReplacementSlice = append(c.ReplacementSlice, "")
 	}
 
+	for len(c.FindDirSlice) > len(c.ReplaceDirSlice) {
+		c.ReplaceDirSlice = append(c.ReplaceDirSlice, "")
+	}
+
+	if c.HasDirReplacement() {
+		// Directories must be included for their replacement to apply
+		c.IncludeDir = true
+
+		// Only directories are renamed if there is no replacement for files
+		if len(c.FindSlice) == 0 && len(c.ReplacementSlice) == 0 {
+			c.OnlyDir = true
+		}
+
+		// Compile every pattern upfront so that invalid ones are
+		// reported as directory patterns
+		for i := range c.FindDirSlice {
+			_, err = c.findRegex(c.FindDirSlice, i, "find-dir")
+			if err != nil {
+				return err
+			}
+		}
+
+		c.DirSearchRegex, err = c.findRegex(c.FindDirSlice, 0, "find-dir")
+		if err != nil {
+			return err
+		}
+	}
+
 	return c.SetFindStringRegex(0)
 }
 
+// splitCommand splits the command provided to the flag into its arguments
+// with the quoting rules of a POSIX shell.
+func splitCommand(flag, command string) ([]string, error) {
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return nil, fmt.Errorf(errInvalidCommand.Error(), flag, err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf(
+			errInvalidCommand.Error(),
+			flag,
+			"the command is empty",
+		)
+	}
+
+	return args, nil
+}
+
+// setMtimeFrom parses the value of --set-mtime-from which is split at the
+// last colon into a regular expression that finds the date in the new name
+// and the layout that the date is parsed with.
+func (c *Config) setMtimeFrom(value string) error {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 || i == len(value)-1 {
+		return errInvalidSetMtimeFrom
+	}
+
+	re, err := regexp.Compile(value[:i])
+	if err != nil {
+		return err
+	}
+
+	c.MtimeRegex = re
+	c.MtimePattern = value[:i]
+	c.MtimeLayout = value[i+1:]
+
+	return nil
+}
+
+// setStartStep parses the value of --start-step which sets the start
+// number and the step of the indexing variables that do not specify their
+// own. It is in the form START:STEP and a missing part defaults to 1.
+func (c *Config) setStartStep(value string) error {
+	start, step, _ := strings.Cut(value, ":")
+
+	c.StartNumber, c.NumberStep = 1, 1
+
+	var err error
+
+	if start != "" {
+		c.StartNumber, err = strconv.Atoi(start)
+		if err != nil {
+			return fmt.Errorf(errInvalidStartStep.Error(), value)
+		}
+	}
+
+	if step != "" {
+		c.NumberStep, err = strconv.Atoi(step)
+		if err != nil {
+			return fmt.Errorf(errInvalidStartStep.Error(), value)
+		}
+	}
+
+	c.StartStep = value
+
+	return nil
+}
+
+// setReplaceLimit parses the value of --replace-limit. It is either the
+// number of matches to replace (counting from the end if it is negative) or
+// in the form START:COUNT to replace COUNT matches starting from the one at
+// position START. A negative START counts from the end so that `-1:1`
+// replaces only the last match. ReplaceStart is zero for the first form.
+func (c *Config) setReplaceLimit(value string) error {
+	start, count, found := strings.Cut(value, ":")
+
+	var err error
+
+	if !found {
+		c.ReplaceLimit, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf(errInvalidReplaceLimit.Error(), value)
+		}
+
+		return nil
+	}
+
+	c.ReplaceStart, err = strconv.Atoi(start)
+	if err != nil || c.ReplaceStart == 0 {
+		return fmt.Errorf(errInvalidReplaceLimit.Error(), value)
+	}
+
+	c.ReplaceLimit, err = strconv.Atoi(count)
+	if err != nil || c.ReplaceLimit < 1 {
+		return fmt.Errorf(errInvalidReplaceLimit.Error(), value)
+	}
+
+	return nil
+}
+
+// separatorsPattern matches each run of the separators
+// that --normalize-separators-to replaces.
+const separatorsPattern = "[ _-]+"
+
+// setNormalizeSeparators sets up a find and replace pair that
+// replaces each run of separators with the provided character.
+func (c *Config) setNormalizeSeparators(sep string) error {
+	if len(c.FindSlice) > 0 || len(c.ReplacementSlice) > 0 {
+		return errNormalizeWithFind
+	}
+
+	if utf8.RuneCountInString(sep) != 1 || sep == "/" || sep == `\` {
+		return errInvalidSeparator
+	}
+
+	c.FindSlice = []string{separatorsPattern}
+
+	// The separator is used literally in the replacement
+	c.ReplacementSlice = []string{strings.ReplaceAll(sep, "$", "$$")}
+
+	return nil
+}
+
+// setReplaceIf compiles the pattern that the original name of a file
+// must match for the replacement to be applied to it.
+func (c *Config) setReplaceIf(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return &PatternError{
+			Err:     err,
+			Kind:    "replace-if",
+			Pattern: value,
+			Index:   1,
+		}
+	}
+
+	c.ReplaceIf = value
+	c.ReplaceIfRegex = re
+
+	return nil
+}
+
 // setSimpleModeOptions is used to set the options for the
 // renaming operation in simpleMode.
 func (c *Config) setSimpleModeOptions(ctx *cli.Context) error {
@@ -142,7 +798,10 @@ func (c *Config) setSimpleModeOptions(ctx *cli.Context) error {
 	c.FindSlice = []string{args[0]}
 	c.ReplacementSlice = []string{args[1]}
 
-	c.setDefaultOpts(ctx)
+	err := c.setDefaultOpts(ctx)
+	if err != nil {
+		return err
+	}
 
 	c.IncludeDir = true
 
@@ -153,24 +812,234 @@ func (c *Config) setSimpleModeOptions(ctx *cli.Context) error {
 	return c.SetFindStringRegex(0)
 }
 
+// setMaxDepth parses the value of the --max-depth flag. It accepts a
+// non-negative integer, or `-1` and `unlimited` for searches without a
+// depth limit.
+func (c *Config) setMaxDepth(ctx *cli.Context) error {
+	value := strings.TrimSpace(ctx.String("max-depth"))
+
+	if strings.EqualFold(value, unlimitedDepthArg) {
+		c.MaxDepth = UnlimitedDepth
+		return nil
+	}
+
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < UnlimitedDepth {
+		return errInvalidMaxDepth
+	}
+
+	c.MaxDepth = depth
+
+	// A depth of 0 used to indicate an unlimited recursive search
+	if depth == 0 && c.Recursive && !c.Quiet {
+		c.Warnings = append(
+			c.Warnings,
+			"--max-depth 0 limits the search to the current level. Use '--max-depth unlimited' (or -1) to search without a depth limit",
+		)
+	}
+
+	return nil
+}
+
+// setMinDepth parses the value of the --min-depth and --depth flags. Since
+// --depth matches entries at exactly the specified depth, it sets both the
+// minimum and maximum depth. A minimum depth implies a recursive search.
+func (c *Config) setMinDepth(ctx *cli.Context) error {
+	c.MinDepth = ctx.Int("min-depth")
+
+	if ctx.IsSet("depth") {
+		c.MinDepth = ctx.Int("depth")
+		c.MaxDepth = c.MinDepth
+	}
+
+	if c.MinDepth < 0 ||
+		c.MaxDepth != UnlimitedDepth && c.MinDepth > c.MaxDepth {
+		return errInvalidMinDepth
+	}
+
+	if c.MinDepth > 0 {
+		c.Recursive = true
+	}
+
+	return nil
+}
+
+// setOrder reads the file provided to the --order-file flag. Each non-empty
+// line is either a file name or a path relative to the order file.
+func (c *Config) setOrder(ctx *cli.Context) error {
+	c.OrderFile = ctx.String("order-file")
+	if c.OrderFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(c.OrderFile)
+	if err != nil {
+		return err
+	}
+
+	orderFileDir, err := filepath.Abs(filepath.Dir(c.OrderFile))
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		entry := strings.TrimSpace(line)
+		if entry == "" {
+			continue
+		}
+
+		if strings.ContainsRune(filepath.ToSlash(entry), '/') {
+			entry = filepath.Join(orderFileDir, entry)
+		}
+
+		c.Order = append(c.Order, entry)
+	}
+
+	return nil
+}
+
+// setMapFile reads the file provided to the --map-file flag. Each non-empty
+// line maps a file name to its new name and the two are separated by the
+// first `=` (surrounding whitespace is ignored). Lines that start with `#`
+// are comments and a later mapping of the same name overrides an earlier
+// one.
+func (c *Config) setMapFile(mapFile string) error {
+	if len(c.FindSlice) > 0 || len(c.ReplacementSlice) > 0 ||
+		c.CSVFilename != "" || c.StdinNames {
+		return errMapFileWithFind
+	}
+
+	b, err := os.ReadFile(mapFile)
+	if err != nil {
+		return err
+	}
+
+	c.MapFile = mapFile
+	c.Mappings = make(map[string]string)
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		source, target, found := strings.Cut(line, "=")
+
+		source = strings.TrimSpace(source)
+		if !found || source == "" {
+			return fmt.Errorf(errInvalidMapFile.Error(), mapFile, i+1)
+		}
+
+		c.Mappings[source] = strings.TrimSpace(target)
+	}
+
+	return nil
+}
+
+// setDirMode parses the octal permission bits that are used for
+// the directories created during a renaming operation.
+func (c *Config) setDirMode(mode string) error {
+	mode = strings.TrimPrefix(strings.ToLower(mode), "0o")
+
+	//nolint:gomnd // octal permission bits
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > uint64(os.ModePerm) {
+		return errInvalidDirMode
+	}
+
+	c.DirMode = os.FileMode(perm)
+
+	return nil
+}
+
 // setDefaultOpts applies the options that may be set through
 // F2_DEFAULT_OPTS.
-func (c *Config) setDefaultOpts(ctx *cli.Context) {
+func (c *Config) setDefaultOpts(ctx *cli.Context) error {
 	c.AutoFixConflicts = ctx.Bool("fix-conflicts")
+	c.NoFix = map[conflict.Name]bool{
+		conflict.InvalidCharacters:         ctx.Bool("no-fix-chars"),
+		conflict.FileExists:                ctx.Bool("no-fix-exists"),
+		conflict.MaxFilenameLengthExceeded: ctx.Bool("no-fix-length"),
+		conflict.TrailingPeriod:            ctx.Bool("no-fix-period"),
+	}
+	c.FailFast = ctx.Bool("fail-fast")
 	c.IncludeDir = ctx.Bool("include-dir")
 	c.IncludeHidden = ctx.Bool("hidden")
 	c.IgnoreCase = ctx.Bool("ignore-case")
 	c.IgnoreExt = ctx.Bool("ignore-ext")
+
+	// The extension is searched unless it is ignored, but this
+	// can be overridden independently of --ignore-ext
+	c.FindIncludesExt = !c.IgnoreExt
+	if ctx.IsSet("find-includes-ext") {
+		c.FindIncludesExt = ctx.Bool("find-includes-ext")
+	}
+
+	c.DefaultStem = ctx.Bool("default-stem")
+	c.IndexPerRoot = ctx.Bool("index-per-root")
+	c.Unaccent = ctx.Bool("unaccent")
+	c.Workers = ctx.Int("workers")
+	c.Rate = ctx.Int("rate")
+	c.StatMaxBytes = ctx.Int64("stat-max-bytes")
+	c.PreserveSubdirs = ctx.Bool("preserve-subdir-structure")
+	c.AllowMove = ctx.Bool("allow-move")
 	c.Recursive = ctx.Bool("recursive")
 	c.OnlyDir = ctx.Bool("only-dir")
 	c.StringLiteralMode = ctx.Bool("string-mode")
 	c.ExcludeFilter = ctx.StringSlice("exclude")
-	c.MaxDepth = int(ctx.Uint("max-depth"))
+	c.SkipConforming = ctx.String("skip-conforming")
+	c.Owner = ctx.String("owner")
+	c.Group = ctx.String("group")
+	c.BrokenSymlinks = ctx.Bool("broken-symlinks")
+	c.SymlinksOnly = ctx.Bool("match-symlinks-only") || c.BrokenSymlinks
+	c.Empty = ctx.Bool("empty")
+	c.NonEmpty = ctx.Bool("non-empty")
+	c.RespectGitignore = ctx.Bool("respect-gitignore")
+	c.WithXattrs = ctx.Bool("with-xattrs")
+	c.ExtFilter = ctx.StringSlice("ext")
+	c.EmptyNameFallback = ctx.String("empty-name-fallback")
 	c.Verbose = ctx.Bool("verbose")
+	c.ShowIndex = ctx.Bool("show-index")
+	c.Explain = ctx.Bool("explain")
 	c.AllowOverwrites = ctx.Bool("allow-overwrites")
-	c.ReplaceLimit = ctx.Int("replace-limit")
+	c.OverwriteIf = ctx.String("overwrite-if")
+	c.TrimTo = ctx.Int("trim-to")
 	c.Quiet = ctx.Bool("quiet")
-	c.JSON = ctx.Bool("json")
+	c.Safe = ctx.Bool("safe")
+	c.Force = ctx.Bool("force")
+	c.SavePlan = ctx.Bool("save-plan")
+	c.LongPaths = ctx.Bool("long-paths")
+	c.BackupFallback = ctx.Bool("backup-fallback")
+	c.Yes = ctx.Bool("yes")
+	c.ProtectPrefix = ctx.String("protect-prefix")
+	c.ProtectSuffix = ctx.String("protect-suffix")
+	c.OutputFormat = ctx.String("output-format")
+	c.RegexEngine = ctx.String("regex-engine")
+
+	switch c.RegexEngine {
+	case pattern.RE2, pattern.PCRE:
+	default:
+		return errInvalidRegexEngine
+	}
+
+	switch c.OutputFormat {
+	case FormatTable, FormatJSON, FormatHTML, FormatTree:
+	default:
+		return errInvalidOutputFormat
+	}
+
+	// --json is a shorthand for --output-format json
+	if ctx.Bool("json") {
+		c.OutputFormat = FormatJSON
+	}
+
+	// --json-stream produces JSON output with one change per line
+	if ctx.Bool("json-stream") {
+		c.OutputFormat = FormatJSON
+		c.JSONStream = true
+	}
+
+	c.JSON = c.OutputFormat == FormatJSON
 
 	// Sorting
 	if ctx.String("sort") != "" {
@@ -180,9 +1049,71 @@ func (c *Config) setDefaultOpts(ctx *cli.Context) {
 		c.ReverseSort = true
 	}
 
+	c.DirsFirst = ctx.Bool("dirs-first")
+	c.DirsLast = ctx.Bool("dirs-last")
+
 	if c.OnlyDir {
 		c.IncludeDir = true
 	}
+
+	switch c.OverwriteIf {
+	case OverwriteAlways, OverwriteNewer, OverwriteLarger:
+	default:
+		return errInvalidOverwritePolicy
+	}
+
+	if c.Workers < 1 {
+		return errInvalidWorkers
+	}
+
+	if ctx.IsSet("rate") && c.Rate < 1 {
+		return errInvalidRate
+	}
+
+	c.RateLimiter = ratelimit.New(c.Rate)
+
+	err := c.setReplaceLimit(ctx.String("replace-limit"))
+	if err != nil {
+		return err
+	}
+
+	if ctx.IsSet("trim-to") && c.TrimTo < 1 {
+		return errInvalidTrimTo
+	}
+
+	if c.Empty && c.NonEmpty {
+		return errEmptyAndNonEmpty
+	}
+
+	if c.DirsFirst && c.DirsLast {
+		return errDirsFirstAndLast
+	}
+
+	// Guard against modifying the filesystem in locked-down environments
+	if c.Exec && c.Safe {
+		return errExecForbidden
+	}
+
+	if c.StatMaxBytes < 1 {
+		return errInvalidStatMaxBytes
+	}
+
+	// A policy for overwriting paths implies that overwrites are allowed
+	if ctx.IsSet("overwrite-if") {
+		c.AllowOverwrites = true
+	}
+
+	err = c.setDirMode(ctx.String("dir-mode"))
+	if err != nil {
+		return err
+	}
+
+	err = c.setMaxDepth(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.setMinDepth(ctx)
 }
 
 func Init(ctx *cli.Context) (*Config, error) {
@@ -229,6 +1160,11 @@ func Init(ctx *cli.Context) (*Config, error) {
 		return nil, err
 	}
 
+	err = conf.checkProtectedPaths()
+	if err != nil {
+		return nil, err
+	}
+
 	return conf, nil
 }
 
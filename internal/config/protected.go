@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	internalos "github.com/ayoisaiah/f2/internal/os"
+)
+
+var errProtectedPath = errors.New(
+	"Refusing to operate on '%s' because it is a protected system location. Use --force to run anyway",
+)
+
+// defaultProtectedPaths returns the system directories of the current
+// operating system that f2 refuses to operate on without --force. The root
+// of every volume and the home directory of the user are also protected.
+func defaultProtectedPaths() []string {
+	var paths []string
+
+	switch runtime.GOOS {
+	case internalos.Windows:
+		for _, env := range []string{
+			"SystemRoot",
+			"ProgramFiles",
+			"ProgramFiles(x86)",
+			"ProgramData",
+			"PUBLIC",
+		} {
+			if v := os.Getenv(env); v != "" {
+				paths = append(paths, v)
+			}
+		}
+
+		paths = append(
+			paths,
+			`C:\Windows`,
+			`C:\Program Files`,
+			`C:\Program Files (x86)`,
+			`C:\ProgramData`,
+			`C:\Users`,
+		)
+	default:
+		paths = []string{
+			"/bin",
+			"/boot",
+			"/dev",
+			"/etc",
+			"/home",
+			"/lib",
+			"/lib64",
+			"/opt",
+			"/proc",
+			"/root",
+			"/sbin",
+			"/sys",
+			"/usr",
+			"/usr/bin",
+			"/usr/lib",
+			"/usr/local",
+			"/usr/sbin",
+			"/var",
+		}
+
+		if runtime.GOOS == internalos.Darwin {
+			paths = append(
+				paths,
+				"/Applications",
+				"/Library",
+				"/System",
+				"/Users",
+				"/private",
+				"/private/etc",
+				"/private/var",
+			)
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, home)
+	}
+
+	return paths
+}
+
+// samePath reports whether two cleaned absolute paths are the same. The
+// comparison ignores case on Windows and macOS since their filesystems are
+// case-insensitive by default.
+func samePath(a, b string) bool {
+	if runtime.GOOS == internalos.Windows || runtime.GOOS == internalos.Darwin {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+// isProtected reports whether the path is the root of a volume or one of
+// the protected paths. Symbolic links are resolved so that a link to a
+// protected directory is also refused.
+func isProtected(path string, protected []string) bool {
+	candidates := []string{path}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		candidates = append(candidates, resolved)
+	}
+
+	for _, candidate := range candidates {
+		if filepath.Dir(candidate) == candidate {
+			return true
+		}
+
+		for _, p := range protected {
+			absPath, err := filepath.Abs(p)
+			if err != nil {
+				continue
+			}
+
+			if samePath(candidate, absPath) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkProtectedPaths returns an error if any of the paths to search (or
+// the working directory if none were specified) is a protected location
+// unless --force is set. The protected paths from the config files are
+// checked in addition to the defaults. Operations that do not search for
+// paths (such as undoing or applying a plan) are not checked since they
+// only modify the paths that were recorded previously.
+func (c *Config) checkProtectedPaths() error {
+	if c.Force || c.Revert || c.ApplyPlan != "" || c.PrintConfig {
+		return nil
+	}
+
+	paths := c.PathsToFilesOrDirs
+	if len(paths) == 0 {
+		paths = []string{c.WorkingDir}
+	}
+
+	protected := append(defaultProtectedPaths(), c.ProtectedPaths...)
+
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		if isProtected(absPath, protected) {
+			return fmt.Errorf(errProtectedPath.Error(), absPath)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"golang.org/x/exp/slices"
+)
+
+var errInvalidConfigFile = errors.New(
+	"the config file '%s' is invalid: %w",
+)
+
+var errInvalidExtRule = errors.New(
+	"rule #%d in the config file '%s' must specify at least one extension",
+)
+
+var errEmptyPipeline = errors.New(
+	"the pipeline '%s' in the config file '%s' must have at least one step",
+)
+
+var errUnknownPipeline = errors.New(
+	"the pipeline '%s' is not defined in the config file(s): %s",
+)
+
+var errPipelineNoConfigFile = errors.New(
+	"the pipeline '%s' cannot be used without a config file",
+)
+
+var errPipelineWithFind = errors.New(
+	"Invalid argument: --pipeline cannot be combined with -f/--find or -r/--replace",
+)
+
+// ExtRule is a find and replace pair that applies by default to the files
+// with one of the specified extensions.
+type ExtRule struct {
+	Find       string   `json:"find"`
+	Replace    string   `json:"replace"`
+	Extensions []string `json:"extensions"`
+}
+
+// PipelineStep is a find and replace pair in a pipeline. The entire name is
+// matched if the find pattern is empty so that a step may only apply a
+// transformation (e.g. `{{.ti}}`).
+type PipelineStep struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+}
+
+// File represents the structure of the config file.
+type File struct {
+	Pipelines map[string][]PipelineStep `json:"pipelines"`
+	Rules     []ExtRule                 `json:"rules"`
+	// ProtectedPaths are refused without --force in
+	// addition to the default system locations
+	ProtectedPaths []string `json:"protected_paths"`
+}
+
+// dirConfigFile is the name of the config file that applies when f2 is run in
+// the directory that contains it.
+const dirConfigFile = ".f2.json"
+
+// defaultConfigFile returns the path to the config file in the user's
+// config directory or an empty string if it does not exist.
+func defaultConfigFile() string {
+	path, err := xdg.SearchConfigFile(filepath.Join("f2", "config.json"))
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// loadConfigFile reads the extension rules, pipelines, and protected paths
+// in the specified config file.
+func loadConfigFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		// Point to the location of syntax errors since the offset in the
+		// error is hard to find in the file
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(b, syntaxErr.Offset)
+			err = fmt.Errorf("line %d, column %d: %w", line, col, err)
+		}
+
+		return nil, fmt.Errorf(errInvalidConfigFile.Error(), path, err)
+	}
+
+	for i := range f.Rules {
+		if len(f.Rules[i].Extensions) == 0 {
+			return nil, fmt.Errorf(errInvalidExtRule.Error(), i+1, path)
+		}
+	}
+
+	for name, steps := range f.Pipelines {
+		if len(steps) == 0 {
+			return nil, fmt.Errorf(errEmptyPipeline.Error(), name, path)
+		}
+	}
+
+	return &f, nil
+}
+
+// position converts the byte offset in b to a line and column (both
+// starting from 1).
+func position(b []byte, offset int64) (line, col int) {
+	line, col = 1, 1
+
+	for i := int64(0); i < offset-1 && i < int64(len(b)); i++ {
+		if b[i] == '\n' {
+			line++
+			col = 1
+
+			continue
+		}
+
+		col++
+	}
+
+	return line, col
+}
+
+// mergeDirConfig adds the extension rules, pipelines, and protected paths in
+// the directory config file to the ones in the global config file. Those in
+// the directory config file take precedence.
+func (c *Config) mergeDirConfig(f *File) {
+	c.ExtRules = slices.Insert(c.ExtRules, 0, f.Rules...)
+	c.ProtectedPaths = append(c.ProtectedPaths, f.ProtectedPaths...)
+
+	if len(f.Pipelines) == 0 {
+		return
+	}
+
+	pipelines := make(map[string][]PipelineStep)
+
+	for name, steps := range c.Pipelines {
+		pipelines[name] = steps
+	}
+
+	for name, steps := range f.Pipelines {
+		pipelines[name] = steps
+	}
+
+	c.Pipelines = pipelines
+}
+
+// configFiles returns the config files that were loaded.
+func (c *Config) configFiles() []string {
+	var files []string
+
+	if c.DirConfigFile != "" {
+		files = append(files, c.DirConfigFile)
+	}
+
+	if c.ConfigFile != "" {
+		files = append(files, c.ConfigFile)
+	}
+
+	return files
+}
+
+// setPipeline sets the find and replace chain to the steps of the named
+// pipeline in the config file.
+func (c *Config) setPipeline(name string) error {
+	if len(c.FindSlice) > 0 || len(c.ReplacementSlice) > 0 {
+		return errPipelineWithFind
+	}
+
+	files := c.configFiles()
+	if len(files) == 0 {
+		return fmt.Errorf(errPipelineNoConfigFile.Error(), name)
+	}
+
+	steps, ok := c.Pipelines[name]
+	if !ok {
+		return fmt.Errorf(
+			errUnknownPipeline.Error(),
+			name,
+			"'"+strings.Join(files, "', '")+"'",
+		)
+	}
+
+	for _, step := range steps {
+		find := step.Find
+		if find == "" {
+			find = ".*"
+		}
+
+		c.FindSlice = append(c.FindSlice, find)
+		c.ReplacementSlice = append(c.ReplacementSlice, step.Replace)
+	}
+
+	return nil
+}
+
+// HasExtRules reports whether the matches are renamed according to the
+// extension rules in the config file. This is only the case if the new
+// names are not provided on the command line in some other way.
+func (c *Config) HasExtRules() bool {
+	return len(c.ExtRules) > 0 && len(c.FindSlice) == 0 &&
+		len(c.ReplacementSlice) == 0 && !c.HasDirReplacement() &&
+		!c.Edit && !c.StdinNames && c.MapFile == ""
+}
+
+// ExtRule returns the first extension rule that applies to the file name
+// or nil if there is none. The extensions are case insensitive and the
+// leading dot is optional.
+func (c *Config) ExtRule(filename string) *ExtRule {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if ext == "" {
+		return nil
+	}
+
+	for i := range c.ExtRules {
+		for _, e := range c.ExtRules[i].Extensions {
+			if strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+				return &c.ExtRules[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExtRuleConfig returns a copy of the configuration whose find and replace
+// chain is the one specified by the extension rule.
+func (c *Config) ExtRuleConfig(rule *ExtRule) (*Config, error) {
+	ruleConf := *c
+	ruleConf.FindSlice = []string{rule.Find}
+	ruleConf.ReplacementSlice = []string{rule.Replace}
+
+	if rule.Find == "" {
+		ruleConf.FindSlice = nil
+	}
+
+	err := ruleConf.SetFindStringRegex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ruleConf, nil
+}
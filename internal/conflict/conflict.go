@@ -20,6 +20,14 @@ const (
 	FileExists                Name = "fileExists"
 	OverwritingNewPath        Name = "overwritingNewPath"
 	MaxFilenameLengthExceeded Name = "maxFilenameLengthExceeded"
+	MaxPathLengthExceeded     Name = "maxPathLengthExceeded"
 	InvalidCharacters         Name = "invalidCharacters"
 	TrailingPeriod            Name = "trailingPeriod"
+	WorkingDirRename          Name = "workingDirRename"
+	TypeMismatch              Name = "typeMismatch"
+	OverwritingSource         Name = "overwritingSource"
+
+	// ReservedName is only reported by validate.ValidateName since the
+	// device names in Windows are not checked during a renaming operation
+	ReservedName Name = "reservedName"
 )
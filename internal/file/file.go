@@ -1,6 +1,19 @@
 package file
 
-import "github.com/ayoisaiah/f2/internal/status"
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ayoisaiah/f2/internal/status"
+)
+
+// Fix records an automatic conflict resolution applied to a change.
+// Target is the proposed target before the fix was applied.
+type Fix struct {
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
 
 // Change represents a single renaming change.
 type Change struct {
@@ -9,9 +22,54 @@ type Change struct {
 	BaseDir        string        `json:"base_dir"`
 	Source         string        `json:"source"`
 	Target         string        `json:"target"`
-	Error          error         `json:"error,omitempty"`
+	Error          error         `json:"-"`
+	ErrorMessage   string        `json:"error,omitempty"` // the message of Error in the JSON output
 	CSVRow         []string      `json:"-"`
+	Root           string        `json:"-"` // the path argument that the match was found in
+	Fixes          []Fix         `json:"fixes,omitempty"`
+	ModTime        *time.Time    `json:"mod_time,omitempty"` // recorded in execute mode so that it can be restored on undo
+	ShownIndex     *int          `json:"index,omitempty"`    // Index in the output with --show-index
 	Index          int           `json:"-"`
+	Depth          int           `json:"-"` // relative to the path argument that the match was found in
 	IsDir          bool          `json:"is_dir"`
 	WillOverwrite  bool          `json:"will_overwrite"`
+	Applied        bool          `json:"applied"` // whether the change was committed to the filesystem
+	AppleDoubleOf  *Change       `json:"-"`       // the change whose AppleDouble file this change renames (see --with-xattrs)
+}
+
+// AppleDoublePrefix is the prefix of the AppleDouble files that macOS
+// creates to hold the extended attributes and resource forks of files on
+// filesystems that cannot store them natively.
+const AppleDoublePrefix = "._"
+
+// AppleDoublePath returns the path of the AppleDouble file of the path.
+func AppleDoublePath(path string) string {
+	return filepath.Join(
+		filepath.Dir(path),
+		AppleDoublePrefix+filepath.Base(path),
+	)
+}
+
+// The placeholders in the arguments of the commands that are run for
+// each change (see CommandArgs).
+const (
+	SourcePlaceholder = "{{source}}"
+	TargetPlaceholder = "{{target}}"
+)
+
+// CommandArgs returns a copy of the arguments of a command with the
+// {{source}} and {{target}} placeholders replaced with the source and
+// target paths of the change.
+func (c *Change) CommandArgs(command []string) []string {
+	replacer := strings.NewReplacer(
+		SourcePlaceholder, filepath.Join(c.BaseDir, c.Source),
+		TargetPlaceholder, filepath.Join(c.BaseDir, c.Target),
+	)
+
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	return args
 }
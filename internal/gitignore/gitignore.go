@@ -0,0 +1,350 @@
+// Package gitignore reports whether paths are ignored by the `.gitignore`
+// files of the git repository they belong to. The files are read from the
+// root of the repository down to the directory of each path so that the
+// patterns in a nested file take precedence over those in its parents.
+package gitignore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitDir is the name of the directory that holds the repository data.
+// It is always ignored since git never tracks it.
+const gitDir = ".git"
+
+// rule is a single pattern in an ignore file.
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool // the pattern starts with `!`
+	dirOnly bool // the pattern ends with `/`
+}
+
+// Matcher checks paths against the ignore files of their repository. The
+// repository roots and ignore files are looked up once and cached.
+type Matcher struct {
+	// roots maps each directory to the root of its repository
+	// (empty if it is not within a repository)
+	roots map[string]string
+	// rules maps each directory to the patterns in its ignore file
+	rules map[string][]rule
+	// dirs maps each directory to whether it is ignored
+	dirs map[string]bool
+}
+
+// New returns a Matcher with an empty cache.
+func New() *Matcher {
+	return &Matcher{
+		roots: make(map[string]string),
+		rules: make(map[string][]rule),
+		dirs:  make(map[string]bool),
+	}
+}
+
+// Ignored reports whether git ignores the path. Like in git, the contents
+// of an ignored directory are ignored as well and cannot be included again
+// with a negated pattern. Paths outside a git repository are never ignored.
+func (m *Matcher) Ignored(path string, isDir bool) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	if filepath.Base(absPath) == gitDir {
+		return true, nil
+	}
+
+	dir := filepath.Dir(absPath)
+
+	root, err := m.repoRoot(dir)
+	if err != nil || root == "" {
+		return false, err
+	}
+
+	ignored, err := m.dirIgnored(root, dir)
+	if err != nil || ignored {
+		return ignored, err
+	}
+
+	return m.match(root, absPath, isDir)
+}
+
+// dirIgnored reports whether the directory or any of its parents below the
+// repository root is ignored. The result is cached for each directory.
+func (m *Matcher) dirIgnored(root, dir string) (bool, error) {
+	if dir == root {
+		return false, nil
+	}
+
+	if ignored, ok := m.dirs[dir]; ok {
+		return ignored, nil
+	}
+
+	ignored, err := m.dirIgnored(root, filepath.Dir(dir))
+	if err != nil {
+		return false, err
+	}
+
+	if !ignored {
+		ignored = filepath.Base(dir) == gitDir
+	}
+
+	if !ignored {
+		ignored, err = m.match(root, dir, true)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	m.dirs[dir] = ignored
+
+	return ignored, nil
+}
+
+// match reports whether the path (within the repository at root) matches
+// the patterns that apply to it. Its parent directories are not checked.
+func (m *Matcher) match(root, absPath string, isDir bool) (bool, error) {
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil {
+		return false, err
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	// The patterns of each directory from the root down to the parent of
+	// the path are applied in turn and the last one that matches decides
+	var ignored bool
+
+	ruleDir := ""
+
+	for {
+		rules, err := m.dirRules(root, ruleDir)
+		if err != nil {
+			return false, err
+		}
+
+		relToRuleDir := strings.TrimPrefix(rel, ruleDir)
+
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+
+			if r.re.MatchString(relToRuleDir) {
+				ignored = !r.negate
+			}
+		}
+
+		next := strings.IndexByte(relToRuleDir, '/')
+		if next == -1 {
+			break
+		}
+
+		ruleDir += relToRuleDir[:next+1]
+	}
+
+	return ignored, nil
+}
+
+// repoRoot returns the closest directory (starting from dir) that contains
+// a `.git` directory or file. An empty string is returned if there is none.
+func (m *Matcher) repoRoot(dir string) (string, error) {
+	if root, ok := m.roots[dir]; ok {
+		return root, nil
+	}
+
+	var root string
+
+	_, err := os.Stat(filepath.Join(dir, gitDir))
+
+	switch {
+	case err == nil:
+		root = dir
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	case filepath.Dir(dir) != dir:
+		root, err = m.repoRoot(filepath.Dir(dir))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	m.roots[dir] = root
+
+	return root, nil
+}
+
+// dirRules returns the patterns that apply to the contents of the
+// directory (relative to the repository root with a trailing slash). The
+// patterns in the `info/exclude` file of the repository precede those in
+// the `.gitignore` file at the root.
+func (m *Matcher) dirRules(root, dir string) ([]rule, error) {
+	path := filepath.Join(root, filepath.FromSlash(dir))
+
+	if rules, ok := m.rules[path]; ok {
+		return rules, nil
+	}
+
+	var rules []rule
+
+	files := []string{filepath.Join(path, ".gitignore")}
+
+	// `.git` may also be a file that points to the repository data (such
+	// as in a worktree or submodule) in which case it has no exclude file
+	if info, err := os.Stat(filepath.Join(root, gitDir)); dir == "" &&
+		err == nil && info.IsDir() {
+		files = append(
+			[]string{filepath.Join(root, gitDir, "info", "exclude")},
+			files...,
+		)
+	}
+
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, parse(string(b))...)
+	}
+
+	m.rules[path] = rules
+
+	return rules, nil
+}
+
+// parse converts the lines of an ignore file to rules. Patterns that cannot
+// be converted are skipped like git does.
+func parse(content string) []rule {
+	var rules []rule
+
+	for _, line := range strings.Split(content, "\n") {
+		line = trimTrailingSpace(strings.TrimSuffix(line, "\r"))
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(toRegex(line))
+		if err != nil {
+			continue
+		}
+
+		r.re = re
+
+		rules = append(rules, r)
+	}
+
+	return rules
+}
+
+// trimTrailingSpace removes the trailing spaces of a line unless they are
+// escaped with a backslash.
+func trimTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	return line
+}
+
+// toRegex converts a gitignore pattern to a regular expression that
+// matches slash separated paths relative to the directory of the ignore
+// file. A pattern with a slash at the beginning or in the middle is
+// anchored to that directory. Otherwise, it matches at any level below it.
+func toRegex(pattern string) string {
+	var sb strings.Builder
+
+	sb.WriteString("^")
+
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		sb.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch c {
+		case '*':
+			atSegmentStart := i == 0 || pattern[i-1] == '/'
+
+			if i+1 < len(pattern) && pattern[i+1] == '*' && atSegmentStart {
+				rest := pattern[i+2:]
+
+				switch {
+				case rest == "":
+					// a trailing `/**` matches everything inside
+					sb.WriteString(".*")
+
+					i++
+
+					continue
+				case rest[0] == '/':
+					// a leading or middle `**/` matches zero
+					// or more directories
+					sb.WriteString("(?:.*/)?")
+
+					i += 2
+
+					continue
+				}
+			}
+
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				sb.WriteString(`\[`)
+				continue
+			}
+
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			sb.WriteString("[" + class + "]")
+
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+
+			sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return sb.String()
+}
@@ -0,0 +1,216 @@
+// Package hash computes the checksums of files. The checksums are cached so
+// that each file is read at most once per algorithm in a renaming operation
+// even if it is hashed while finding the matches and again while replacing
+// the hash variables.
+package hash
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Algorithm is the name of a supported hash function.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "sha1"
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+	MD5    Algorithm = "md5"
+	CRC32  Algorithm = "crc32"
+)
+
+var errInvalidList = errors.New(
+	"Invalid hash list '%s': line %d must start with an md5, sha1, sha256, or sha512 hash",
+)
+
+type key struct {
+	path      string
+	algorithm Algorithm
+}
+
+var (
+	cache = make(map[key]string)
+	mu    sync.RWMutex
+)
+
+// Reset clears the cache since the files may have changed
+// since a previous renaming operation.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cache = make(map[key]string)
+}
+
+// newHash returns the hash function for the algorithm
+// or nil if it is not supported.
+func newHash(algorithm Algorithm) hash.Hash {
+	switch algorithm {
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	case MD5:
+		return md5.New()
+	case CRC32:
+		return crc32.NewIEEE()
+	}
+
+	return nil
+}
+
+// File returns the hex encoded checksum of the contents of the file. The
+// file is streamed through a fixed size buffer so that large files are not
+// read into memory at once. An empty string is returned for a directory or
+// if the algorithm is not supported.
+func File(path string, algorithm Algorithm) (string, error) {
+	k := key{path: path, algorithm: algorithm}
+
+	mu.RLock()
+	v, ok := cache[k]
+	mu.RUnlock()
+
+	if ok {
+		return v, nil
+	}
+
+	h := newHash(algorithm)
+	if h == nil {
+		return "", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if !fileInfo.IsDir() {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+
+		v = hex.EncodeToString(h.Sum(nil))
+	}
+
+	mu.Lock()
+	cache[k] = v
+	mu.Unlock()
+
+	return v, nil
+}
+
+// algorithmFor infers the algorithm of a hex encoded checksum from its
+// length since each supported algorithm has a different digest size.
+func algorithmFor(checksum string) (Algorithm, bool) {
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return "", false
+	}
+
+	switch len(checksum) {
+	case md5.Size * 2:
+		return MD5, true
+	case sha1.Size * 2:
+		return SHA1, true
+	case sha256.Size * 2:
+		return SHA256, true
+	case sha512.Size * 2:
+		return SHA512, true
+	}
+
+	return "", false
+}
+
+// List is a set of checksums that files can be compared against.
+type List struct {
+	checksums  map[string]bool
+	algorithms []Algorithm // in the order they first appear in the list
+}
+
+// ReadList reads the checksums in the file at the specified path. Each
+// line starts with a checksum which may be followed by other fields (such
+// as the file name in the output of sha256sum). Empty lines and those that
+// start with `#` are ignored. The algorithms may be mixed in a list since
+// each one is inferred from the length of the checksum.
+func ReadList(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	l := &List{
+		checksums: make(map[string]bool),
+	}
+
+	seen := make(map[Algorithm]bool)
+
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		checksum := strings.ToLower(fields[0])
+
+		algorithm, ok := algorithmFor(checksum)
+		if !ok {
+			return nil, fmt.Errorf(errInvalidList.Error(), path, lineNo)
+		}
+
+		if !seen[algorithm] {
+			seen[algorithm] = true
+			l.algorithms = append(l.algorithms, algorithm)
+		}
+
+		l.checksums[checksum] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Contains reports whether the checksum of the file with any of the
+// algorithms used in the list is in the list.
+func (l *List) Contains(path string) (bool, error) {
+	for _, algorithm := range l.algorithms {
+		checksum, err := File(path, algorithm)
+		if err != nil {
+			return false, err
+		}
+
+		if l.checksums[checksum] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
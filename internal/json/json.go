@@ -2,10 +2,12 @@ package json
 
 import (
 	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/ayoisaiah/f2/internal/conflict"
 	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/warning"
 	"github.com/ayoisaiah/f2/validate"
 )
 
@@ -15,16 +17,105 @@ type Output struct {
 	Conflicts  conflict.Collection `json:"conflicts,omitempty"`
 	WorkingDir string              `json:"working_dir"`
 	Date       string              `json:"date"`
+	BackupFile string              `json:"backup_file,omitempty"`
+	PlanFile   string              `json:"plan_file,omitempty"`
 	Changes    []*file.Change      `json:"changes"`
 	Errors     []int               `json:"errors,omitempty"`
+	Warnings   []string            `json:"warnings,omitempty"`
+	Stats      *Stats              `json:"stats,omitempty"`
 	DryRun     bool                `json:"dry_run"`
+	NoMatches  bool                `json:"no_matches,omitempty"` // the find pattern did not match any files
+}
+
+// Stats summarises the outcome of a renaming operation that
+// was committed to the filesystem.
+type Stats struct {
+	Elapsed            string `json:"elapsed"`
+	Renamed            int    `json:"renamed"`
+	Skipped            int    `json:"skipped"`
+	Failed             int    `json:"failed"`
+	DirectoriesCreated int    `json:"directories_created"`
+}
+
+// CountOutput represents the structure of the output produced by the
+// `--count` flag.
+type CountOutput struct {
+	Directories map[string]int `json:"directories"`
+	WorkingDir  string         `json:"working_dir"`
+	Date        string         `json:"date"`
+	Count       int            `json:"count"`
+}
+
+// DuplicatesOutput represents the structure of the output produced by the
+// `--find-duplicate-names` flag.
+type DuplicatesOutput struct {
+	Duplicates map[string][]string `json:"duplicates"`
+	WorkingDir string              `json:"working_dir"`
+	Date       string              `json:"date"`
+}
+
+// The values of the type field in the output produced by the
+// `--json-stream` flag.
+const (
+	StreamTypeChange  = "change"
+	StreamTypeSummary = "summary"
+)
+
+// StreamChange represents a single change in the output produced by the
+// `--json-stream` flag.
+type StreamChange struct {
+	*file.Change
+	Type string `json:"type"`
+}
+
+// StreamSummary is the final object in the output produced by the
+// `--json-stream` flag. It holds everything in Output except the changes.
+type StreamSummary struct {
+	Conflicts  conflict.Collection `json:"conflicts,omitempty"`
+	Type       string              `json:"type"`
+	WorkingDir string              `json:"working_dir"`
+	Date       string              `json:"date"`
+	BackupFile string              `json:"backup_file,omitempty"`
+	PlanFile   string              `json:"plan_file,omitempty"`
+	Errors     []int               `json:"errors,omitempty"`
+	Warnings   []string            `json:"warnings,omitempty"`
+	Stats      *Stats              `json:"stats,omitempty"`
+	Count      int                 `json:"count"`
+	DryRun     bool                `json:"dry_run"`
+	NoMatches  bool                `json:"no_matches,omitempty"`
 }
 
 type OutputOpts struct {
 	Date       time.Time
 	WorkingDir string
+	BackupFile string // set once the backup file has been written
+	PlanFile   string // set once the plan file has been written
+	Stats      *Stats // set once the renaming operation has been committed
 	Exec       bool
 	Print      bool // whether to print the JSON output
+	Stream     bool // whether to print the JSON output one change per line
+	HTML       bool // whether to render the output as an HTML page
+	Tree       bool // whether to group the changes under their directories
+	ShowIndex  bool // whether to include the index of each change
+}
+
+// setErrorMessages records the message of the error of each change (if
+// any) so that it is encoded as a string.
+func setErrorMessages(changes []*file.Change) {
+	for _, change := range changes {
+		if change.Error != nil {
+			change.ErrorMessage = change.Error.Error()
+		}
+	}
+}
+
+// setShownIndexes records the index of each change so that it is
+// included in the output.
+func setShownIndexes(changes []*file.Change) {
+	for _, change := range changes {
+		index := change.Index
+		change.ShownIndex = &index
+	}
 }
 
 func GetOutput(
@@ -32,13 +123,23 @@ func GetOutput(
 	changes []*file.Change,
 	errs []int,
 ) ([]byte, error) {
+	setErrorMessages(changes)
+
+	if opts.ShowIndex {
+		setShownIndexes(changes)
+	}
+
 	out := Output{
 		WorkingDir: opts.WorkingDir,
 		Date:       opts.Date.Format(time.RFC3339),
+		BackupFile: opts.BackupFile,
+		PlanFile:   opts.PlanFile,
 		DryRun:     !opts.Exec,
 		Changes:    changes,
 		Conflicts:  validate.GetConflicts(),
 		Errors:     errs,
+		Warnings:   warning.Get(),
+		Stats:      opts.Stats,
 	}
 
 	// prevent empty matches from being encoded as `null`
@@ -53,3 +154,114 @@ func GetOutput(
 
 	return b, nil
 }
+
+// WriteStream writes each change to w as a separate JSON object (one per
+// line) followed by a summary object so that the output does not have to
+// be held in memory all at once.
+func WriteStream(
+	w io.Writer,
+	opts *OutputOpts,
+	changes []*file.Change,
+	errs []int,
+) error {
+	setErrorMessages(changes)
+
+	if opts.ShowIndex {
+		setShownIndexes(changes)
+	}
+
+	encoder := json.NewEncoder(w)
+
+	for _, change := range changes {
+		err := encoder.Encode(StreamChange{
+			Type:   StreamTypeChange,
+			Change: change,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return encoder.Encode(StreamSummary{
+		Type:       StreamTypeSummary,
+		WorkingDir: opts.WorkingDir,
+		Date:       opts.Date.Format(time.RFC3339),
+		BackupFile: opts.BackupFile,
+		PlanFile:   opts.PlanFile,
+		DryRun:     !opts.Exec,
+		Count:      len(changes),
+		Conflicts:  validate.GetConflicts(),
+		Errors:     errs,
+		Warnings:   warning.Get(),
+		Stats:      opts.Stats,
+	})
+}
+
+// WriteNoMatchesStream writes the summary object for when the find pattern
+// does not match any files in the `--json-stream` format.
+func WriteNoMatchesStream(w io.Writer, opts *OutputOpts) error {
+	return json.NewEncoder(w).Encode(StreamSummary{
+		Type:       StreamTypeSummary,
+		WorkingDir: opts.WorkingDir,
+		Date:       opts.Date.Format(time.RFC3339),
+		DryRun:     !opts.Exec,
+		NoMatches:  true,
+	})
+}
+
+// GetNoMatchesOutput produces the output for when the find pattern
+// does not match any files.
+func GetNoMatchesOutput(opts *OutputOpts) ([]byte, error) {
+	out := Output{
+		WorkingDir: opts.WorkingDir,
+		Date:       opts.Date.Format(time.RFC3339),
+		DryRun:     !opts.Exec,
+		Changes:    make([]*file.Change, 0),
+		NoMatches:  true,
+	}
+
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+func GetCountOutput(
+	opts *OutputOpts,
+	directories map[string]int,
+	count int,
+) ([]byte, error) {
+	out := CountOutput{
+		WorkingDir:  opts.WorkingDir,
+		Date:        opts.Date.Format(time.RFC3339),
+		Directories: directories,
+		Count:       count,
+	}
+
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+func GetDuplicatesOutput(
+	opts *OutputOpts,
+	duplicates map[string][]string,
+) ([]byte, error) {
+	out := DuplicatesOutput{
+		WorkingDir: opts.WorkingDir,
+		Date:       opts.Date.Format(time.RFC3339),
+		Duplicates: duplicates,
+	}
+
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	internalos "github.com/ayoisaiah/f2/internal/os"
 )
@@ -26,3 +27,61 @@ func init() {
 func FilenameWithoutExtension(fileName string) string {
 	return fileName[:len(fileName)-len(filepath.Ext(fileName))]
 }
+
+// Depth returns the depth of the directory relative to the path argument
+// that it was found in. The contents of a path argument are at depth 0, so
+// its subdirectories are at depth 1 and so on. The longest path argument is
+// preferred if several of them contain the directory. A directory that is
+// not within any path argument (such as the parent of a file argument) is
+// at depth 0.
+func Depth(dir string, pathArgs []string) int {
+	if len(pathArgs) == 0 {
+		pathArgs = []string{"."}
+	}
+
+	dir = filepath.Clean(dir)
+
+	depth, longest := 0, -1
+
+	for _, arg := range pathArgs {
+		arg = filepath.Clean(arg)
+
+		var rel string
+
+		switch {
+		case dir == arg:
+			rel = "."
+		case arg == ".":
+			if filepath.IsAbs(dir) || dir == ".." ||
+				strings.HasPrefix(dir, ".."+Separator) {
+				continue
+			}
+
+			rel = dir
+		default:
+			prefix := arg
+			if !strings.HasSuffix(prefix, Separator) {
+				prefix += Separator
+			}
+
+			if !strings.HasPrefix(dir, prefix) {
+				continue
+			}
+
+			rel = dir[len(prefix):]
+		}
+
+		if len(arg) <= longest {
+			continue
+		}
+
+		longest = len(arg)
+
+		depth = 0
+		if rel != "." {
+			depth = strings.Count(rel, Separator) + 1
+		}
+	}
+
+	return depth
+}
@@ -0,0 +1,176 @@
+// Package pattern compiles the find patterns with the regular expression
+// engine selected on the command line
+package pattern
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// The regular expression engines that may be used for the find patterns.
+const (
+	// RE2 is the engine in Go's standard library. It guarantees that
+	// matching runs in linear time but does not support lookarounds and
+	// backreferences.
+	RE2 = "re2"
+	// PCRE is a backtracking engine with Perl compatible features such as
+	// lookarounds and backreferences. Some patterns may take exponential
+	// time to match so each match is subject to MatchTimeout.
+	PCRE = "pcre"
+)
+
+// MatchTimeout is the maximum amount of time that a PCRE pattern may spend
+// on a single match. A match that times out is treated as if the pattern
+// did not match.
+var MatchTimeout = time.Second
+
+// Regexp is a compiled find pattern. The methods behave like the ones of
+// the same name in the regexp package.
+type Regexp interface {
+	MatchString(s string) bool
+	FindAllString(s string, n int) []string
+	ReplaceAllString(src, repl string) string
+	ReplaceAllStringFunc(src string, repl func(string) string) string
+	String() string
+}
+
+// LimitReplacer is implemented by the patterns that cannot be replaced one
+// match at a time through ReplaceAllStringFunc without losing the
+// surrounding context (e.g. because of lookarounds).
+type LimitReplacer interface {
+	// ReplaceRange replaces count matches in src with repl starting from
+	// the one at the 1-based position start (counting from the end if it
+	// is negative). All the matches from start onwards are replaced if
+	// count is zero.
+	ReplaceRange(src, repl string, start, count int) string
+}
+
+// Compile parses the expression with the specified engine.
+//
+//nolint:ireturn // the engine is only known at runtime
+func Compile(expr, engine string) (Regexp, error) {
+	if engine != PCRE {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		return re, nil
+	}
+
+	re, err := regexp2.Compile(expr, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+
+	re.MatchTimeout = MatchTimeout
+
+	return &pcre{re}, nil
+}
+
+// pcre adapts a regexp2 pattern to the Regexp interface.
+type pcre struct {
+	re *regexp2.Regexp
+}
+
+func (p *pcre) MatchString(s string) bool {
+	matched, err := p.re.MatchString(s)
+
+	return err == nil && matched
+}
+
+func (p *pcre) FindAllString(s string, n int) []string {
+	var matches []string
+
+	m, err := p.re.FindStringMatch(s)
+	for err == nil && m != nil && (n < 0 || len(matches) < n) {
+		matches = append(matches, m.String())
+
+		m, err = p.re.FindNextMatch(m)
+	}
+
+	return matches
+}
+
+func (p *pcre) ReplaceAllString(src, repl string) string {
+	return p.ReplaceRange(src, repl, 1, 0)
+}
+
+func (p *pcre) ReplaceAllStringFunc(
+	src string,
+	repl func(string) string,
+) string {
+	out, err := p.re.ReplaceFunc(src, func(m regexp2.Match) string {
+		return repl(m.String())
+	}, -1, -1)
+	if err != nil {
+		return src
+	}
+
+	return out
+}
+
+func (p *pcre) ReplaceRange(src, repl string, start, count int) string {
+	startAt := -1
+
+	if count == 0 {
+		count = -1
+	}
+
+	if start != 1 {
+		// Start the replacement at the first match in the range so that
+		// the preceding text remains visible to lookbehinds
+		var indices []int
+
+		m, err := p.re.FindStringMatch(src)
+		for err == nil && m != nil {
+			indices = append(indices, m.Index)
+
+			m, err = p.re.FindNextMatch(m)
+		}
+
+		if err != nil {
+			return src
+		}
+
+		skip := start - 1
+		if start < 0 {
+			skip = len(indices) + start
+		}
+
+		if skip >= len(indices) {
+			return src
+		}
+
+		// Only the part of the range that overlaps the matches is replaced
+		// so that it is handled the same way as with the default engine
+		if skip < 0 {
+			if count > 0 {
+				count += skip
+				if count <= 0 {
+					return src
+				}
+			}
+
+			skip = 0
+		}
+
+		if skip > 0 {
+			// The match index is in runes but startAt is in bytes
+			startAt = len(string([]rune(src)[:indices[skip]]))
+		}
+	}
+
+	out, err := p.re.Replace(src, repl, startAt, count)
+	if err != nil {
+		return src
+	}
+
+	return out
+}
+
+func (p *pcre) String() string {
+	return p.re.String()
+}
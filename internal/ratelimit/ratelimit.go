@@ -0,0 +1,60 @@
+// Package ratelimit limits the number of filesystem operations per second
+// so that shared or network storage is not overwhelmed. A single limiter is
+// shared by every goroutine that accesses the filesystem.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Now and Sleep are the clock used by the limiters. They may be replaced
+// (such as in tests) to avoid waiting in real time.
+var (
+	Now   = time.Now
+	Sleep = time.Sleep
+)
+
+// Limiter is a token bucket that holds a single token so that the
+// operations are evenly spaced. A nil Limiter does not limit anything.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // when the next token is available
+}
+
+// New returns a limiter that allows the specified number of operations per
+// second. It returns nil (no limit) if the rate is not positive.
+func New(rate int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &Limiter{
+		interval: time.Second / time.Duration(rate),
+	}
+}
+
+// Wait blocks until the next operation is allowed. Concurrent callers are
+// given consecutive tokens so that they do not exceed the rate together.
+func (l *Limiter) Wait() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+
+	now := Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+
+	l.mu.Unlock()
+
+	if wait > 0 {
+		Sleep(wait)
+	}
+}
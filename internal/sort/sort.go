@@ -150,6 +150,77 @@ func Alphabetically(changes []*file.Change, reverseSort bool) []*file.Change {
 	return changes
 }
 
+// ByExtension sorts the changes by their file extension and then by the
+// rest of their name so that the files of each type are grouped together.
+// Both comparisons are case insensitive.
+func ByExtension(changes []*file.Change, reverseSort bool) []*file.Change {
+	sort.SliceStable(changes, func(i, j int) bool {
+		compareElement1 := strings.ToLower(changes[i].Source)
+		compareElement2 := strings.ToLower(changes[j].Source)
+
+		ext1 := filepath.Ext(compareElement1)
+		ext2 := filepath.Ext(compareElement2)
+
+		if ext1 != ext2 {
+			compareElement1, compareElement2 = ext1, ext2
+		}
+
+		if reverseSort {
+			return compareElement1 > compareElement2
+		}
+
+		return compareElement1 < compareElement2
+	})
+
+	return changes
+}
+
+// GroupDirs places the directories before the files (or after them if
+// dirsFirst is not set) without changing their order within each group.
+func GroupDirs(changes []*file.Change, dirsFirst bool) []*file.Change {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].IsDir == dirsFirst && changes[j].IsDir != dirsFirst
+	})
+
+	return changes
+}
+
+// ByOrder sorts the changes according to their position in the provided
+// order. Each entry is either a file name or an absolute path. Changes that
+// are not present in the order are placed last in their existing order.
+func ByOrder(changes []*file.Change, order []string) []*file.Change {
+	positions := make(map[string]int, len(order))
+
+	for i := len(order) - 1; i >= 0; i-- {
+		positions[order[i]] = i
+	}
+
+	position := func(change *file.Change) int {
+		// The paths in the order are absolute while the base directory
+		// is relative if the path argument is
+		sourcePath, err := filepath.Abs(
+			filepath.Join(change.BaseDir, change.Source),
+		)
+		if err == nil {
+			if pos, exists := positions[sourcePath]; exists {
+				return pos
+			}
+		}
+
+		if pos, exists := positions[change.Source]; exists {
+			return pos
+		}
+
+		return len(order)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return position(changes[i]) < position(changes[j])
+	})
+
+	return changes
+}
+
 // Changes is used to sort changes according to the configured sort value.
 func Changes(
 	changes []*file.Change,
@@ -159,6 +230,8 @@ func Changes(
 	switch sortName {
 	case "size":
 		return BySize(changes, reverseSort)
+	case "extension":
+		return ByExtension(changes, reverseSort), nil
 	case internaltime.Mod,
 		internaltime.Access,
 		internaltime.Birth,
@@ -5,6 +5,7 @@ type Status string
 const (
 	OK                     Status = "ok"
 	Unchanged              Status = "unchanged"
+	Skipped                Status = "skipped"
 	Overwriting            Status = "overwriting"
 	EmptyFilename          Status = "empty filename"
 	TrailingPeriod         Status = "trailing periods are prohibited"
@@ -12,4 +13,8 @@ const (
 	OverwritingNewPath     Status = "overwriting newly renamed path"
 	InvalidCharacters      Status = "invalid characters present: (%s)"
 	FilenameLengthExceeded Status = "max file name length exceeded: (%s)"
+	PathLengthExceeded     Status = "max path length exceeded: (%s)"
+	WorkingDirRename       Status = "cannot rename the working directory or its parents"
+	TypeMismatch           Status = "path already exists as a %s"
+	OverwritingSource      Status = "overwriting a path before it is renamed"
 )
@@ -0,0 +1,34 @@
+// Package warning collects the non-fatal problems encountered during an
+// operation so that they can be reported in every output format.
+package warning
+
+import "sync"
+
+var (
+	warnings []string
+	mu       sync.Mutex
+)
+
+// Add records a warning.
+func Add(msg string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	warnings = append(warnings, msg)
+}
+
+// Get returns the warnings that have been recorded so far.
+func Get() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]string(nil), warnings...)
+}
+
+// Reset discards the recorded warnings.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	warnings = nil
+}
@@ -0,0 +1,32 @@
+//go:build darwin
+// +build darwin
+
+package rename
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayoisaiah/f2/internal/file"
+)
+
+// movedWithFile reports whether the AppleDouble file of a change was
+// already renamed along with its file. macOS manages the AppleDouble files
+// itself on filesystems that cannot store extended attributes natively
+// (such as FAT, exFAT, and some network shares) so renaming a file there
+// also renames its AppleDouble file.
+func movedWithFile(change *file.Change, sourcePath, targetPath string) bool {
+	if !strings.HasPrefix(filepath.Base(change.Source), file.AppleDoublePrefix) {
+		return false
+	}
+
+	if _, err := os.Lstat(sourcePath); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+
+	_, err := os.Lstat(targetPath)
+
+	return err == nil
+}
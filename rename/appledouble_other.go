@@ -0,0 +1,12 @@
+//go:build !darwin
+// +build !darwin
+
+package rename
+
+import "github.com/ayoisaiah/f2/internal/file"
+
+// movedWithFile always reports false outside macOS since the AppleDouble
+// files are ordinary files that are only renamed by f2.
+func movedWithFile(_ *file.Change, _, _ string) bool {
+	return false
+}
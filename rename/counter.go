@@ -0,0 +1,119 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// counterLockTimeout is how long to wait for another renaming operation
+// that uses the same counter file to release it.
+const counterLockTimeout = 10 * time.Second
+
+// counterLockInterval is how often the lock of a counter file is retried.
+const counterLockInterval = 50 * time.Millisecond
+
+var errCounterLocked = errors.New(
+	"the counter file '%s' is in use by another renaming operation. Remove '%s' if no other operation is in progress",
+)
+
+var errInvalidCounterFile = errors.New(
+	"the counter file '%s' does not contain a valid number: %w",
+)
+
+// Counter is a file that holds the last number used by the indexing
+// variables so that the numbering continues across renaming operations.
+// The file is locked until Unlock is called so that concurrent operations
+// cannot use the same numbers.
+type Counter struct {
+	path     string
+	lockPath string
+	// Value is the last number used by a previous renaming operation
+	// (zero if the file does not exist yet)
+	Value int
+}
+
+// LockCounter locks the counter file at the specified path (waiting for
+// other renaming operations to release it) and reads its value.
+func LockCounter(path string) (*Counter, error) {
+	c := &Counter{
+		path:     path,
+		lockPath: path + ".lock",
+	}
+
+	deadline := time.Now().Add(counterLockTimeout)
+
+	for {
+		f, err := os.OpenFile(
+			c.lockPath,
+			os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+			0o600,
+		)
+		if err == nil {
+			f.Close()
+			break
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf(errCounterLocked.Error(), path, c.lockPath)
+		}
+
+		time.Sleep(counterLockInterval)
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+
+	if err == nil {
+		c.Value, err = strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			err = fmt.Errorf(errInvalidCounterFile.Error(), path, err)
+		}
+	}
+
+	if err != nil {
+		_ = c.Unlock()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Save records the highest number used by the current renaming operation
+// unless it does not exceed the existing value. The file is replaced
+// atomically so that it is never left partially written.
+func (c *Counter) Save(value int) error {
+	if value <= c.Value {
+		return nil
+	}
+
+	tmpPath := c.path + ".tmp"
+
+	err := os.WriteFile(tmpPath, []byte(strconv.Itoa(value)+"\n"), 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, c.path)
+	if err != nil {
+		return err
+	}
+
+	c.Value = value
+
+	return nil
+}
+
+// Unlock releases the counter file for other renaming operations.
+func (c *Counter) Unlock() error {
+	return os.Remove(c.lockPath)
+}
@@ -0,0 +1,59 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/report"
+)
+
+var errExecAfterFailed = errors.New(
+	"the command after renaming '%s' to '%s' failed: %w",
+)
+
+// RunAfterHook runs the command for each path that was renamed
+// successfully. The {{source}} and {{target}} placeholders in its arguments
+// are replaced with the old and new paths. The command is executed
+// directly (not through a shell) and its output is written to
+// report.Stderr. A failure is reported as a warning unless failFast is
+// set, in which case it is returned and the remaining paths are left out.
+func RunAfterHook(
+	changes []*file.Change,
+	command []string,
+	failFast bool,
+) error {
+	for _, change := range changes {
+		sourcePath := filepath.Join(change.BaseDir, change.Source)
+		targetPath := filepath.Join(change.BaseDir, change.Target)
+
+		if change.Error != nil || change.Status == status.Skipped ||
+			sourcePath == targetPath {
+			continue
+		}
+
+		args := change.CommandArgs(command)
+
+		//nolint:gosec // the command is supplied by the user
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = report.Stderr
+		cmd.Stderr = report.Stderr
+
+		err := cmd.Run()
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf(errExecAfterFailed.Error(), sourcePath, targetPath, err)
+		if failFast {
+			return err
+		}
+
+		report.Warning(err.Error())
+	}
+
+	return nil
+}
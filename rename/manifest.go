@@ -0,0 +1,79 @@
+package rename
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	internaljson "github.com/ayoisaiah/f2/internal/json"
+	"github.com/ayoisaiah/f2/internal/status"
+)
+
+// manifestEntry represents a single renamed file in the manifest.
+type manifestEntry struct {
+	Date       string `json:"date"`
+	Command    string `json:"command"`
+	WorkingDir string `json:"working_dir"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Undo       bool   `json:"undo"`
+}
+
+// appendToManifest records each successful change to the manifest file
+// (one JSON object per line). Unlike backup files which are overwritten on
+// each run, the manifest is never truncated so that it holds the history
+// of every renaming operation.
+func appendToManifest(
+	manifestPath string,
+	changes []*file.Change,
+	revert bool,
+	jsonOpts *internaljson.OutputOpts,
+) (err error) {
+	f, err := os.OpenFile(
+		manifestPath,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0o600,
+	)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		ferr := f.Close()
+		if ferr != nil {
+			err = ferr
+		}
+	}()
+
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+
+	command := strings.Join(os.Args, " ")
+
+	for _, change := range changes {
+		if change.Error != nil || change.Status == status.Skipped ||
+			change.Source == change.Target {
+			continue
+		}
+
+		entry := manifestEntry{
+			Date:       jsonOpts.Date.Format(time.RFC3339),
+			Command:    command,
+			WorkingDir: jsonOpts.WorkingDir,
+			Source:     filepath.Join(change.BaseDir, change.Source),
+			Target:     filepath.Join(change.BaseDir, change.Target),
+			Undo:       revert,
+		}
+
+		err = encoder.Encode(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
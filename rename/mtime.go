@@ -0,0 +1,82 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/report"
+)
+
+var errMtimeNoMatch = errors.New(
+	"the modification time of '%s' was not changed since its name does not match '%s'",
+)
+
+var errMtimeParseFailed = errors.New(
+	"the modification time of '%s' was not changed since '%s' could not be parsed as a date: %w",
+)
+
+var errSetMtimeFailed = errors.New(
+	"unable to set the modification time of '%s': %w",
+)
+
+// SetModTimes sets the modification time of each renamed path to the date
+// in its new name. The date is found with the regular expression (the first
+// capture group is used if there is one) and parsed with the layout in the
+// local time zone. Paths whose date cannot be determined are left untouched
+// with a warning, as are those that were not renamed.
+func SetModTimes(changes []*file.Change, re *regexp.Regexp, layout string) {
+	for _, change := range changes {
+		sourcePath := filepath.Join(change.BaseDir, change.Source)
+		targetPath := filepath.Join(change.BaseDir, change.Target)
+
+		if change.Error != nil || change.Status == status.Skipped ||
+			sourcePath == targetPath {
+			continue
+		}
+
+		match := re.FindStringSubmatch(filepath.Base(change.Target))
+		if match == nil {
+			report.Warning(
+				fmt.Errorf(
+					errMtimeNoMatch.Error(),
+					targetPath,
+					re.String(),
+				).Error(),
+			)
+
+			continue
+		}
+
+		value := match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+
+		modTime, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			report.Warning(
+				fmt.Errorf(
+					errMtimeParseFailed.Error(),
+					targetPath,
+					value,
+					err,
+				).Error(),
+			)
+
+			continue
+		}
+
+		err = os.Chtimes(targetPath, time.Now(), modTime)
+		if err != nil {
+			report.Warning(
+				fmt.Errorf(errSetMtimeFailed.Error(), targetPath, err).Error(),
+			)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package rename
+
+// extendedLengthPath returns the path unchanged since extended-length paths
+// only exist in Windows.
+func extendedLengthPath(path string) string {
+	return path
+}
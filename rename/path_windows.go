@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package rename
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// extendedLengthPath converts the path to an extended-length path which is
+// not subject to the MAX_PATH limit of the Windows API.
+func extendedLengthPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	// UNC paths (\\server\share) use a different prefix
+	if strings.HasPrefix(absPath, `\\`) {
+		return `\\?\UNC\` + absPath[2:]
+	}
+
+	return `\\?\` + absPath
+}
@@ -0,0 +1,100 @@
+package rename
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	internaljson "github.com/ayoisaiah/f2/internal/json"
+	"github.com/ayoisaiah/f2/report"
+)
+
+var errPlanFileNotFound = errors.New(
+	"the plan file '%s' does not exist",
+)
+
+var errInvalidPlanFile = errors.New(
+	"the plan file '%s' is not a valid plan: %w",
+)
+
+var errPlanSourceMissing = errors.New(
+	"the plan is out of date since '%s' no longer exists",
+)
+
+// SavePlan records the changes of a dry run in the plans directory so that
+// they can be committed later through ReadPlan. The plan has the same
+// structure as a backup file.
+func SavePlan(
+	changes []*file.Change,
+	jsonOpts *internaljson.OutputOpts,
+) error {
+	planPath, err := xdg.DataFile(
+		filepath.Join("f2", "plans", dataFileName(jsonOpts.WorkingDir)),
+	)
+	if err != nil {
+		return err
+	}
+
+	b, err := internaljson.GetOutput(jsonOpts, changes, nil)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(planPath, b, 0o600)
+	if err != nil {
+		return err
+	}
+
+	jsonOpts.PlanFile = planPath
+
+	if !jsonOpts.Print && !jsonOpts.HTML {
+		report.PlanSaved(planPath)
+	}
+
+	return nil
+}
+
+// ReadPlan retrieves the changes recorded in the specified plan file.
+// An error is returned if the source of any of the changes no longer exists
+// since the plan cannot be applied as it was reviewed.
+func ReadPlan(planFile string) ([]*file.Change, error) {
+	fileBytes, err := os.ReadFile(planFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf(errPlanFileNotFound.Error(), planFile)
+		}
+
+		return nil, err
+	}
+
+	var o internaljson.Output
+
+	err = json.Unmarshal(fileBytes, &o)
+	if err != nil {
+		return nil, fmt.Errorf(errInvalidPlanFile.Error(), planFile, err)
+	}
+
+	for i, ch := range o.Changes {
+		// Relative paths are resolved against the directory that the
+		// plan was created in
+		if !filepath.IsAbs(ch.BaseDir) {
+			ch.BaseDir = filepath.Join(o.WorkingDir, ch.BaseDir)
+		}
+
+		ch.Index = i
+
+		sourcePath := filepath.Join(ch.BaseDir, ch.Source)
+
+		_, err = os.Lstat(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf(errPlanSourceMissing.Error(), sourcePath)
+		}
+	}
+
+	return o.Changes, nil
+}
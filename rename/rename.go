@@ -18,21 +18,95 @@ import (
 	"github.com/adrg/xdg"
 	"github.com/pterm/pterm"
 
+	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/file"
 	internaljson "github.com/ayoisaiah/f2/internal/json"
 	internalos "github.com/ayoisaiah/f2/internal/os"
 	internalpath "github.com/ayoisaiah/f2/internal/path"
+	"github.com/ayoisaiah/f2/internal/ratelimit"
 	internalsort "github.com/ayoisaiah/f2/internal/sort"
+	"github.com/ayoisaiah/f2/internal/status"
 	"github.com/ayoisaiah/f2/report"
 )
 
 var errs []int
 
+var errBackupDirUnwritable = errors.New(
+	"unable to write the backup file to '%s' due to error: %w. Use --backup-fallback to write it to the temporary directory instead",
+)
+
+var errOverwriteNotConfirmed = errors.New(
+	"the renaming operation was aborted since overwriting the existing paths was not confirmed",
+)
+
+var errOverwriteNeedsYes = errors.New(
+	"the renaming operation will overwrite existing paths: use --yes to confirm in quiet, JSON, or HTML mode",
+)
+
+// missingDirs returns the directories in the provided path (starting from
+// the deepest one) that do not exist yet.
+func missingDirs(dir string) []string {
+	var dirs []string
+
+	for {
+		_, err := os.Stat(dir)
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+
+		dirs = append(dirs, dir)
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+
+		dir = parent
+	}
+
+	return dirs
+}
+
+// tempPathPrefix is the prefix of the temporary names used by two-phase
+// renames.
+const tempPathPrefix = ".f2-tmp-"
+
+// tempPath returns a path in dir for the temporary name of a two-phase
+// rename. The name is derived from the original one and a counter which is
+// incremented until the resulting path does not exist, so the same name is
+// produced for the same state of the filesystem.
+func tempPath(dir, name string) (string, error) {
+	for i := 0; ; i++ {
+		path := filepath.Join(
+			dir,
+			fmt.Sprintf("%s%d-%s", tempPathPrefix, i, name),
+		)
+
+		_, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
 // rename iterates over all the matches and renames them on the filesystem.
 // Directories are auto-created if necessary, and errors are aggregated.
+// If failFast is set, the operation stops at the first error and the
+// remaining changes are marked as skipped. The number of directories that
+// were created is also returned. If longPaths is set, extended-length paths
+// are used so that the MAX_PATH limit does not apply in Windows. Created
+// directories have the dirMode permissions (before the umask). Each rename
+// waits for the limiter (if any).
 func rename(
 	changes []*file.Change,
-) []int {
+	dirMode os.FileMode,
+	limiter *ratelimit.Limiter,
+	failFast, longPaths bool,
+) (errs []int, dirsCreated int) {
 	for i := range changes {
 		change := changes[i]
 
@@ -44,20 +118,38 @@ func rename(
 			continue
 		}
 
+		if longPaths {
+			sourcePath = extendedLengthPath(sourcePath)
+			targetPath = extendedLengthPath(targetPath)
+		}
+
 		// Account for case insensitive filesystems where renaming a filename to its
 		// upper or lowercase equivalent doesn't work. Fixing this involves the
 		// following steps:
-		// 1. Prefix <target> with __<time>__ if case insensitive FS
+		// 1. Choose a temporary name for <target> if case insensitive FS
 		// 2. Rename <source> to <target>
-		// 3. Rename __<time>__<target> to <target> if case insensitive FS
+		// 3. Rename the temporary name to <target> if case insensitive FS
 		var caseInsensitiveFS bool
 		if strings.EqualFold(sourcePath, targetPath) {
 			caseInsensitiveFS = true
-			timeStr := fmt.Sprintf("%d", time.Now().UnixNano())
-			targetPath = filepath.Join(
-				change.BaseDir,
-				"__"+timeStr+"__"+change.Target, // step 1
-			)
+
+			tmp, err := tempPath(
+				filepath.Dir(targetPath),
+				filepath.Base(targetPath),
+			) // step 1
+			if err != nil {
+				errs = append(errs, i)
+				change.Error = err
+
+				if failFast {
+					skip(changes[i+1:])
+					break
+				}
+
+				continue
+			}
+
+			targetPath = tmp
 		}
 
 		// If target contains a slash, create all missing
@@ -66,64 +158,154 @@ func rename(
 			strings.Contains(change.Target, `\`) &&
 				runtime.GOOS == internalos.Windows {
 			// No need to check if the `dir` exists or if there are several
-			// consecutive slashes since `os.MkdirAll` handles that
-			dir := filepath.Dir(change.Target)
+			// consecutive slashes since `os.MkdirAll` handles that. The
+			// missing directories are only retrieved to be counted
+			dir := filepath.Dir(targetPath)
+
+			missing := missingDirs(dir)
 
-			//nolint:gomnd // number can be understood from context
-			err := os.MkdirAll(filepath.Join(change.BaseDir, dir), 0o750)
+			err := os.MkdirAll(dir, dirMode)
 			if err != nil {
 				errs = append(errs, i)
 				change.Error = err
 
+				if failFast {
+					skip(changes[i+1:])
+					break
+				}
+
 				continue
 			}
+
+			dirsCreated += len(missing)
 		}
 
+		limiter.Wait()
+
 		err := os.Rename(sourcePath, targetPath) // step 2
 		// if the intermediate rename is successful,
 		// proceed with the original renaming operation
 		if err == nil && caseInsensitiveFS {
 			orginalTarget := filepath.Join(change.BaseDir, change.Target)
+			if longPaths {
+				orginalTarget = extendedLengthPath(orginalTarget)
+			}
 
 			err = os.Rename(targetPath, orginalTarget) // step 3
+			if err != nil {
+				// Restore the source so that the temporary name is not left
+				// behind
+				_ = os.Rename(targetPath, sourcePath)
+			}
+		}
+
+		if errors.Is(err, os.ErrNotExist) &&
+			movedWithFile(change, sourcePath, targetPath) {
+			err = nil
 		}
 
 		if err != nil {
 			errs = append(errs, i)
 			change.Error = err
 
+			if failFast {
+				skip(changes[i+1:])
+				break
+			}
+
 			continue
 		}
+
+		change.Applied = true
 	}
 
-	return errs
+	return errs, dirsCreated
 }
 
-// backupChanges records the details of a renaming operation to the filesystem
-// so that it may be reverted if necessary.
-func backupChanges(
-	changes []*file.Change,
-	errs []int,
-	jsonOpts *internaljson.OutputOpts,
-) error {
-	workingDir := strings.ReplaceAll(
-		jsonOpts.WorkingDir,
-		internalpath.Separator,
-		"_",
-	)
-	if runtime.GOOS == internalos.Windows {
-		workingDir = strings.ReplaceAll(workingDir, ":", "_")
+// skip marks the provided changes as skipped so that they are
+// not recorded as successful.
+func skip(changes []*file.Change) {
+	for i := range changes {
+		changes[i].Status = status.Skipped
+		changes[i].Applied = false
 	}
+}
 
-	filename := workingDir + ".json"
+// isWritable reports whether files can be created in the directory.
+func isWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".f2-")
+	if err != nil {
+		return err
+	}
 
-	backupFilePath, err := xdg.DataFile(
-		filepath.Join("f2", "backups", filename),
-	)
+	err = f.Close()
 	if err != nil {
 		return err
 	}
 
+	return os.Remove(f.Name())
+}
+
+// dataFileName returns the name of the file in which the data for the
+// working directory (such as a backup) is stored.
+func dataFileName(workingDir string) string {
+	name := strings.ReplaceAll(workingDir, internalpath.Separator, "_")
+	if runtime.GOOS == internalos.Windows {
+		name = strings.ReplaceAll(name, ":", "_")
+	}
+
+	return name + ".json"
+}
+
+// BackupPath returns the path to the backup file for a renaming operation in
+// the working directory. It ensures that the backup directory is writable
+// before any file is renamed so that the operation can always be reverted.
+// If it isn't, an error is returned unless fallback is set in which case
+// the backup file is written to the temporary directory instead.
+func BackupPath(workingDir string, fallback bool) (string, error) {
+	relPath := filepath.Join("f2", "backups", dataFileName(workingDir))
+
+	backupFilePath, err := xdg.DataFile(relPath)
+	if err == nil {
+		err = isWritable(filepath.Dir(backupFilePath))
+		if err == nil {
+			return backupFilePath, nil
+		}
+	}
+
+	if !fallback {
+		return "", fmt.Errorf(
+			errBackupDirUnwritable.Error(),
+			filepath.Join(xdg.DataHome, filepath.Dir(relPath)),
+			err,
+		)
+	}
+
+	backupFilePath = filepath.Join(os.TempDir(), relPath)
+
+	err = os.MkdirAll(filepath.Dir(backupFilePath), 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	report.Warning(
+		fmt.Sprintf(
+			"The backup directory is not writable so the backup file will be written to '%s'. Use --undo-file to revert the operation",
+			backupFilePath,
+		),
+	)
+
+	return backupFilePath, nil
+}
+
+// backupChanges records the details of a renaming operation to the filesystem
+// so that it may be reverted if necessary.
+func backupChanges(
+	changes []*file.Change,
+	errs []int,
+	backupFilePath string,
+	jsonOpts *internaljson.OutputOpts,
+) error {
 	// Create or truncate backupFile
 	backupFile, err := os.Create(backupFilePath)
 	if err != nil {
@@ -141,9 +323,10 @@ func backupChanges(
 
 	copy(successfulChanges, changes)
 
-	// remove files that errored out
+	// remove files that errored out or were skipped
 	for i := len(successfulChanges) - 1; i >= 0; i-- {
-		if successfulChanges[i].Error != nil {
+		if successfulChanges[i].Error != nil ||
+			successfulChanges[i].Status == status.Skipped {
 			successfulChanges = append(
 				successfulChanges[:i],
 				successfulChanges[i+1:]...)
@@ -162,26 +345,78 @@ func backupChanges(
 		return err
 	}
 
-	return writer.Flush()
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	jsonOpts.BackupFile = backupFilePath
+
+	return nil
+}
+
+// getStats summarises the outcome of the renaming operation
+// that started at the provided time.
+func getStats(
+	changes []*file.Change,
+	errs []int,
+	dirsCreated int,
+	start time.Time,
+) *internaljson.Stats {
+	stats := &internaljson.Stats{
+		Failed:             len(errs),
+		DirectoriesCreated: dirsCreated,
+		Elapsed:            time.Since(start).Round(time.Millisecond).String(),
+	}
+
+	for _, change := range changes {
+		switch {
+		case change.Error != nil:
+		case change.Status == status.Skipped,
+			filepath.Join(change.BaseDir, change.Source) ==
+				filepath.Join(change.BaseDir, change.Target):
+			stats.Skipped++
+		default:
+			stats.Renamed++
+		}
+	}
+
+	return stats
 }
 
 // commit applies the renaming operation to the filesystem.
 // A backup file is auto created as long as at least one file
-// was renamed and it wasn't an undo operation.
+// was renamed and it wasn't an undo operation. The renamed files are
+// also appended to the manifest file if one is provided.
 func commit(
 	changes []*file.Change,
-	revert, verbose bool,
+	manifestPath, backupPath string,
+	dirMode os.FileMode,
+	limiter *ratelimit.Limiter,
+	failFast, longPaths, quiet, revert, verbose bool,
 	jsonOpts *internaljson.OutputOpts,
 ) []int {
 	changes = internalsort.FilesBeforeDirs(changes, revert)
 
-	errs = rename(changes)
+	var dirsCreated int
+
+	errs, dirsCreated = rename(
+		changes,
+		dirMode,
+		limiter,
+		failFast,
+		longPaths,
+	)
 
 	if verbose {
 		for _, change := range changes {
 			sourcePath := filepath.Join(change.BaseDir, change.Source)
 			targetPath := filepath.Join(change.BaseDir, change.Target)
 
+			if change.Status == status.Skipped {
+				continue
+			}
+
 			if change.Error != nil {
 				pterm.Fprintln(report.Stderr,
 					pterm.Error.Sprintf(
@@ -195,7 +430,7 @@ func commit(
 			}
 
 			pterm.Fprintln(report.Stderr,
-				pterm.Success.Printfln(
+				pterm.Success.Sprintf(
 					"Renamed '%s' to '%s'",
 					pterm.Yellow(sourcePath),
 					pterm.Yellow(targetPath),
@@ -205,12 +440,27 @@ func commit(
 	}
 
 	if !revert {
-		err := backupChanges(changes, errs, jsonOpts)
+		err := backupChanges(changes, errs, backupPath, jsonOpts)
 		if err != nil {
 			report.BackupFailed(err)
+		} else if !quiet && !jsonOpts.Print && !jsonOpts.HTML {
+			report.BackupCreated(jsonOpts.BackupFile)
 		}
 	}
 
+	if manifestPath != "" {
+		err := appendToManifest(manifestPath, changes, revert, jsonOpts)
+		if err != nil {
+			report.ManifestFailed(err)
+		}
+	}
+
+	jsonOpts.Stats = getStats(changes, errs, dirsCreated, jsonOpts.Date)
+
+	if !quiet && !jsonOpts.Print && !jsonOpts.HTML {
+		report.Summary(jsonOpts.Stats)
+	}
+
 	if len(errs) > 0 {
 		sort.SliceStable(changes, func(i, _ int) bool {
 			compareElement1 := changes[i]
@@ -222,15 +472,70 @@ func commit(
 	return errs
 }
 
+// confirmOverwrites lists the existing paths that will be overwritten by the
+// renaming operation and requires the user to type 'yes' before proceeding.
+// Since the prompt cannot be shown in quiet, JSON, or HTML mode, the
+// operation is aborted in those modes.
+func confirmOverwrites(
+	changes []*file.Change,
+	stdin io.Reader,
+	quiet bool,
+	jsonOpts *internaljson.OutputOpts,
+) error {
+	var overwrites []string
+
+	for _, change := range changes {
+		if change.WillOverwrite {
+			overwrites = append(
+				overwrites,
+				filepath.Join(change.BaseDir, change.Target),
+			)
+		}
+	}
+
+	if len(overwrites) == 0 {
+		return nil
+	}
+
+	if quiet || jsonOpts.Print || jsonOpts.HTML {
+		return errOverwriteNeedsYes
+	}
+
+	pterm.Fprintln(report.Stderr,
+		pterm.Warning.Sprint("The following paths will be overwritten:"),
+	)
+
+	for _, path := range overwrites {
+		fmt.Fprintln(report.Stderr, path)
+	}
+
+	fmt.Fprint(report.Stderr, "Type 'yes' to proceed: ")
+
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if strings.TrimSpace(answer) != "yes" {
+		return errOverwriteNotConfirmed
+	}
+
+	return nil
+}
+
 // Execute prints the changes to be made in dry-run mode
 // or commits the operation to the filesystem if in execute mode.
+// Unless conf.Yes is set, the user must confirm the operation if
+// existing paths will be overwritten. The operation is recorded in the
+// backup file at backupPath.
 func Execute(
 	changes []*file.Change,
-	simpleMode, quiet, revert, verbose bool,
+	conf *config.Config,
+	backupPath string,
 	jsonOpts *internaljson.OutputOpts,
-) []int {
-	if simpleMode {
-		report.Changes(changes, nil, quiet, jsonOpts)
+) ([]int, error) {
+	if conf.SimpleMode {
+		report.Changes(changes, nil, conf.Quiet, jsonOpts)
 
 		reader := bufio.NewReader(os.Stdin)
 
@@ -240,12 +545,31 @@ func Execute(
 		// Block until user input before beginning next session
 		_, err := reader.ReadString('\n')
 		if err != nil && !errors.Is(err, io.EOF) {
-			pterm.Fprintln(report.Stderr, pterm.Error.Print(err))
-			return nil
+			pterm.Fprintln(report.Stderr, pterm.Error.Sprint(err))
+			return nil, nil
+		}
+	}
+
+	if !conf.Yes {
+		err := confirmOverwrites(changes, conf.Stdin, conf.Quiet, jsonOpts)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return commit(changes, revert, verbose, jsonOpts)
+	return commit(
+		changes,
+		conf.Manifest,
+		backupPath,
+		conf.DirMode,
+		conf.RateLimiter,
+		conf.FailFast,
+		conf.LongPaths,
+		conf.Quiet,
+		conf.Revert,
+		conf.Verbose,
+		jsonOpts,
+	), nil
 }
 
 func GetErrs() []int {
@@ -6,17 +6,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
-	"github.com/pterm/pterm"
 
+	"github.com/ayoisaiah/f2/internal/config"
+	"github.com/ayoisaiah/f2/internal/file"
 	internaljson "github.com/ayoisaiah/f2/internal/json"
-	internalos "github.com/ayoisaiah/f2/internal/os"
-	internalpath "github.com/ayoisaiah/f2/internal/path"
 	internalsort "github.com/ayoisaiah/f2/internal/sort"
 	"github.com/ayoisaiah/f2/report"
+	"github.com/ayoisaiah/f2/validate"
 )
 
 var errUndoFailed = errors.New(
@@ -27,38 +26,132 @@ var errNothingToUndo = errors.New(
 	"nothing to undo",
 )
 
+var errRestoreTimesFailed = errors.New(
+	"unable to restore the modification time of '%s': %w",
+)
+
+var errUndoFileNotFound = errors.New(
+	"the undo file '%s' does not exist",
+)
+
+var errInvalidUndoFile = errors.New(
+	"the undo file '%s' is not a valid backup file: %w",
+)
+
+var errUndoConflicts = errors.New(
+	"the renaming operation cannot be reverted due to the above conflicts",
+)
+
 var errBackupFileRemovalFailed = errors.New(
 	"unable to remove redundant backup file '%s' after reverting the changes. Please remove it manually",
 )
 
-// Undo reverses a renaming operation according to the relevant backup file.
-// The undo file is deleted if the operation is successfully reverted.
-func Undo(
-	exec, includeDir, quiet, revert, verbose bool,
-	jsonOpts *internaljson.OutputOpts,
-) error {
-	dir := strings.ReplaceAll(jsonOpts.WorkingDir, internalpath.Separator, "_")
-	if runtime.GOOS == internalos.Windows {
-		dir = strings.ReplaceAll(dir, ":", "_")
-	}
+// restoreTimes sets the modification time of each reverted path to the
+// one recorded in the backup file.
+func restoreTimes(changes []*file.Change) {
+	for _, change := range changes {
+		if change.ModTime == nil || change.Error != nil {
+			continue
+		}
 
-	file := dir + ".json"
+		targetPath := filepath.Join(change.BaseDir, change.Target)
 
+		err := os.Chtimes(targetPath, time.Now(), *change.ModTime)
+		if err != nil {
+			report.Warning(
+				fmt.Errorf(
+					errRestoreTimesFailed.Error(),
+					targetPath,
+					err,
+				).Error(),
+			)
+		}
+	}
+}
+
+// retrieveBackupFile returns the path to the backup file
+// for the last renaming operation in the working directory.
+func retrieveBackupFile(workingDir string) (string, error) {
 	backupFilePath, err := xdg.SearchDataFile(
-		filepath.Join("f2", "backups", file),
+		filepath.Join("f2", "backups", dataFileName(workingDir)),
 	)
 	if err != nil {
-		return errNothingToUndo
+		return "", errNothingToUndo
 	}
 
-	fileBytes, err := os.ReadFile(backupFilePath)
+	return backupFilePath, nil
+}
+
+// LastRun returns the date of the last renaming operation in the working
+// directory according to its backup file. The zero time is returned if
+// there is no such operation (for example, if it was reverted).
+func LastRun(workingDir string) (time.Time, error) {
+	backupFilePath, err := retrieveBackupFile(workingDir)
 	if err != nil {
-		return err
+		return time.Time{}, nil
+	}
+
+	o, err := readUndoFile(backupFilePath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := time.Parse(time.RFC3339, o.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			errInvalidUndoFile.Error(),
+			backupFilePath,
+			err,
+		)
+	}
+
+	return date, nil
+}
+
+// readUndoFile reads the renaming operation recorded in the
+// specified backup file.
+func readUndoFile(undoFile string) (*internaljson.Output, error) {
+	fileBytes, err := os.ReadFile(undoFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf(errUndoFileNotFound.Error(), undoFile)
+		}
+
+		return nil, err
 	}
 
 	var o internaljson.Output
 
 	err = json.Unmarshal(fileBytes, &o)
+	if err != nil {
+		return nil, fmt.Errorf(errInvalidUndoFile.Error(), undoFile, err)
+	}
+
+	return &o, nil
+}
+
+// Undo reverses a renaming operation according to the relevant backup file.
+// The reversal is checked for conflicts first and nothing is modified
+// without exec, including the backup file.
+// The backup file is deleted if the operation is successfully reverted
+// unless it was explicitly provided through conf.UndoFile in which case the
+// working directory is not used to find it. If conf.RestoreTimes is set,
+// the modification times recorded in the backup file are applied to the
+// reverted paths. Like the renaming operation, any directories that need
+// to be recreated are created with conf.DirMode.
+func Undo(conf *config.Config, jsonOpts *internaljson.OutputOpts) error {
+	backupFilePath := conf.UndoFile
+
+	if backupFilePath == "" {
+		var err error
+
+		backupFilePath, err = retrieveBackupFile(jsonOpts.WorkingDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	o, err := readUndoFile(backupFilePath)
 	if err != nil {
 		return err
 	}
@@ -74,31 +167,82 @@ func Undo(
 		ch.Source = target
 		ch.Target = source
 
+		// The changes were applied by the operation that is being reverted
+		// but the reversal has not been applied yet
+		ch.Applied = false
+
+		// Relative paths in an explicitly provided backup file are resolved
+		// against the directory that the operation was performed in
+		if conf.UndoFile != "" && !filepath.IsAbs(ch.BaseDir) {
+			ch.BaseDir = filepath.Join(o.WorkingDir, ch.BaseDir)
+		}
+
 		changes[i] = ch
 	}
 
-	internalsort.FilesBeforeDirs(changes, revert)
+	internalsort.FilesBeforeDirs(changes, conf.Revert)
 
-	if !exec {
-		report.Dry(changes, includeDir, quiet, revert, jsonOpts)
+	for i := range changes {
+		changes[i].Index = i
+	}
+
+	// The filesystem may have changed since the operation was performed
+	// so the reversal is checked for conflicts before it is previewed or
+	// committed. Conflicts are never fixed automatically here since the
+	// point is to restore the original names
+	conflicts := validate.Validate(changes, &config.Config{
+		WorkingDir: jsonOpts.WorkingDir,
+		LongPaths:  conf.LongPaths,
+	})
+	if len(conflicts) > 0 {
+		report.Conflicts(conflicts, jsonOpts)
+		return errUndoConflicts
+	}
+
+	if !conf.Exec {
+		report.Dry(changes, conf.IncludeDir, conf.Quiet, conf.Revert, jsonOpts)
 
 		return nil
 	}
 
-	errs := commit(changes, revert, verbose, jsonOpts)
+	errs := commit(
+		changes,
+		conf.Manifest,
+		"",
+		conf.DirMode,
+		conf.RateLimiter,
+		false,
+		conf.LongPaths,
+		conf.Quiet,
+		conf.Revert,
+		conf.Verbose,
+		jsonOpts,
+	)
+	if conf.RestoreTimes {
+		restoreTimes(changes)
+	}
+
 	if len(errs) > 0 {
-		report.Changes(changes, errs, quiet, jsonOpts)
+		report.Changes(changes, errs, conf.Quiet, jsonOpts)
 		return errUndoFailed
 	}
 
-	if exec {
+	// The changes have been reverted at this point so failing to remove the
+	// backup file is not treated as an error
+	if conf.Exec && conf.UndoFile == "" {
 		if err = os.Remove(backupFilePath); err != nil {
-			return fmt.Errorf(
-				errBackupFileRemovalFailed.Error(),
-				pterm.LightYellow(backupFilePath),
+			report.Warning(
+				fmt.Errorf(
+					errBackupFileRemovalFailed.Error(),
+					backupFilePath,
+				).Error(),
 			)
 		}
 	}
 
+	if jsonOpts.Print || jsonOpts.HTML {
+		report.Changes(changes, nil, conf.Quiet, jsonOpts)
+	}
+
 	return nil
 }
@@ -0,0 +1,42 @@
+package replace
+
+import (
+	"path/filepath"
+
+	"github.com/ayoisaiah/f2/find"
+	"github.com/ayoisaiah/f2/internal/file"
+)
+
+// addAppleDoubles inserts a change after each change whose source has an
+// AppleDouble file (see find.GetAppleDoubles) so that the AppleDouble file
+// is renamed to match the target. The AppleDouble files of the paths that
+// are left unchanged are not renamed.
+func addAppleDoubles(changes []*file.Change) []*file.Change {
+	appleDoubles := find.GetAppleDoubles()
+
+	result := make([]*file.Change, 0, len(changes))
+
+	for _, change := range changes {
+		result = append(result, change)
+
+		name, ok := appleDoubles[filepath.Join(change.BaseDir, change.Source)]
+		if !ok || change.Source == change.Target {
+			continue
+		}
+
+		source := filepath.Join(filepath.Dir(change.Source), name)
+
+		result = append(result, &file.Change{
+			BaseDir:        change.BaseDir,
+			Source:         source,
+			OriginalSource: source,
+			Target:         file.AppleDoublePath(change.Target),
+			Status:         change.Status,
+			Root:           change.Root,
+			Depth:          change.Depth,
+			AppleDoubleOf:  change,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,136 @@
+package replace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	internalos "github.com/ayoisaiah/f2/internal/os"
+	"github.com/ayoisaiah/f2/internal/status"
+)
+
+var errEditorFailed = errors.New(
+	"the editor exited with an error: %w",
+)
+
+var errEmptyEdit = errors.New(
+	"the edited file is empty: aborting the renaming operation",
+)
+
+var errInvalidEditLine = errors.New(
+	"invalid line %d in the edited file: %s. Each line must start with the number of the match it refers to",
+)
+
+// editor returns the command for the user's preferred text editor.
+func editor() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+
+	if runtime.GOOS == internalos.Windows {
+		return []string{"notepad"}
+	}
+
+	return []string{"vi"}
+}
+
+// editTargets writes the target of each change to a temporary file
+// (one per line, prefixed with its number) and opens it in the user's editor.
+// The edited targets are then assigned to the corresponding changes.
+// Changes whose lines are deleted from the file are skipped.
+func editTargets(changes []*file.Change) ([]*file.Change, error) {
+	f, err := os.CreateTemp("", "f2-edit-*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+
+	for i, change := range changes {
+		fmt.Fprintf(w, "%d\t%s\n", i+1, filepath.ToSlash(change.Target))
+	}
+
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	args := editor()
+	args = append(args, f.Name())
+
+	//nolint:gosec // the editor is supplied by the user
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf(errEditorFailed.Error(), err)
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(string(b)) == "" {
+		return nil, errEmptyEdit
+	}
+
+	edited := make(map[int]string, len(changes))
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		num, target, found := strings.Cut(line, "\t")
+
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !found || err != nil || n < 1 || n > len(changes) {
+			return nil, fmt.Errorf(errInvalidEditLine.Error(), i+1, line)
+		}
+
+		if _, exists := edited[n-1]; exists {
+			return nil, fmt.Errorf(errInvalidEditLine.Error(), i+1, line)
+		}
+
+		edited[n-1] = target
+	}
+
+	result := make([]*file.Change, 0, len(edited))
+
+	for i, change := range changes {
+		target, exists := edited[i]
+		if !exists {
+			continue
+		}
+
+		change.Index = len(result)
+		change.Target = strings.TrimSpace(filepath.Clean(target))
+		change.Status = status.OK
+
+		result = append(result, change)
+	}
+
+	return result, nil
+}
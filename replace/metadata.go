@@ -0,0 +1,200 @@
+package replace
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/hash"
+	"github.com/ayoisaiah/f2/internal/ratelimit"
+)
+
+// metadataCache holds the exif data, id3 tags, text statistics, image
+// dimensions, and video metadata that have been retrieved so that each one is
+// read at most once per file.
+type metadataCache struct {
+	exif   map[string]*Exif
+	id3    map[string]*ID3
+	stats  map[string]*TextStats
+	images map[string]*ImageSize
+	videos map[string]*VideoInfo
+	mu     sync.RWMutex
+}
+
+var metadata = newMetadataCache()
+
+func newMetadataCache() *metadataCache {
+	return &metadataCache{
+		exif:   make(map[string]*Exif),
+		id3:    make(map[string]*ID3),
+		stats:  make(map[string]*TextStats),
+		images: make(map[string]*ImageSize),
+		videos: make(map[string]*VideoInfo),
+	}
+}
+
+func (m *metadataCache) exifData(path string) (*Exif, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.exif[path]
+
+	return v, ok
+}
+
+func (m *metadataCache) setExifData(path string, v *Exif) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.exif[path] = v
+}
+
+func (m *metadataCache) id3Tags(path string) (*ID3, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.id3[path]
+
+	return v, ok
+}
+
+func (m *metadataCache) setID3Tags(path string, v *ID3) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.id3[path] = v
+}
+
+func (m *metadataCache) textStats(path string) (*TextStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.stats[path]
+
+	return v, ok
+}
+
+func (m *metadataCache) setTextStats(path string, v *TextStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats[path] = v
+}
+
+func (m *metadataCache) imageSize(path string) (*ImageSize, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.images[path]
+
+	return v, ok
+}
+
+func (m *metadataCache) setImageSize(path string, v *ImageSize) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.images[path] = v
+}
+
+func (m *metadataCache) videoInfo(path string) (*VideoInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.videos[path]
+
+	return v, ok
+}
+
+func (m *metadataCache) setVideoInfo(path string, v *VideoInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.videos[path] = v
+}
+
+// prefetchMetadata reads the hashes, exif data, id3 tags, text statistics,
+// image dimensions, and video metadata required by the variables in the
+// replacement for each change using a pool of workers that share the
+// limiter (if any).
+// The results are cached so that the substitution of the variables does not
+// have to wait on the slow I/O. Errors are ignored here since they are
+// encountered again (and reported) during the substitution.
+func prefetchMetadata(
+	changes []*file.Change,
+	vars *variables,
+	workers int,
+	statMaxBytes int64,
+	limiter *ratelimit.Limiter,
+) {
+	algorithms := make([]hash.Algorithm, 0, len(vars.hash.matches))
+	for i := range vars.hash.matches {
+		algorithms = append(algorithms, vars.hash.matches[i].hashFn)
+	}
+
+	needsExif := len(vars.exif.matches) > 0
+	needsID3 := len(vars.id3.matches) > 0
+	needsStats := len(vars.stat.matches) > 0
+	needsImages := len(vars.image.matches) > 0
+	needsVideos := len(vars.video.matches) > 0
+
+	if len(algorithms) == 0 && !needsExif && !needsID3 && !needsStats &&
+		!needsImages && !needsVideos {
+		return
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	paths := make(chan string)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for path := range paths {
+				limiter.Wait()
+
+				for _, algorithm := range algorithms {
+					_, _ = hash.File(path, algorithm)
+				}
+
+				if needsExif {
+					_, _ = getExifData(path)
+				}
+
+				if needsID3 {
+					_, _ = getID3Tags(path)
+				}
+
+				if needsStats {
+					_, _ = getTextStats(path, statMaxBytes)
+				}
+
+				if needsImages {
+					_, _ = getImageSize(path)
+				}
+
+				if needsVideos {
+					_, _ = getVideoInfo(path)
+				}
+			}
+		}()
+	}
+
+	for _, change := range changes {
+		if change.IsDir {
+			continue
+		}
+
+		paths <- filepath.Join(change.BaseDir, change.OriginalSource)
+	}
+
+	close(paths)
+	wg.Wait()
+}
@@ -4,7 +4,10 @@
 package replace
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"math"
 	"path/filepath"
 	"regexp"
@@ -14,13 +17,44 @@ import (
 	"github.com/ayoisaiah/f2/find"
 	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/hash"
 	internalpath "github.com/ayoisaiah/f2/internal/path"
+	"github.com/ayoisaiah/f2/internal/pattern"
 	"github.com/ayoisaiah/f2/internal/sort"
 	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/report"
 )
 
 var errInvalidSubmatches = errors.New("Invalid number of submatches")
 
+var errInvalidWidthSpec = errors.New(
+	"invalid width specification '%s': expected width=<positive integer> optionally followed by :align=<left|right|center> and :fill=<character>",
+)
+
+var errNoOpChain = errors.New(
+	"the find and replace chain does not change any of the matched names: review the patterns since the replacements may cancel each other out",
+)
+
+var errTargetMoved = errors.New(
+	"the target of '%s' (%s) is outside its original directory: use --allow-move to rename it anyway",
+)
+
+var errRandomExhausted = errors.New(
+	"could not generate a value for %s in '%s' that was not already used for another file: increase its length or use a larger character set",
+)
+
+var errStdinNamesMismatch = errors.New(
+	"expected %d names from the standard input (one per match), but got %d",
+)
+
+// highestIndex is the highest number substituted for an indexing variable
+// in the current renaming operation.
+var highestIndex int
+
+// issuedRandom maps each value generated for a random variable in the
+// current renaming operation to the source of the change it was issued to.
+var issuedRandom map[string]string
+
 type numbersToSkip struct {
 	min int
 	max int
@@ -37,6 +71,7 @@ type indexVarMatch struct {
 		value int
 	}
 	startNumber int
+	startIsSet  bool // the start number is specified in the variable
 }
 
 type indexVars struct {
@@ -105,8 +140,9 @@ type dateVars struct {
 
 type hashVarMatch struct {
 	regex          *regexp.Regexp
-	hashFn         hashAlgorithm
+	hashFn         hash.Algorithm
 	transformToken string
+	length         int // the number of leading characters to keep (all if zero)
 	val            []string
 }
 
@@ -114,6 +150,61 @@ type hashVars struct {
 	matches []hashVarMatch
 }
 
+type statVarMatch struct {
+	regex          *regexp.Regexp
+	stat           string
+	transformToken string
+	val            []string
+}
+
+type statVars struct {
+	matches []statVarMatch
+}
+
+type imageVarMatch struct {
+	regex          *regexp.Regexp
+	attr           string
+	transformToken string
+	val            []string
+}
+
+type imageVars struct {
+	matches []imageVarMatch
+}
+
+type videoVarMatch struct {
+	regex          *regexp.Regexp
+	attr           string
+	transformToken string
+	val            []string
+}
+
+type videoVars struct {
+	matches []videoVarMatch
+}
+
+type alphaVarMatch struct {
+	regex          *regexp.Regexp
+	transformToken string
+	start          int
+	upper          bool
+}
+
+type alphaVars struct {
+	matches []alphaVarMatch
+}
+
+type romanVarMatch struct {
+	regex          *regexp.Regexp
+	transformToken string
+	start          int
+	lower          bool
+}
+
+type romanVars struct {
+	matches []romanVarMatch
+}
+
 type randomVarMatch struct {
 	regex          *regexp.Regexp
 	characters     string
@@ -146,6 +237,15 @@ type filenameVars struct {
 	matches []filenameVarMatch
 }
 
+type origVarMatch struct {
+	regex          *regexp.Regexp
+	transformToken string
+}
+
+type origVars struct {
+	matches []origVarMatch
+}
+
 type extVarMatch struct {
 	regex          *regexp.Regexp
 	transformToken string
@@ -169,13 +269,19 @@ type variables struct {
 	exif      exifVars
 	exiftool  exiftoolVars
 	index     indexVars
+	alpha     alphaVars
+	roman     romanVars
 	id3       id3Vars
 	hash      hashVars
+	stat      statVars
+	image     imageVars
+	video     videoVars
 	date      dateVars
 	random    randomVars
 	transform transformVars
 	csv       csvVars
 	filename  filenameVars
+	orig      origVars
 	ext       extVars
 	parentDir parentDirVars
 }
@@ -195,7 +301,7 @@ func getCSVVars(replacementInput string) (csvVars, error) {
 
 			var match csvVarMatch
 
-			regex, err := regexp.Compile(submatch[0])
+			regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 			if err != nil {
 				return csv, err
 			}
@@ -239,7 +345,7 @@ func getDateVars(replacementInput string) (dateVars, error) {
 
 		var match dateVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return dateVarMatches, err
 		}
@@ -269,7 +375,7 @@ func getHashVars(replacementInput string) (hashVars, error) {
 		replacementInput,
 		-1,
 	)
-	expectedLength := 3
+	expectedLength := 4
 
 	for _, submatch := range submatches {
 		if len(submatch) < expectedLength {
@@ -278,15 +384,22 @@ func getHashVars(replacementInput string) (hashVars, error) {
 
 		var match hashVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return hashMatches, err
 		}
 
 		match.regex = regex
 		match.val = submatch
-		match.hashFn = hashAlgorithm(submatch[1])
-		match.transformToken = submatch[2]
+		match.hashFn = hash.Algorithm(submatch[1])
+		match.transformToken = submatch[3]
+
+		if submatch[2] != "" {
+			match.length, err = strconv.Atoi(submatch[2])
+			if err != nil {
+				return hashMatches, err
+			}
+		}
 
 		hashMatches.matches = append(hashMatches.matches, match)
 	}
@@ -294,6 +407,200 @@ func getHashVars(replacementInput string) (hashVars, error) {
 	return hashMatches, nil
 }
 
+// getAlphaVars retrieves all the alphabetical indexing variables in the
+// replacement string if any.
+func getAlphaVars(replacementInput string) (alphaVars, error) {
+	var alphaMatches alphaVars
+
+	if !alphaVarRegex.MatchString(replacementInput) {
+		return alphaMatches, nil
+	}
+
+	submatches := alphaVarRegex.FindAllStringSubmatch(replacementInput, -1)
+	expectedLength := 3
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return alphaMatches, errInvalidSubmatches
+		}
+
+		var match alphaVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return alphaMatches, err
+		}
+
+		match.regex = regex
+		match.transformToken = submatch[2]
+
+		if start := submatch[1]; start != "" {
+			match.start = alphaToInteger(start)
+			match.upper = strings.ToUpper(start) == start
+		}
+
+		alphaMatches.matches = append(alphaMatches.matches, match)
+	}
+
+	return alphaMatches, nil
+}
+
+// getRomanVars retrieves all the roman numeral indexing variables in the
+// replacement string if any.
+func getRomanVars(replacementInput string) (romanVars, error) {
+	var romanMatches romanVars
+
+	if !romanVarRegex.MatchString(replacementInput) {
+		return romanMatches, nil
+	}
+
+	submatches := romanVarRegex.FindAllStringSubmatch(replacementInput, -1)
+	expectedLength := 4
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return romanMatches, errInvalidSubmatches
+		}
+
+		var match romanVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return romanMatches, err
+		}
+
+		match.regex = regex
+		match.start = 1
+		match.lower = submatch[2] != ""
+		match.transformToken = submatch[3]
+
+		if submatch[1] != "" {
+			match.start, err = strconv.Atoi(submatch[1])
+			if err != nil {
+				return romanMatches, err
+			}
+		}
+
+		romanMatches.matches = append(romanMatches.matches, match)
+	}
+
+	return romanMatches, nil
+}
+
+// getImageVars retrieves all the image dimension variables in the
+// replacement string if any.
+func getImageVars(replacementInput string) (imageVars, error) {
+	var imageMatches imageVars
+
+	if !imageVarRegex.MatchString(replacementInput) {
+		return imageMatches, nil
+	}
+
+	submatches := imageVarRegex.FindAllStringSubmatch(
+		replacementInput,
+		-1,
+	)
+	expectedLength := 3
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return imageMatches, errInvalidSubmatches
+		}
+
+		var match imageVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return imageMatches, err
+		}
+
+		match.regex = regex
+		match.val = submatch
+		match.attr = submatch[1]
+		match.transformToken = submatch[2]
+
+		imageMatches.matches = append(imageMatches.matches, match)
+	}
+
+	return imageMatches, nil
+}
+
+// getVideoVars retrieves all the video metadata variables in the
+// replacement string if any.
+func getVideoVars(replacementInput string) (videoVars, error) {
+	var videoMatches videoVars
+
+	if !videoVarRegex.MatchString(replacementInput) {
+		return videoMatches, nil
+	}
+
+	submatches := videoVarRegex.FindAllStringSubmatch(
+		replacementInput,
+		-1,
+	)
+	expectedLength := 3
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return videoMatches, errInvalidSubmatches
+		}
+
+		var match videoVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return videoMatches, err
+		}
+
+		match.regex = regex
+		match.val = submatch
+		match.attr = submatch[1]
+		match.transformToken = submatch[2]
+
+		videoMatches.matches = append(videoMatches.matches, match)
+	}
+
+	return videoMatches, nil
+}
+
+// getStatVars retrieves all the text statistics variables in the replacement
+// string if any.
+func getStatVars(replacementInput string) (statVars, error) {
+	var statMatches statVars
+
+	if !statVarRegex.MatchString(replacementInput) {
+		return statMatches, nil
+	}
+
+	submatches := statVarRegex.FindAllStringSubmatch(
+		replacementInput,
+		-1,
+	)
+	expectedLength := 3
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return statMatches, errInvalidSubmatches
+		}
+
+		var match statVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return statMatches, err
+		}
+
+		match.regex = regex
+		match.val = submatch
+		match.stat = submatch[1]
+		match.transformToken = submatch[2]
+
+		statMatches.matches = append(statMatches.matches, match)
+	}
+
+	return statMatches, nil
+}
+
 // getTransformVars retrieves all the string transformation variables
 // in the replacement string if any.
 func getTransformVars(replacementInput string) (transformVars, error) {
@@ -316,7 +623,7 @@ func getTransformVars(replacementInput string) (transformVars, error) {
 
 		var match transformVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return transformVarMatches, err
 		}
@@ -356,7 +663,7 @@ func getExifVars(replacementInput string) (exifVars, error) {
 
 		var match exifVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return exifMatches, err
 		}
@@ -401,7 +708,7 @@ func getIndexingVars(replacementInput string) (indexVars, error) {
 
 		var match indexVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return indexMatches, err
 		}
@@ -414,6 +721,8 @@ func getIndexingVars(replacementInput string) (indexVars, error) {
 		}
 
 		if submatch[2] != "" {
+			match.startIsSet = true
+
 			match.startNumber, err = strconv.Atoi(submatch[2])
 			if err != nil {
 				return indexMatches, err
@@ -499,7 +808,7 @@ func getExifToolVars(replacementInput string) (exiftoolVars, error) {
 
 		var match exiftoolVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return exiftoolMatches, err
 		}
@@ -537,7 +846,7 @@ func getID3Vars(replacementInput string) (id3Vars, error) {
 
 		var match id3VarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return id3Matches, err
 		}
@@ -566,7 +875,7 @@ func getRandomVars(replacementInput string) (randomVars, error) {
 		replacementInput,
 		-1,
 	)
-	expectedLength := 5
+	expectedLength := 7
 
 	for _, submatch := range submatches {
 		if len(submatch) < expectedLength {
@@ -578,7 +887,7 @@ func getRandomVars(replacementInput string) (randomVars, error) {
 		match.length = 10
 		match.val = submatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return rvMatches, err
 		}
@@ -599,7 +908,20 @@ func getRandomVars(replacementInput string) (randomVars, error) {
 			match.characters = submatch[3]
 		}
 
-		match.transformToken = submatch[4]
+		// {{random.N}} is alphanumeric and {{random.N.d}} is numeric
+		if submatch[4] != "" {
+			match.length, err = strconv.Atoi(submatch[4])
+			if err != nil {
+				return rvMatches, err
+			}
+
+			match.characters = `_ld`
+			if submatch[5] != "" {
+				match.characters = `_d`
+			}
+		}
+
+		match.transformToken = submatch[6]
 
 		rvMatches.matches = append(rvMatches.matches, match)
 	}
@@ -625,7 +947,7 @@ func getExtVars(replacementInput string) (extVars, error) {
 
 		var match extVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return evMatches, err
 		}
@@ -658,7 +980,7 @@ func getParentDirVars(replacementInput string) (parentDirVars, error) {
 
 		var match parentDirVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return pvMatches, err
 		}
@@ -699,7 +1021,7 @@ func getFilenameVars(replacementInput string) (filenameVars, error) {
 
 		var match filenameVarMatch
 
-		regex, err := regexp.Compile(submatch[0])
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
 		if err != nil {
 			return fvMatches, err
 		}
@@ -714,6 +1036,39 @@ func getFilenameVars(replacementInput string) (filenameVars, error) {
 	return fvMatches, nil
 }
 
+func getOrigVars(replacementInput string) (origVars, error) {
+	var ovMatches origVars
+
+	if !origVarRegex.MatchString(replacementInput) {
+		return ovMatches, nil
+	}
+
+	submatches := origVarRegex.FindAllStringSubmatch(replacementInput, -1)
+
+	expectedLength := 2
+
+	for _, submatch := range submatches {
+		if len(submatch) < expectedLength {
+			return ovMatches, errInvalidSubmatches
+		}
+
+		var match origVarMatch
+
+		regex, err := regexp.Compile(regexp.QuoteMeta(submatch[0]))
+		if err != nil {
+			return ovMatches, err
+		}
+
+		match.regex = regex
+
+		match.transformToken = submatch[1]
+
+		ovMatches.matches = append(ovMatches.matches, match)
+	}
+
+	return ovMatches, nil
+}
+
 // extractVariables retrieves all the variables present in the replacement
 // string.
 func extractVariables(replacement string) (variables, error) {
@@ -726,6 +1081,11 @@ func extractVariables(replacement string) (variables, error) {
 		return vars, err
 	}
 
+	vars.orig, err = getOrigVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
 	vars.ext, err = getExtVars(replacement)
 	if err != nil {
 		return vars, err
@@ -756,6 +1116,31 @@ func extractVariables(replacement string) (variables, error) {
 		return vars, err
 	}
 
+	vars.alpha, err = getAlphaVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
+	vars.roman, err = getRomanVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
+	vars.stat, err = getStatVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
+	vars.image, err = getImageVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
+	vars.video, err = getVideoVars(replacement)
+	if err != nil {
+		return vars, err
+	}
+
 	vars.date, err = getDateVars(replacement)
 	if err != nil {
 		return vars, err
@@ -781,6 +1166,16 @@ func extractVariables(replacement string) (variables, error) {
 		return vars, err
 	}
 
+	for _, submatch := range widthSpecRegex.FindAllStringSubmatch(
+		replacement,
+		-1,
+	) {
+		_, err = parseWidthSpec(submatch[1])
+		if err != nil {
+			return vars, err
+		}
+	}
+
 	return vars, nil
 }
 
@@ -788,52 +1183,71 @@ func extractVariables(replacement string) (variables, error) {
 // It respects the specified replacement limit. A negative limit indicates that
 // replacement should start from the end of the fileName.
 func regexReplace(
-	regex *regexp.Regexp,
+	regex pattern.Regexp,
 	input, replacement string,
 	replaceLimit int,
 ) string {
-	var output string
-
-	switch limit := replaceLimit; {
-	case limit > 0:
-		counter := 0
-		output = regex.ReplaceAllStringFunc(
-			input,
-			func(val string) string {
-				if counter == replaceLimit {
-					return val
-				}
+	if replaceLimit < 0 {
+		return regexReplaceRange(regex, input, replacement, replaceLimit, 0)
+	}
 
-				counter++
-				return regex.ReplaceAllString(val, replacement)
-			},
-		)
-	case limit < 0:
-		matches := regex.FindAllString(input, -1)
-
-		l := len(matches) + limit
-		counter := 0
-		output = regex.ReplaceAllStringFunc(
-			input,
-			func(val string) string {
-				if counter >= l {
-					return regex.ReplaceAllString(val, replacement)
-				}
+	return regexReplaceRange(regex, input, replacement, 1, replaceLimit)
+}
 
-				counter++
-				return val
-			},
-		)
-	default:
-		output = regex.ReplaceAllString(input, replacement)
+// regexReplaceRange replaces count matches in the input with the replacement
+// starting from the one at the 1-based position start (counting from the end
+// if it is negative). All the matches from start onwards are replaced if
+// count is zero. The other matches are left as is. Each match in the range is
+// replaced on its own so capture variables in the replacement (such as $1)
+// refer to the groups of that match.
+func regexReplaceRange(
+	regex pattern.Regexp,
+	input, replacement string,
+	start, count int,
+) string {
+	if re, ok := regex.(pattern.LimitReplacer); ok {
+		return re.ReplaceRange(input, replacement, start, count)
 	}
 
-	return output
+	if start == 1 && count == 0 {
+		return regex.ReplaceAllString(input, replacement)
+	}
+
+	first := start - 1
+	if start < 0 {
+		first = len(regex.FindAllString(input, -1)) + start
+	}
+
+	counter := 0
+
+	return regex.ReplaceAllStringFunc(
+		input,
+		func(val string) string {
+			i := counter
+			counter++
+
+			if i < first || (count > 0 && i >= first+count) {
+				return val
+			}
+
+			return regex.ReplaceAllString(val, replacement)
+		},
+	)
 }
 
 // replaceString replaces all matches in the filename
 // with the replacement string.
 func replaceString(conf *config.Config, originalName string) string {
+	if conf.ReplaceStart != 0 {
+		return regexReplaceRange(
+			conf.SearchRegex,
+			originalName,
+			conf.Replacement,
+			conf.ReplaceStart,
+			conf.ReplaceLimit,
+		)
+	}
+
 	return regexReplace(
 		conf.SearchRegex,
 		originalName,
@@ -842,38 +1256,126 @@ func replaceString(conf *config.Config, originalName string) string {
 	)
 }
 
+// trimTarget shortens the base name of the target to n runes at a word
+// boundary. The extension of files is preserved.
+func trimTarget(target string, n int, isDir bool) string {
+	dir, name := filepath.Split(target)
+
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(name)
+	}
+
+	name = trimToWordBoundary(strings.TrimSuffix(name, ext), n)
+
+	return dir + name + ext
+}
+
+// protectedAffixes returns the protected prefix and suffix that are present
+// in the name. They are excluded from the find and replace operation.
+func protectedAffixes(name, protectPrefix, protectSuffix string) (
+	prefix, suffix string,
+) {
+	if protectPrefix != "" && strings.HasPrefix(name, protectPrefix) {
+		prefix = protectPrefix
+	}
+
+	if protectSuffix != "" &&
+		strings.HasSuffix(name[len(prefix):], protectSuffix) {
+		suffix = protectSuffix
+	}
+
+	return prefix, suffix
+}
+
 // replaceMatches handles the replacement of matches in each file with the
 // replacement string.
 func replaceMatches(
 	conf *config.Config,
 	matches []*file.Change,
 ) ([]*file.Change, error) {
-	vars, err := extractVariables(conf.Replacement)
+	// The variables that specify a default value are extracted
+	// along with the other variables
+	replacement, _ := markDefaultVars(conf.Replacement)
+
+	vars, err := extractVariables(replacement)
 	if err != nil {
 		return nil, err
 	}
 
+	prefetchMetadata(
+		matches,
+		&vars,
+		conf.Workers,
+		conf.StatMaxBytes,
+		conf.RateLimiter,
+	)
+
+	// The number of matches seen so far in each path argument
+	rootCounters := make(map[string]int)
+
+	// The number of matches in each path argument
+	rootTotals := make(map[string]int)
+
+	if conf.IndexPerRoot {
+		for i := range matches {
+			rootTotals[matches[i].Root]++
+		}
+	}
+
 	for i := range matches {
 		change := matches[i]
 		change.Index = i
 		originalName := change.Source
+
+		index, total := i, len(matches)
+		if conf.IndexPerRoot {
+			index, total = rootCounters[change.Root], rootTotals[change.Root]
+			rootCounters[change.Root]++
+		}
+
 		fileExt := filepath.Ext(originalName)
 
-		if conf.IgnoreExt && !change.IsDir {
+		if conf.StemOnly() && !change.IsDir {
 			originalName = internalpath.FilenameWithoutExtension(originalName)
 		}
 
+		prefix, suffix := protectedAffixes(
+			originalName,
+			conf.ProtectPrefix,
+			conf.ProtectSuffix,
+		)
+
+		originalName = strings.TrimSuffix(
+			strings.TrimPrefix(originalName, prefix),
+			suffix,
+		)
+
 		change.Target = replaceString(conf, originalName)
 
 		// Replace any variables present with their corresponding values
-		err = replaceVariables(conf, change, &vars)
+		err = replaceVariables(conf, change, index, total, &vars)
 		if err != nil {
 			return nil, err
 		}
 
-		// Reattach the original extension to the new file name
-		if conf.IgnoreExt && !change.IsDir {
-			change.Target += fileExt
+		// Reattach the protected portions of the name
+		change.Target = prefix + change.Target + suffix
+
+		if !change.IsDir && (conf.StemOnly() || conf.IgnoreExt) {
+			stem := change.Target
+			if !conf.StemOnly() {
+				// Keep the original extension even though it was searched
+				stem = internalpath.FilenameWithoutExtension(stem)
+			}
+
+			// Reattach the original extension to the new file name unless
+			// the name is now empty so that it is reported as such instead
+			// of leaving a file that consists of the extension alone
+			change.Target = ""
+			if strings.TrimSpace(stem) != "" {
+				change.Target = stem + fileExt
+			}
 		}
 
 		change.Target = strings.TrimSpace(filepath.Clean(change.Target))
@@ -884,14 +1386,114 @@ func replaceMatches(
 	return matches, nil
 }
 
+// handleReplacementChain applies each find and replace pair to the matches
+// in turn. If a separate chain is provided for directories, files and
+// directories are renamed with their respective chains.
 func handleReplacementChain(
 	conf *config.Config,
 	matches []*file.Change,
+) ([]*file.Change, error) {
+	if !conf.HasDirReplacement() {
+		return replaceChain(conf, matches)
+	}
+
+	dirConf, err := conf.DirConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var files, dirs []*file.Change
+
+	for _, change := range matches {
+		if change.IsDir {
+			dirs = append(dirs, change)
+			continue
+		}
+
+		files = append(files, change)
+	}
+
+	if len(files) > 0 {
+		_, err = replaceChain(conf, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(dirs) > 0 {
+		_, err = replaceChain(dirConf, dirs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The indexes were assigned separately within each group
+	for i := range matches {
+		matches[i].Index = i
+	}
+
+	return matches, nil
+}
+
+// handleExtRules renames each match with the find and replace pair of the
+// extension rule (from the config file) that applies to it.
+func handleExtRules(
+	conf *config.Config,
+	matches []*file.Change,
+) ([]*file.Change, error) {
+	groups := make(map[*config.ExtRule][]*file.Change)
+
+	var rules []*config.ExtRule
+
+	for _, change := range matches {
+		rule := conf.ExtRule(change.Source)
+
+		if _, ok := groups[rule]; !ok {
+			rules = append(rules, rule)
+		}
+
+		groups[rule] = append(groups[rule], change)
+	}
+
+	for _, rule := range rules {
+		if rule == nil {
+			for _, change := range groups[rule] {
+				change.Target = change.Source
+				change.Status = status.OK
+			}
+
+			continue
+		}
+
+		ruleConf, err := conf.ExtRuleConfig(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = replaceChain(ruleConf, groups[rule])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The indexes were assigned separately within each group
+	for i := range matches {
+		matches[i].Index = i
+	}
+
+	return matches, nil
+}
+
+// replaceChain applies each find and replace pair in the config to the
+// matches in turn.
+func replaceChain(
+	conf *config.Config,
+	matches []*file.Change,
 ) ([]*file.Change, error) {
 	replacementSlice := conf.ReplacementSlice
 
 	for i, v := range replacementSlice {
-		config.SetReplacement(v)
+		conf.Replacement = expandCaptureCase(v)
 
 		var err error
 
@@ -924,9 +1526,146 @@ func handleReplacementChain(
 		}
 	}
 
+	if len(replacementSlice) > 1 && isNoOp(matches) {
+		report.Warning(errNoOpChain.Error())
+	}
+
 	return matches, nil
 }
 
+// isNoOp reports whether the target of every change is its original source.
+func isNoOp(matches []*file.Change) bool {
+	for _, change := range matches {
+		if change.Target != change.OriginalSource {
+			return false
+		}
+	}
+
+	return len(matches) > 0
+}
+
+// keepUngated keeps the original name of the files whose original name does
+// not match the gate so that they are reported as unchanged. The other
+// changes are returned so that only they are replaced.
+func keepUngated(
+	changes []*file.Change,
+	gate *regexp.Regexp,
+) []*file.Change {
+	var gated []*file.Change
+
+	for i := range changes {
+		change := changes[i]
+
+		if gate.MatchString(change.OriginalSource) {
+			gated = append(gated, change)
+			continue
+		}
+
+		change.Target = change.OriginalSource
+		change.Status = status.Unchanged
+	}
+
+	return gated
+}
+
+// keepInPlace marks the changes whose target resolves to the current location
+// of the source as unchanged (such as the files that are already in the
+// directory that the other matches are moved into) so that they are not
+// treated as moves.
+func keepInPlace(changes []*file.Change) {
+	for i := range changes {
+		change := changes[i]
+
+		if change.Target == change.Source {
+			continue
+		}
+
+		if filepath.Join(change.BaseDir, change.Target) !=
+			filepath.Join(change.BaseDir, change.Source) {
+			continue
+		}
+
+		change.Target = change.Source
+		change.Status = status.Unchanged
+	}
+}
+
+// readTargets assigns the names read from the reader (one per line) as the
+// target of each change in order. The number of names must match the number
+// of changes exactly.
+func readTargets(
+	reader io.Reader,
+	changes []*file.Change,
+) ([]*file.Change, error) {
+	var names []string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		names = append(names, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(names) != len(changes) {
+		return nil, fmt.Errorf(
+			errStdinNamesMismatch.Error(),
+			len(changes),
+			len(names),
+		)
+	}
+
+	for i := range changes {
+		change := changes[i]
+		change.Index = i
+		change.Target = strings.TrimSpace(filepath.Clean(names[i]))
+		change.Status = status.OK
+	}
+
+	return changes, nil
+}
+
+// checkTargetDirs ensures that no target includes a directory so that
+// each path stays in its original directory after the renaming operation.
+func checkTargetDirs(changes []*file.Change) error {
+	for _, change := range changes {
+		if filepath.Dir(change.Target) != "." {
+			return fmt.Errorf(
+				errTargetMoved.Error(),
+				filepath.Join(change.BaseDir, change.Source),
+				change.Target,
+			)
+		}
+	}
+
+	return nil
+}
+
+// rootOf returns the path argument that the provided path was found in.
+// The longest path argument is preferred if several of them contain the path.
+func rootOf(path string, pathArgs []string) string {
+	root := "."
+
+	var longest int
+
+	for _, arg := range pathArgs {
+		arg = filepath.Clean(arg)
+
+		if path != arg &&
+			!strings.HasPrefix(path, arg+string(filepath.Separator)) {
+			continue
+		}
+
+		if len(arg) > longest {
+			root = arg
+			longest = len(arg)
+		}
+	}
+
+	return root
+}
+
 // c creates a file.Change struct for each match.
 func c(conf *config.Config, matches internalpath.Collection) []*file.Change {
 	var changes []*file.Change
@@ -941,6 +1680,11 @@ func c(conf *config.Config, matches internalpath.Collection) []*file.Change {
 				IsDir:          entry.IsDir(),
 				Source:         filename,
 				OriginalSource: filename,
+				Root: rootOf(
+					filepath.Join(path, filename),
+					conf.PathsToFilesOrDirs,
+				),
+				Depth: internalpath.Depth(path, conf.PathsToFilesOrDirs),
 			}
 
 			if conf.CSVFilename != "" {
@@ -948,6 +1692,13 @@ func c(conf *config.Config, matches internalpath.Collection) []*file.Change {
 				change.CSVRow = rows[absPath]
 			}
 
+			if conf.Exec {
+				if info, err := entry.Info(); err == nil {
+					modTime := info.ModTime()
+					change.ModTime = &modTime
+				}
+			}
+
 			changes = append(changes, change)
 		}
 	}
@@ -955,6 +1706,12 @@ func c(conf *config.Config, matches internalpath.Collection) []*file.Change {
 	return changes
 }
 
+// HighestIndex returns the highest number that was substituted for an
+// indexing variable in the last call to Replace (zero if there were none).
+func HighestIndex() int {
+	return highestIndex
+}
+
 func Replace(
 	conf *config.Config,
 	matches internalpath.Collection,
@@ -963,6 +1720,14 @@ func Replace(
 
 	var changes []*file.Change
 
+	// Files may have changed since a previous renaming operation. The hashes
+	// are reset by find.Find so that those computed by its filters are reused
+	metadata = newMetadataCache()
+
+	highestIndex = 0
+
+	issuedRandom = make(map[string]string)
+
 	changes = c(conf, matches)
 
 	changes, err = sort.Changes(changes, conf.Sort, conf.ReverseSort)
@@ -970,10 +1735,101 @@ func Replace(
 		return nil, err
 	}
 
-	changes, err = handleReplacementChain(conf, changes)
+	if conf.OrderFile != "" {
+		changes = sort.ByOrder(changes, conf.Order)
+	}
+
+	if conf.DirsFirst || conf.DirsLast {
+		changes = sort.GroupDirs(changes, conf.DirsFirst)
+	}
+
+	// The replacement steps assign the indexes again to each
+	// group of changes that they number separately
+	for i := range changes {
+		changes[i].Index = i
+	}
+
+	// The gate is checked for each match before the replacement so that
+	// the files that are left out do not use up any index numbers and none
+	// of the transformations below apply to them
+	replaced := changes
+	if conf.ReplaceIfRegex != nil {
+		replaced = keepUngated(changes, conf.ReplaceIfRegex)
+	}
+
+	switch {
+	case conf.StdinNames:
+		replaced, err = readTargets(conf.Stdin, replaced)
+	case conf.MapFile != "":
+		for i := range replaced {
+			replaced[i].Target = conf.Mappings[replaced[i].Source]
+			replaced[i].Status = status.OK
+		}
+	case conf.HasExtRules():
+		replaced, err = handleExtRules(conf, replaced)
+	case len(conf.ReplacementSlice) == 0 && !conf.HasDirReplacement():
+		// Without a replacement, the targets start off as the original names
+		// so that they can be modified in the editor
+		for i := range replaced {
+			replaced[i].Target = replaced[i].Source
+			replaced[i].Status = status.OK
+		}
+	default:
+		replaced, err = handleReplacementChain(conf, replaced)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	if conf.Unaccent {
+		for i := range replaced {
+			replaced[i].Target = removeDiacritics(replaced[i].Target)
+		}
+	}
+
+	if conf.TrimTo > 0 {
+		for i := range replaced {
+			replaced[i].Target = trimTarget(
+				replaced[i].Target,
+				conf.TrimTo,
+				replaced[i].IsDir,
+			)
+		}
+	}
+
+	// The indexes were assigned to the gated changes alone
+	if conf.ReplaceIfRegex != nil {
+		for i := range changes {
+			changes[i].Index = i
+		}
+	}
+
+	if conf.Edit {
+		changes, err = editTargets(changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keepInPlace(changes)
+
+	// The validator sees the final targets, but conflicts are detected
+	// afterwards so that the rejected changes are accounted for
+	if len(conf.ValidateWith) > 0 {
+		validateTargets(changes, conf.ValidateWith, conf.Workers)
+	}
+
+	if conf.WithXattrs {
+		changes = addAppleDoubles(changes)
+	}
+
+	if conf.PreserveSubdirs && !conf.AllowMove {
+		err = checkTargetDirs(changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return changes, nil
 }
@@ -0,0 +1,101 @@
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/report"
+)
+
+var errValidatorRejected = errors.New(
+	"'%s' was left unchanged since the validator rejected '%s': %w",
+)
+
+// validation is the outcome of running the validator for a change.
+type validation struct {
+	err    error
+	output []byte // the combined standard output and error
+}
+
+// validateTargets runs the validator command for each change whose target
+// differs from its source with the {{source}} and {{target}} placeholders
+// replaced with the paths of the change. Up to the specified number of
+// commands are run concurrently. The changes that the validator rejects
+// (with a non-zero exit status or by failing to run) are left unchanged
+// with a warning. The output of each command is written to report.Stderr
+// once they have all completed so that it is not interleaved.
+//
+// The validator is an arbitrary command supplied by the user that is run
+// without a shell once per change, even in dry-run mode, so it must not
+// have side effects that the user does not expect from a preview.
+func validateTargets(
+	changes []*file.Change,
+	validator []string,
+	workers int,
+) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]validation, len(changes))
+
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range indices {
+				args := changes[j].CommandArgs(validator)
+
+				//nolint:gosec // the validator is supplied by the user
+				cmd := exec.Command(args[0], args[1:]...)
+
+				results[j].output, results[j].err = cmd.CombinedOutput()
+			}
+		}()
+	}
+
+	for i, change := range changes {
+		if change.Source == change.Target {
+			continue
+		}
+
+		indices <- i
+	}
+
+	close(indices)
+
+	wg.Wait()
+
+	for i, result := range results {
+		_, _ = report.Stderr.Write(result.output)
+
+		if result.err == nil {
+			continue
+		}
+
+		change := changes[i]
+
+		report.Warning(
+			fmt.Errorf(
+				errValidatorRejected.Error(),
+				filepath.Join(change.BaseDir, change.Source),
+				filepath.Join(change.BaseDir, change.Target),
+				result.err,
+			).Error(),
+		)
+
+		change.Target = change.Source
+		change.Status = status.Unchanged
+	}
+}
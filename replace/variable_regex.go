@@ -2,29 +2,48 @@ package replace
 
 import (
 	"fmt"
-	"math/rand"
 	"regexp"
 	"strings"
-	"time"
 
 	internaltime "github.com/ayoisaiah/f2/internal/time"
 )
 
 var transformTokens string
 
+// widthSpecRegex matches the fixed width specification that may follow a
+// transform token so that it can be validated.
+var widthSpecRegex = regexp.MustCompile(`[{.](width=[^{}]*)}`)
+
 var (
 	filenameVarRegex  *regexp.Regexp
+	origVarRegex      *regexp.Regexp
 	extensionVarRegex *regexp.Regexp
 	parentDirVarRegex *regexp.Regexp
 	indexVarRegex     *regexp.Regexp
+	totalVarRegex     *regexp.Regexp
+	alphaVarRegex     *regexp.Regexp
+	romanVarRegex     *regexp.Regexp
 	randomVarRegex    *regexp.Regexp
 	hashVarRegex      *regexp.Regexp
+	statVarRegex      *regexp.Regexp
+	imageVarRegex     *regexp.Regexp
+	videoVarRegex     *regexp.Regexp
 	transformVarRegex *regexp.Regexp
+	captureCaseRegex  *regexp.Regexp
 	csvVarRegex       *regexp.Regexp
 	exiftoolVarRegex  *regexp.Regexp
 	id3VarRegex       *regexp.Regexp
 	exifVarRegex      *regexp.Regexp
 	dateVarRegex      *regexp.Regexp
+	defaultVarRegex   *regexp.Regexp
+)
+
+// defaultVarMarker encloses the variables that specify a default value while
+// the variables are replaced. It cannot occur in a file name.
+const defaultVarMarker = "\x00"
+
+var markedVarRegex = regexp.MustCompile(
+	defaultVarMarker + "([^" + defaultVarMarker + "]*)" + defaultVarMarker,
 )
 
 var dateTokens = map[string]string{
@@ -57,14 +76,19 @@ func init() {
 
 	tokenString := strings.Join(tokens, "|")
 
+	// A fixed width specification may be used on its own or after
+	// another token in which case it is applied last
 	transformTokens = fmt.Sprintf(
-		"(up|lw|ti|win|mac|di|(?:dt\\.(%s)))",
+		"((?:up|lw|low|title|ti|win|mac|di|unaccent|reverse|trim:\\d+|slice:-?\\d+(?::-?\\d+)?|(?:dt\\.(%s)))(?:\\.width=[^{}]*)?|width=[^{}]*)",
 		tokenString,
 	)
 
 	filenameVarRegex = regexp.MustCompile(
 		fmt.Sprintf("{+f(?:\\.%s)?}+", transformTokens),
 	)
+	origVarRegex = regexp.MustCompile(
+		fmt.Sprintf("{+orig(?:\\.%s)?}+", transformTokens),
+	)
 	extensionVarRegex = regexp.MustCompile(
 		fmt.Sprintf("{+ext(?:\\.%s)?}+", transformTokens),
 	)
@@ -74,21 +98,54 @@ func init() {
 	indexVarRegex = regexp.MustCompile(
 		`{+(\$\d+)?(\d+)?(%(\d?)+d)([borh])?(-?\d+)?(?:<(\d+(?:-\d+)?(?:;\s*\d+(?:-\d+)?)*)>)?}+`,
 	)
+	totalVarRegex = regexp.MustCompile(`{+total}+`)
+	alphaVarRegex = regexp.MustCompile(
+		fmt.Sprintf("{+alpha(?:<([a-zA-Z]+)>)?(?:\\.%s)?}+", transformTokens),
+	)
+	romanVarRegex = regexp.MustCompile(
+		fmt.Sprintf(
+			"{+roman(?:<(\\d+)>)?(?:\\.(?:(lower)|%s))?}+",
+			transformTokens,
+		),
+	)
 	randomVarRegex = regexp.MustCompile(
 		fmt.Sprintf(
-			"{+(\\d+)?r(?:(_l|_d|_ld)|(?:<([^>])>))?(?:\\.%s)?}+",
+			"{+(?:(\\d+)?r(?:(_l|_d|_ld)|(?:<([^>])>))?|random\\.([1-9]\\d*)(\\.d)?)(?:\\.%s)?}+",
 			transformTokens,
 		),
 	)
 	hashVarRegex = regexp.MustCompile(
 		fmt.Sprintf(
-			"{+hash.(sha1|sha256|sha512|md5)(?:\\.%s)?}+",
+			"{+hash.(sha1|sha256|sha512|md5|crc32)(?:\\.([1-9]\\d*))?(?:\\.%s)?}+",
+			transformTokens,
+		),
+	)
+	statVarRegex = regexp.MustCompile(
+		fmt.Sprintf(
+			"{+stat\\.(lines|words)(?:\\.%s)?}+",
+			transformTokens,
+		),
+	)
+	imageVarRegex = regexp.MustCompile(
+		fmt.Sprintf(
+			"{+img\\.(width|height|orientation)(?:\\.%s)?}+",
+			transformTokens,
+		),
+	)
+	videoVarRegex = regexp.MustCompile(
+		fmt.Sprintf(
+			"{+video\\.(duration|width|height|codec)(?:\\.%s)?}+",
 			transformTokens,
 		),
 	)
 	transformVarRegex = regexp.MustCompile(
 		fmt.Sprintf("{+(?:<(?:(\\$\\d+)|([^\\.]+))>)?\\.%s}+", transformTokens),
 	)
+	// captureCaseRegex matches the <$1.up> shorthand for changing the
+	// case of a capture group which expands to {{<$1>.up}}
+	captureCaseRegex = regexp.MustCompile(
+		`<(\$\d+)\.(up|lw|low|title|ti)>`,
+	)
 	csvVarRegex = regexp.MustCompile(
 		fmt.Sprintf("{+csv.(\\d+)(?:\\.%s)?}+", transformTokens),
 	)
@@ -119,6 +176,5 @@ func init() {
 		),
 	)
 
-	// for the sake of replacing random string variables
-	rand.Seed(time.Now().UnixNano())
+	defaultVarRegex = regexp.MustCompile(`({+)([^{}|]*)\|([^{}]*)(}+)`)
 }
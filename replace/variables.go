@@ -1,25 +1,26 @@
 package replace
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/hex"
+	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"hash"
-	"io"
-	"math/rand"
+	"image"
+	_ "image/gif"  // register the GIF format for image.DecodeConfig
+	_ "image/jpeg" // register the JPEG format for image.DecodeConfig
+	_ "image/png"  // register the PNG format for image.DecodeConfig
+	"math/big"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	exiftool "github.com/barasher/go-exiftool"
 	"github.com/dhowden/tag"
+	"github.com/rivo/uniseg"
 	"github.com/rwcarlsen/goexif/exif"
 	"golang.org/x/exp/slices"
 	"golang.org/x/text/cases"
@@ -34,20 +35,12 @@ import (
 
 	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/file"
+	"github.com/ayoisaiah/f2/internal/hash"
 	internalos "github.com/ayoisaiah/f2/internal/os"
 
 	"github.com/araddon/dateparse"
 )
 
-type hashAlgorithm string
-
-const (
-	sha1Hash   hashAlgorithm = "sha1"
-	sha256Hash hashAlgorithm = "sha256"
-	sha512Hash hashAlgorithm = "sha512"
-	md5Hash    hashAlgorithm = "md5"
-)
-
 const (
 	letterBytes = "abcdefghijklmnopqrstuvwxyz"
 	numberBytes = "0123456789"
@@ -90,6 +83,39 @@ type ID3 struct {
 	TotalDiscs  int
 }
 
+// ImageSize represents the dimensions of an image in pixels.
+type ImageSize struct {
+	Width  int
+	Height int
+}
+
+// Orientation returns the orientation of the image based on its dimensions.
+func (s *ImageSize) Orientation() string {
+	switch {
+	case s.Width > s.Height:
+		return "landscape"
+	case s.Width < s.Height:
+		return "portrait"
+	default:
+		return "square"
+	}
+}
+
+// VideoInfo represents the metadata of a video that is read from the
+// headers of its container.
+type VideoInfo struct {
+	Codec    string
+	Duration time.Duration
+	Width    int
+	Height   int
+}
+
+// TextStats represents the line and word counts of a text file.
+type TextStats struct {
+	Lines int
+	Words int
+}
+
 func greatestCommonDivisor(a, b int) int {
 	precision := 0.0001
 	if float64(b) < precision {
@@ -99,22 +125,39 @@ func greatestCommonDivisor(a, b int) int {
 	return greatestCommonDivisor(b, a%b)
 }
 
+// maxRandomAttempts is the number of times a random value is generated
+// for a variable before giving up on finding one that was not issued to
+// another file.
+const maxRandomAttempts = 1000
+
 // getRandString returns a random string of the specified length
 // using the specified characterSet.
-func getRandString(n int, characterSet string) string {
+func getRandString(n int, characterSet string) (string, error) {
 	b := make([]byte, n)
 
+	setSize := big.NewInt(int64(len(characterSet)))
+
 	for i := range b {
-		b[i] = characterSet[rand.Intn(len(characterSet))] //nolint:gosec // appropriate use of math.rand
+		j, err := rand.Int(rand.Reader, setSize)
+		if err != nil {
+			return "", err
+		}
+
+		b[i] = characterSet[j.Int64()]
 	}
 
-	return string(b)
+	return string(b), nil
 }
 
 // replaceRandomVars replaces all random string variables
 // in the target filename with a generated random string that matches
-// the specifications.
-func replaceRandomVars(target string, matches []string, rv randomVars) string {
+// the specifications. The same value is never issued to two files in a
+// renaming operation so that the targets do not conflict because of it.
+func replaceRandomVars(
+	target, sourcePath string,
+	matches []string,
+	rv randomVars,
+) (string, error) {
 	for range matches {
 		for i := range rv.matches {
 			current := rv.matches[i]
@@ -131,7 +174,30 @@ func replaceRandomVars(target string, matches []string, rv randomVars) string {
 				characters = letterBytes + numberBytes
 			}
 
-			randString := getRandString(current.length, characters)
+			var randString string
+
+			for attempt := 0; ; attempt++ {
+				if attempt == maxRandomAttempts {
+					return "", fmt.Errorf(
+						errRandomExhausted.Error(),
+						current.val[0],
+						sourcePath,
+					)
+				}
+
+				var err error
+
+				randString, err = getRandString(current.length, characters)
+				if err != nil {
+					return "", err
+				}
+
+				if src, ok := issuedRandom[randString]; !ok || src == sourcePath {
+					break
+				}
+			}
+
+			issuedRandom[randString] = sourcePath
 
 			randString = transformString(randString, current.transformToken)
 
@@ -139,7 +205,7 @@ func replaceRandomVars(target string, matches []string, rv randomVars) string {
 		}
 	}
 
-	return target
+	return target, nil
 }
 
 // integerToRoman converts an integer to a roman numeral
@@ -181,35 +247,72 @@ func integerToRoman(integer int) string {
 	return roman.String()
 }
 
-// getHash retrieves the appropriate hash value for the specified file.
-func getHash(filePath string, hashValue hashAlgorithm) (string, error) {
-	openedFile, err := os.Open(filePath)
-	if err != nil {
-		return "", err
+// integerToAlpha converts a zero-based integer to a sequence of letters
+// such that 0 is `a`, 25 is `z`, 26 is `aa`, 27 is `ab`, and so on.
+func integerToAlpha(integer int) string {
+	letters := len(letterBytes)
+
+	var alpha []byte
+
+	for integer >= 0 {
+		alpha = append([]byte{letterBytes[integer%letters]}, alpha...)
+		integer = integer/letters - 1
 	}
 
-	defer openedFile.Close()
+	return string(alpha)
+}
 
-	var newHash hash.Hash
+// alphaToInteger is the inverse of integerToAlpha. The letters
+// are case insensitive.
+func alphaToInteger(alpha string) int {
+	letters := len(letterBytes)
 
-	switch hashValue {
-	case sha1Hash:
-		newHash = sha1.New()
-	case sha256Hash:
-		newHash = sha256.New()
-	case sha512Hash:
-		newHash = sha512.New()
-	case md5Hash:
-		newHash = md5.New()
-	default:
-		return "", nil
+	var integer int
+
+	for _, r := range strings.ToLower(alpha) {
+		integer = integer*letters + int(r-'a') + 1
 	}
 
-	if _, err := io.Copy(newHash, openedFile); err != nil {
-		return "", err
+	return integer - 1
+}
+
+// replaceAlphaVars replaces all alphabetical indexing variables in the
+// target file name with the letter sequence for the change's position.
+// The sequence is uppercase if the start letters are uppercase.
+func replaceAlphaVars(target string, changeIndex int, av alphaVars) string {
+	for i := range av.matches {
+		current := av.matches[i]
+
+		alpha := integerToAlpha(current.start + changeIndex)
+		if current.upper {
+			alpha = strings.ToUpper(alpha)
+		}
+
+		alpha = transformString(alpha, current.transformToken)
+
+		target = current.regex.ReplaceAllString(target, alpha)
 	}
 
-	return hex.EncodeToString(newHash.Sum(nil)), nil
+	return target
+}
+
+// replaceRomanVars replaces all roman numeral indexing variables in the
+// target file name with the numeral for the change's position.
+func replaceRomanVars(target string, changeIndex int, rv romanVars) string {
+	for i := range rv.matches {
+		current := rv.matches[i]
+
+		roman := integerToRoman(current.start + changeIndex)
+		if current.lower {
+			roman = strings.ToLower(roman)
+		}
+
+		roman = transformString(roman, current.transformToken)
+
+		target = current.regex.ReplaceAllString(target, roman)
+	}
+
+	return target
 }
 
 // replaceFileHashVars replaces a hash variable with the corresponding
@@ -221,11 +324,15 @@ func replaceFileHashVars(
 	for i := range hashMatches.matches {
 		current := hashMatches.matches[i]
 
-		hashValue, err := getHash(sourcePath, current.hashFn)
+		hashValue, err := hash.File(sourcePath, current.hashFn)
 		if err != nil {
 			return "", err
 		}
 
+		if current.length > 0 && current.length < len(hashValue) {
+			hashValue = hashValue[:current.length]
+		}
+
 		hashValue = transformString(hashValue, current.transformToken)
 
 		target = regexReplace(current.regex, target, hashValue, 0)
@@ -234,6 +341,240 @@ func replaceFileHashVars(
 	return target, nil
 }
 
+// getTextStats counts the lines and words in a text file. Files that are
+// larger than maxBytes or do not contain valid UTF-8 text (such as binary
+// files) yield a nil result so that the corresponding variables are replaced
+// with an empty string.
+func getTextStats(sourcePath string, maxBytes int64) (*TextStats, error) {
+	if stats, ok := metadata.textStats(sourcePath); ok {
+		return stats, nil
+	}
+
+	fileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileInfo.IsDir() || fileInfo.Size() > maxBytes {
+		metadata.setTextStats(sourcePath, nil)
+
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.IndexByte(b, 0) != -1 || !utf8.Valid(b) {
+		metadata.setTextStats(sourcePath, nil)
+
+		return nil, nil
+	}
+
+	stats := &TextStats{
+		Lines: bytes.Count(b, []byte("\n")),
+		Words: len(bytes.Fields(b)),
+	}
+
+	// count the last line if it isn't terminated by a newline
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		stats.Lines++
+	}
+
+	metadata.setTextStats(sourcePath, stats)
+
+	return stats, nil
+}
+
+// replaceStatVars replaces all text statistics variables in the target
+// file name with the corresponding line or word count.
+func replaceStatVars(
+	target, sourcePath string,
+	maxBytes int64,
+	sv statVars,
+) (string, error) {
+	stats, err := getTextStats(sourcePath, maxBytes)
+	if err != nil {
+		return target, err
+	}
+
+	for i := range sv.matches {
+		current := sv.matches[i]
+
+		var value string
+
+		if stats != nil {
+			switch current.stat {
+			case "lines":
+				value = strconv.Itoa(stats.Lines)
+			case "words":
+				value = strconv.Itoa(stats.Words)
+			}
+		}
+
+		value = transformString(value, current.transformToken)
+
+		target = regexReplace(current.regex, target, value, 0)
+	}
+
+	return target, nil
+}
+
+// getImageSize reads the dimensions of a PNG, JPEG, GIF, or WebP image from
+// its header without decoding the rest of the file. Other files yield a nil
+// result so that the corresponding variables are replaced with an empty
+// string.
+func getImageSize(sourcePath string) (*ImageSize, error) {
+	if size, ok := metadata.imageSize(sourcePath); ok {
+		return size, nil
+	}
+
+	fileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileInfo.IsDir() {
+		metadata.setImageSize(sourcePath, nil)
+
+		return nil, nil
+	}
+
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var size *ImageSize
+
+	// Errors are ignored since they indicate that the file is not an image
+	// in one of the supported formats
+	imageConf, _, err := image.DecodeConfig(f)
+	if err == nil {
+		size = &ImageSize{
+			Width:  imageConf.Width,
+			Height: imageConf.Height,
+		}
+	}
+
+	metadata.setImageSize(sourcePath, size)
+
+	return size, nil
+}
+
+// replaceImageVars replaces all image dimension variables in the target
+// file name with the corresponding width, height, or orientation.
+func replaceImageVars(
+	target, sourcePath string,
+	iv imageVars,
+) (string, error) {
+	size, err := getImageSize(sourcePath)
+	if err != nil {
+		return target, err
+	}
+
+	for i := range iv.matches {
+		current := iv.matches[i]
+
+		var value string
+
+		if size != nil {
+			switch current.attr {
+			case "width":
+				value = strconv.Itoa(size.Width)
+			case "height":
+				value = strconv.Itoa(size.Height)
+			case "orientation":
+				value = size.Orientation()
+			}
+		}
+
+		value = transformString(value, current.transformToken)
+
+		target = regexReplace(current.regex, target, value, 0)
+	}
+
+	return target, nil
+}
+
+// getVideoInfo reads the metadata of an MP4 or Matroska video. Files that
+// are not videos or cannot be parsed yield a nil result so that the
+// corresponding variables are replaced with an empty string.
+func getVideoInfo(sourcePath string) (*VideoInfo, error) {
+	if info, ok := metadata.videoInfo(sourcePath); ok {
+		return info, nil
+	}
+
+	fileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileInfo.IsDir() {
+		metadata.setVideoInfo(sourcePath, nil)
+
+		return nil, nil
+	}
+
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	// Errors are ignored since they indicate that the container is
+	// malformed or uses features that are not supported
+	info, err := parseVideo(f)
+	if err != nil {
+		info = nil
+	}
+
+	metadata.setVideoInfo(sourcePath, info)
+
+	return info, nil
+}
+
+// replaceVideoVars replaces all video metadata variables in the target
+// file name with the corresponding duration, dimension, or codec.
+func replaceVideoVars(
+	target, sourcePath string,
+	vv videoVars,
+) (string, error) {
+	info, err := getVideoInfo(sourcePath)
+	if err != nil {
+		return target, err
+	}
+
+	for i := range vv.matches {
+		current := vv.matches[i]
+
+		var value string
+
+		if info != nil {
+			switch current.attr {
+			case "duration":
+				value = formatVideoDuration(info.Duration)
+			case "width":
+				value = strconv.Itoa(info.Width)
+			case "height":
+				value = strconv.Itoa(info.Height)
+			case "codec":
+				value = info.Codec
+			}
+		}
+
+		value = transformString(value, current.transformToken)
+
+		target = regexReplace(current.regex, target, value, 0)
+	}
+
+	return target, nil
+}
+
 // replaceDateVars replaces any date variables in the target
 // with the corresponding date value.
 func replaceDateVars(
@@ -290,6 +631,10 @@ func replaceDateVars(
 // errors while reading the id3 tags are ignored since the corresponding
 // variable will be replaced with an empty string.
 func getID3Tags(sourcePath string) (*ID3, error) {
+	if tags, ok := metadata.id3Tags(sourcePath); ok {
+		return tags, nil
+	}
+
 	f, err := os.Open(sourcePath)
 	if err != nil {
 		return nil, err
@@ -297,31 +642,39 @@ func getID3Tags(sourcePath string) (*ID3, error) {
 
 	defer f.Close()
 
-	metadata, err := tag.ReadFrom(f)
+	m, err := tag.ReadFrom(f)
 	if err != nil {
 		// empty ID3 instance which means the variables are replaced with empty strings
+		tags := &ID3{}
+
+		metadata.setID3Tags(sourcePath, tags)
+
 		//nolint:nilerr // intentionally returning nil here
-		return &ID3{}, nil
+		return tags, nil
 	}
 
-	trackNum, totalTracks := metadata.Track()
-	discNum, totalDiscs := metadata.Disc()
+	trackNum, totalTracks := m.Track()
+	discNum, totalDiscs := m.Disc()
 
-	return &ID3{
-		Format:      string(metadata.Format()),
-		FileType:    string(metadata.FileType()),
-		Title:       metadata.Title(),
-		Album:       metadata.Album(),
-		Artist:      metadata.Artist(),
-		AlbumArtist: metadata.AlbumArtist(),
+	tags := &ID3{
+		Format:      string(m.Format()),
+		FileType:    string(m.FileType()),
+		Title:       m.Title(),
+		Album:       m.Album(),
+		Artist:      m.Artist(),
+		AlbumArtist: m.AlbumArtist(),
 		Track:       trackNum,
 		TotalTracks: totalTracks,
 		Disc:        discNum,
 		TotalDiscs:  totalDiscs,
-		Composer:    metadata.Composer(),
-		Year:        metadata.Year(),
-		Genre:       metadata.Genre(),
-	}, nil
+		Composer:    m.Composer(),
+		Year:        m.Year(),
+		Genre:       m.Genre(),
+	}
+
+	metadata.setID3Tags(sourcePath, tags)
+
+	return tags, nil
 }
 
 // replaceID3Variables replaces all id3 variables in the target file name
@@ -396,6 +749,10 @@ func replaceID3Variables(
 // the corresponding exif variable will be replaced by an empty
 // string.
 func getExifData(sourcePath string) (*Exif, error) {
+	if exifData, ok := metadata.exifData(sourcePath); ok {
+		return exifData, nil
+	}
+
 	f, err := os.Open(sourcePath)
 	if err != nil {
 		return nil, err
@@ -421,6 +778,8 @@ func getExifData(sourcePath string) (*Exif, error) {
 		}
 	}
 
+	metadata.setExifData(sourcePath, exifData)
+
 	return exifData, nil
 }
 
@@ -704,6 +1063,10 @@ func replaceIndex(
 			}
 		}
 
+		if num > highestIndex {
+			highestIndex = num
+		}
+
 		numInt64 := int64(num)
 
 		var formattedNum string
@@ -735,15 +1098,299 @@ func replaceIndex(
 	return target
 }
 
+// applyStartStep returns a copy of the indexing variables in which those
+// that do not specify a start number or a step use the provided ones
+// (see --start-step). The step does not apply to the variables that
+// number a capture group.
+func applyStartStep(indexing indexVars, start, step int) indexVars {
+	matches := make([]indexVarMatch, len(indexing.matches))
+	copy(matches, indexing.matches)
+
+	for i := range matches {
+		if !matches[i].startIsSet {
+			matches[i].startNumber = start
+		}
+
+		if !matches[i].step.isSet &&
+			!slices.Contains(indexing.capturVarIndex, i) {
+			matches[i].step.isSet = true
+			matches[i].step.value = step
+		}
+	}
+
+	indexing.matches = matches
+
+	return indexing
+}
+
+// continueCounter returns a copy of the indexing variables that start after
+// the last number used in a previous renaming operation unless they specify
+// a higher starting number.
+func continueCounter(indexing indexVars, last int) indexVars {
+	matches := make([]indexVarMatch, len(indexing.matches))
+	copy(matches, indexing.matches)
+
+	for i := range matches {
+		if matches[i].startNumber <= last {
+			matches[i].startNumber = last + 1
+		}
+	}
+
+	indexing.matches = matches
+
+	return indexing
+}
+
+// removeDiacritics maps accented letters to their base letter through
+// Unicode decomposition while leaving other characters intact.
+func removeDiacritics(source string) string {
+	t := transform.Chain(
+		norm.NFD,
+		runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC,
+	)
+
+	result, _, err := transform.String(t, source)
+	if err != nil {
+		return source
+	}
+
+	return result
+}
+
+// isWordSeparator reports whether r separates the words in a file name.
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == '-' || r == '_' || r == '.'
+}
+
+// trimToWordBoundary shortens the source to at most n runes. The cut is made
+// at the last word boundary before the limit so that words are kept whole,
+// unless the first word is longer than the limit.
+func trimToWordBoundary(source string, n int) string {
+	r := []rune(source)
+	if len(r) <= n {
+		return source
+	}
+
+	cut := n
+
+	for i := n; i > 0; i-- {
+		if isWordSeparator(r[i]) {
+			cut = i
+			break
+		}
+	}
+
+	trimmed := strings.TrimRightFunc(string(r[:cut]), isWordSeparator)
+	if trimmed == "" {
+		return string(r[:n])
+	}
+
+	return trimmed
+}
+
+// sliceRunes returns the runes of the source between the start and
+// (optional) end indices in bounds which are separated by a colon. Negative
+// indices count from the end of the source and out of range indices are
+// clamped so that an empty string is returned instead of panicking.
+func sliceRunes(source, bounds string) string {
+	r := []rune(source)
+
+	index := func(s string) (int, error) {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, err
+		}
+
+		if i < 0 {
+			i += len(r)
+		}
+
+		if i < 0 {
+			return 0, nil
+		}
+
+		if i > len(r) {
+			return len(r), nil
+		}
+
+		return i, nil
+	}
+
+	startStr, endStr, hasEnd := strings.Cut(bounds, ":")
+
+	start, err := index(startStr)
+	if err != nil {
+		return source
+	}
+
+	end := len(r)
+
+	if hasEnd {
+		end, err = index(endStr)
+		if err != nil {
+			return source
+		}
+	}
+
+	if start >= end {
+		return ""
+	}
+
+	return string(r[start:end])
+}
+
+// Alignments of a value within a fixed width.
+const (
+	alignLeft   = "left"
+	alignRight  = "right"
+	alignCenter = "center"
+)
+
+// widthSpec describes how a value is padded or truncated to a fixed width.
+type widthSpec struct {
+	align string
+	width int
+	fill  rune
+}
+
+// parseWidthSpec parses a specification in the form
+// width=N[:align=left|right|center][:fill=C]. The value is left aligned
+// and padded with spaces by default.
+func parseWidthSpec(spec string) (widthSpec, error) {
+	ws := widthSpec{
+		align: alignLeft,
+		fill:  ' ',
+	}
+
+	invalid := fmt.Errorf(errInvalidWidthSpec.Error(), spec)
+
+	widthStr, rest, _ := strings.Cut(strings.TrimPrefix(spec, "width="), ":")
+
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width < 1 {
+		return ws, invalid
+	}
+
+	ws.width = width
+
+	for rest != "" {
+		var option string
+
+		switch {
+		case strings.HasPrefix(rest, "fill="):
+			// The fill character may itself be a colon
+			rest = strings.TrimPrefix(rest, "fill=")
+
+			fill, size := utf8.DecodeRuneInString(rest)
+			if fill == utf8.RuneError {
+				return ws, invalid
+			}
+
+			ws.fill = fill
+			rest = rest[size:]
+		case strings.HasPrefix(rest, "align="):
+			option, rest, _ = strings.Cut(strings.TrimPrefix(rest, "align="), ":")
+
+			switch option {
+			case alignLeft, alignRight, alignCenter:
+				ws.align = option
+			default:
+				return ws, invalid
+			}
+
+			continue
+		default:
+			return ws, invalid
+		}
+
+		if rest != "" {
+			if rest[0] != ':' {
+				return ws, invalid
+			}
+
+			rest = rest[1:]
+		}
+	}
+
+	return ws, nil
+}
+
+// fit pads or truncates the value to the exact width of the specification.
+// Left aligned values keep their start, right aligned values keep their end
+// and centered values are trimmed (or padded) evenly on both sides.
+func (ws widthSpec) fit(value string) string {
+	r := []rune(value)
+
+	diff := ws.width - len(r)
+
+	var before, after int
+
+	switch ws.align {
+	case alignLeft:
+		after = diff
+	case alignRight:
+		before = diff
+	default:
+		before = diff / 2 //nolint:gomnd // half of the difference
+		after = diff - before
+	}
+
+	if diff < 0 {
+		return string(r[-before : len(r)+after])
+	}
+
+	fill := string(ws.fill)
+
+	return strings.Repeat(fill, before) + value + strings.Repeat(fill, after)
+}
+
+// reverseGraphemes reverses the order of the user-perceived characters in
+// the source so that combining marks and multi-codepoint emoji stay
+// attached to their base characters. Since no characters are added, the
+// result never contains a character that was not already in the source.
+func reverseGraphemes(source string) string {
+	var clusters []string
+
+	gr := uniseg.NewGraphemes(source)
+	for gr.Next() {
+		clusters = append(clusters, gr.Str())
+	}
+
+	var sb strings.Builder
+
+	sb.Grow(len(source))
+
+	for i := len(clusters) - 1; i >= 0; i-- {
+		sb.WriteString(clusters[i])
+	}
+
+	return sb.String()
+}
+
 func transformString(source, token string) string {
+	// The fixed width is applied after any other token
+	if i := strings.Index(token, "width="); i != -1 {
+		spec, err := parseWidthSpec(token[i:])
+		if err != nil {
+			return source
+		}
+
+		return spec.fit(
+			transformString(source, strings.TrimSuffix(token[:i], ".")),
+		)
+	}
+
 	switch token {
 	case "up":
 		return strings.ToUpper(source)
-	case "lw":
+	case "lw", "low":
 		return strings.ToLower(source)
 	case "ti":
 		c := cases.Title(language.English)
 		return c.String(strings.ToLower(source))
+	case "title":
+		return titleCaseWords(source)
 	case "win":
 		return regexReplace(
 			internalos.CompleteWindowsForbiddenCharRegex,
@@ -753,19 +1400,23 @@ func transformString(source, token string) string {
 		)
 	case "mac":
 		return regexReplace(internalos.MacForbiddenCharRegex, source, "", 0)
-	case "di":
-		t := transform.Chain(
-			norm.NFD,
-			runes.Remove(runes.In(unicode.Mn)),
-			norm.NFC,
-		)
+	case "di", "unaccent":
+		return removeDiacritics(source)
+	case "reverse":
+		return reverseGraphemes(source)
+	}
 
-		result, _, err := transform.String(t, source)
+	if strings.HasPrefix(token, "trim:") {
+		n, err := strconv.Atoi(strings.TrimPrefix(token, "trim:"))
 		if err != nil {
 			return source
 		}
 
-		return result
+		return trimToWordBoundary(source, n)
+	}
+
+	if strings.HasPrefix(token, "slice:") {
+		return sliceRunes(source, strings.TrimPrefix(token, "slice:"))
 	}
 
 	if strings.HasPrefix(token, "dt.") {
@@ -786,6 +1437,37 @@ func transformString(source, token string) string {
 	return source
 }
 
+// titleCaseWords capitalizes the first letter of each whitespace separated
+// word and lowercases the rest. Unlike the ti transform, the letters that
+// follow punctuation (such as a hyphen) within a word are not capitalized.
+func titleCaseWords(source string) string {
+	var sb strings.Builder
+
+	atWordStart := true
+
+	for _, r := range source {
+		switch {
+		case unicode.IsSpace(r):
+			atWordStart = true
+		case atWordStart:
+			r = unicode.ToTitle(r)
+			atWordStart = false
+		default:
+			r = unicode.ToLower(r)
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// expandCaptureCase expands the <$1.up> shorthand for changing the case of
+// a capture group in the replacement to the {{<$1>.up}} transform variable.
+func expandCaptureCase(replacement string) string {
+	return captureCaseRegex.ReplaceAllString(replacement, "{{<$1>.$2}}")
+}
+
 // replaceTransformVars handles string transformations like uppercase,
 // lowercase, stripping characters, e.t.c.
 func replaceTransformVars(
@@ -911,6 +1593,20 @@ func replaceFilenameVars(
 	return target
 }
 
+// replaceOrigVars replaces the original name of the file (including its
+// extension) in the target. It is unaffected by previous steps in a chain.
+func replaceOrigVars(target, originalName string, ov origVars) string {
+	for i := range ov.matches {
+		current := ov.matches[i]
+
+		source := transformString(originalName, current.transformToken)
+
+		target = regexReplace(current.regex, target, source, 0)
+	}
+
+	return target
+}
+
 func replaceExtVars(target, fileExt string, ev extVars) string {
 	for i := range ev.matches {
 		current := ev.matches[i]
@@ -923,16 +1619,88 @@ func replaceExtVars(target, fileExt string, ev extVars) string {
 	return target
 }
 
+// defaultVar is a variable that specifies a default value
+// (such as `{{id3.album|Unknown Album}}`).
+type defaultVar struct {
+	variable string // the variable without its default value
+	name     string // the text between the braces of the variable
+	fallback string
+}
+
+// markDefaultVars removes the default value from each variable that
+// specifies one and encloses the variable in markers so that it is replaced
+// along with the other variables. The variables are returned in order so
+// that their default values can be applied afterwards (see
+// applyDefaultVars).
+func markDefaultVars(target string) (string, []defaultVar) {
+	var defaults []defaultVar
+
+	target = defaultVarRegex.ReplaceAllStringFunc(
+		target,
+		func(match string) string {
+			submatch := defaultVarRegex.FindStringSubmatch(match)
+
+			v := defaultVar{
+				variable: submatch[1] + submatch[2] + submatch[4],
+				name:     submatch[2],
+				fallback: submatch[3],
+			}
+
+			defaults = append(defaults, v)
+
+			return defaultVarMarker + v.variable + defaultVarMarker
+		},
+	)
+
+	return target, defaults
+}
+
+// applyDefaultVars replaces each marked variable with its value or with its
+// default value if the variable resolved to an empty string.
+func applyDefaultVars(target string, defaults []defaultVar) string {
+	i := 0
+
+	return markedVarRegex.ReplaceAllStringFunc(
+		target,
+		func(match string) string {
+			v := defaults[i]
+			i++
+
+			value := strings.Trim(match, defaultVarMarker)
+
+			// Text that is not a variable (such as an expanded capture group)
+			// is used as is
+			if value == v.variable {
+				value = v.name
+			}
+
+			if strings.TrimSpace(value) == "" {
+				value = v.fallback
+			}
+
+			return value
+		},
+	)
+}
+
 // replaceVariables checks if any variables are present in the target filename
 // and delegates the variable replacement to the appropriate function.
+// The index is the number used for indexing variables and the total is the
+// number of matches that the index is counted against.
 func replaceVariables(
 	conf *config.Config,
 	change *file.Change,
+	index, total int,
 	vars *variables,
 ) error {
 	fileExt := filepath.Ext(change.OriginalSource)
 	sourcePath := filepath.Join(change.BaseDir, change.OriginalSource)
 
+	var defaults []defaultVar
+	if defaultVarRegex.MatchString(change.Target) {
+		change.Target, defaults = markDefaultVars(change.Target)
+	}
+
 	if len(vars.filename.matches) > 0 {
 		sourceName := filepath.Base(sourcePath)
 		if !change.IsDir {
@@ -946,6 +1714,14 @@ func replaceVariables(
 		)
 	}
 
+	if len(vars.orig.matches) > 0 {
+		change.Target = replaceOrigVars(
+			change.Target,
+			change.OriginalSource,
+			vars.orig,
+		)
+	}
+
 	if len(vars.ext.matches) > 0 {
 		if change.IsDir {
 			fileExt = ""
@@ -1022,14 +1798,57 @@ func replaceVariables(
 		change.Target = out
 	}
 
+	if len(vars.stat.matches) > 0 {
+		out, err := replaceStatVars(
+			change.Target,
+			sourcePath,
+			conf.StatMaxBytes,
+			vars.stat,
+		)
+		if err != nil {
+			return err
+		}
+
+		change.Target = out
+	}
+
+	if len(vars.image.matches) > 0 {
+		out, err := replaceImageVars(change.Target, sourcePath, vars.image)
+		if err != nil {
+			return err
+		}
+
+		change.Target = out
+	}
+
+	if len(vars.video.matches) > 0 {
+		out, err := replaceVideoVars(change.Target, sourcePath, vars.video)
+		if err != nil {
+			return err
+		}
+
+		change.Target = out
+	}
+
 	if len(vars.random.matches) > 0 {
 		matches := conf.SearchRegex.FindAllString(change.Source, -1)
-		change.Target = replaceRandomVars(change.Target, matches, vars.random)
+
+		out, err := replaceRandomVars(
+			change.Target,
+			sourcePath,
+			matches,
+			vars.random,
+		)
+		if err != nil {
+			return err
+		}
+
+		change.Target = out
 	}
 
 	if transformVarRegex.MatchString(change.Target) {
 		sourceName := change.Source
-		if conf.IgnoreExt && !change.IsDir {
+		if conf.StemOnly() && !change.IsDir {
 			sourceName = internalpath.FilenameWithoutExtension(sourceName)
 		}
 
@@ -1047,6 +1866,21 @@ func replaceVariables(
 		change.Target = out
 	}
 
+	if totalVarRegex.MatchString(change.Target) {
+		change.Target = totalVarRegex.ReplaceAllString(
+			change.Target,
+			strconv.Itoa(total),
+		)
+	}
+
+	if len(vars.alpha.matches) > 0 {
+		change.Target = replaceAlphaVars(change.Target, index, vars.alpha)
+	}
+
+	if len(vars.roman.matches) > 0 {
+		change.Target = replaceRomanVars(change.Target, index, vars.roman)
+	}
+
 	if indexVarRegex.MatchString(change.Target) {
 		if len(vars.index.capturVarIndex) > 0 {
 			indices := make([]int, len(vars.index.capturVarIndex))
@@ -1062,13 +1896,30 @@ func replaceVariables(
 			vars.index.capturVarIndex = indices
 		}
 
+		indexing := vars.index
+		if conf.StartStep != "" {
+			indexing = applyStartStep(
+				indexing,
+				conf.StartNumber,
+				conf.NumberStep,
+			)
+		}
+
+		if conf.CounterFile != "" {
+			indexing = continueCounter(indexing, conf.CounterValue)
+		}
+
 		change.Target = replaceIndex(
 			change.Target,
-			change.Index,
-			vars.index,
+			index,
+			indexing,
 			conf.NumberOffset,
 		)
 	}
 
+	if len(defaults) > 0 {
+		change.Target = applyDefaultVars(change.Target, defaults)
+	}
+
 	return nil
 }
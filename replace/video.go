@@ -0,0 +1,491 @@
+package replace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+var errInvalidVideo = errors.New("invalid video container")
+
+// maxVideoHeaderSize is the maximum size of the metadata sections that are
+// read into memory when parsing a video container. The media data itself is
+// never read.
+const maxVideoHeaderSize = 16 << 20
+
+// Matroska element IDs (including the length marker).
+const (
+	ebmlHeaderID    = 0x1A45DFA3
+	mkvSegmentID    = 0x18538067
+	mkvInfoID       = 0x1549A966
+	mkvTracksID     = 0x1654AE6B
+	mkvClusterID    = 0x1F43B675
+	mkvTimescaleID  = 0x2AD7B1
+	mkvDurationID   = 0x4489
+	mkvTrackEntryID = 0xAE
+	mkvTrackTypeID  = 0x83
+	mkvCodecID      = 0x86
+	mkvVideoID      = 0xE0
+	mkvWidthID      = 0xB0
+	mkvHeightID     = 0xBA
+)
+
+const (
+	mkvVideoTrack         = 1
+	mkvDefaultTimescale   = 1000000 // in nanoseconds
+	mkvUnknownSize        = -1
+	mp4BoxHeaderLength    = 8
+	mp4LargeSizeIndicator = 1
+)
+
+// videoCodecs maps the codec identifiers in MP4 sample entries and Matroska
+// tracks to the common names of the codecs.
+var videoCodecs = map[string]string{
+	"avc1":             "h264",
+	"avc3":             "h264",
+	"hvc1":             "hevc",
+	"hev1":             "hevc",
+	"av01":             "av1",
+	"vp08":             "vp8",
+	"vp09":             "vp9",
+	"mp4v":             "mpeg4",
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_AV1":            "av1",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+}
+
+// videoCodecName returns the common name of the codec or a file name safe
+// version of the identifier if the codec is not known.
+func videoCodecName(id string) string {
+	if name, ok := videoCodecs[id]; ok {
+		return name
+	}
+
+	id = strings.TrimPrefix(strings.TrimSpace(id), "V_")
+
+	return strings.ToLower(strings.ReplaceAll(id, "/", "_"))
+}
+
+// formatVideoDuration formats the duration as HH-MM-SS since colons are
+// not allowed in file names on some platforms.
+func formatVideoDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+
+	return fmt.Sprintf(
+		"%02d-%02d-%02d",
+		d/time.Hour,
+		d%time.Hour/time.Minute,
+		d%time.Minute/time.Second,
+	)
+}
+
+// parseVideo reads the metadata of an MP4 or Matroska (MKV and WebM) video
+// from the headers of its container. A nil result is returned for other
+// files.
+func parseVideo(r io.ReadSeeker) (*VideoInfo, error) {
+	magic := make([]byte, mp4BoxHeaderLength)
+
+	_, err := io.ReadFull(r, magic)
+	if err != nil {
+		return nil, nil //nolint:nilerr // the file is too small to be a video
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case string(magic[4:8]) == "ftyp":
+		return parseMP4(r)
+	case binary.BigEndian.Uint32(magic[:4]) == ebmlHeaderID:
+		return parseMKV(r)
+	}
+
+	return nil, nil
+}
+
+// parseMP4 reads the duration from the movie header and the dimensions and
+// codec of the first video track in the moov box.
+func parseMP4(r io.ReadSeeker) (*VideoInfo, error) {
+	for {
+		boxType, size, err := readMP4BoxHeader(r)
+		if err != nil {
+			return nil, errInvalidVideo
+		}
+
+		if boxType != "moov" {
+			_, err = r.Seek(size, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if size > maxVideoHeaderSize {
+			return nil, errInvalidVideo
+		}
+
+		moov := make([]byte, size)
+
+		_, err = io.ReadFull(r, moov)
+		if err != nil {
+			return nil, errInvalidVideo
+		}
+
+		return parseMP4Movie(moov)
+	}
+}
+
+// readMP4BoxHeader returns the type of the next box and the size of its
+// contents.
+func readMP4BoxHeader(r io.Reader) (string, int64, error) {
+	header := make([]byte, mp4BoxHeaderLength)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return "", 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:4])) - mp4BoxHeaderLength
+	if size == mp4LargeSizeIndicator-mp4BoxHeaderLength {
+		largeSize := make([]byte, 8)
+
+		_, err = io.ReadFull(r, largeSize)
+		if err != nil {
+			return "", 0, err
+		}
+
+		size = int64(binary.BigEndian.Uint64(largeSize)) - 16
+	}
+
+	if size < 0 {
+		return "", 0, errInvalidVideo
+	}
+
+	return string(header[4:8]), size, nil
+}
+
+// mp4Boxes returns the contents of the child boxes in b by their type. Only
+// the first box of each type is returned except for tracks.
+func mp4Boxes(b []byte) (boxes map[string][]byte, tracks [][]byte) {
+	boxes = make(map[string][]byte)
+
+	for len(b) >= mp4BoxHeaderLength {
+		size := int(binary.BigEndian.Uint32(b[:4]))
+		if size < mp4BoxHeaderLength || size > len(b) {
+			break
+		}
+
+		boxType := string(b[4:8])
+		contents := b[mp4BoxHeaderLength:size]
+
+		if boxType == "trak" {
+			tracks = append(tracks, contents)
+		} else if _, ok := boxes[boxType]; !ok {
+			boxes[boxType] = contents
+		}
+
+		b = b[size:]
+	}
+
+	return boxes, tracks
+}
+
+func parseMP4Movie(moov []byte) (*VideoInfo, error) {
+	boxes, tracks := mp4Boxes(moov)
+
+	mvhd := boxes["mvhd"]
+	if len(mvhd) < 20 {
+		return nil, errInvalidVideo
+	}
+
+	info := &VideoInfo{}
+
+	// The version determines the size of the time fields
+	var timescale, duration uint64
+	if mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return nil, errInvalidVideo
+		}
+
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+
+	if timescale > 0 {
+		info.Duration = time.Duration(
+			float64(duration) / float64(timescale) * float64(time.Second),
+		)
+	}
+
+	for _, trak := range tracks {
+		trakBoxes, _ := mp4Boxes(trak)
+		mdiaBoxes, _ := mp4Boxes(trakBoxes["mdia"])
+
+		// The handler type follows the version, flags and pre-defined field
+		hdlr := mdiaBoxes["hdlr"]
+		if len(hdlr) < 12 || string(hdlr[8:12]) != "vide" {
+			continue
+		}
+
+		// The dimensions are the last fields of the track header as
+		// 16.16 fixed point numbers
+		tkhd := trakBoxes["tkhd"]
+		if len(tkhd) >= 8 {
+			info.Width = int(binary.BigEndian.Uint32(tkhd[len(tkhd)-8:]) >> 16)
+			info.Height = int(binary.BigEndian.Uint32(tkhd[len(tkhd)-4:]) >> 16)
+		}
+
+		// The format of the first sample entry identifies the codec
+		minfBoxes, _ := mp4Boxes(mdiaBoxes["minf"])
+		stblBoxes, _ := mp4Boxes(minfBoxes["stbl"])
+
+		stsd := stblBoxes["stsd"]
+		if len(stsd) >= 16 {
+			info.Codec = videoCodecName(string(stsd[12:16]))
+		}
+
+		break
+	}
+
+	return info, nil
+}
+
+// readEBMLVint reads a variable size integer. The length marker is kept for
+// element IDs but removed for element sizes.
+func readEBMLVint(r io.Reader, keepMarker bool) (int64, error) {
+	b := make([]byte, 1)
+
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+
+	if length > 8 {
+		return 0, errInvalidVideo
+	}
+
+	rest := make([]byte, length-1)
+
+	_, err = io.ReadFull(r, rest)
+	if err != nil {
+		return 0, err
+	}
+
+	value := uint64(b[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+
+	allOnes := value == uint64(0xFF>>length)
+
+	for _, c := range rest {
+		value = value<<8 | uint64(c)
+		allOnes = allOnes && c == 0xFF
+	}
+
+	if !keepMarker && allOnes {
+		return mkvUnknownSize, nil
+	}
+
+	return int64(value), nil
+}
+
+// readEBMLElement reads the ID and size of the next element.
+func readEBMLElement(r io.Reader) (id, size int64, err error) {
+	id, err = readEBMLVint(r, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size, err = readEBMLVint(r, false)
+
+	return id, size, err
+}
+
+// ebmlChildren calls fn with the ID and contents of each child element in b
+// until it returns false.
+func ebmlChildren(b []byte, fn func(id int64, data []byte) bool) {
+	r := bytes.NewReader(b)
+
+	for r.Len() > 0 {
+		id, size, err := readEBMLElement(r)
+		if err != nil || size < 0 || size > int64(r.Len()) {
+			return
+		}
+
+		data := b[len(b)-r.Len() : len(b)-r.Len()+int(size)]
+
+		if !fn(id, data) {
+			return
+		}
+
+		_, _ = r.Seek(size, io.SeekCurrent)
+	}
+}
+
+func ebmlUint(b []byte) int64 {
+	var value int64
+	for _, c := range b {
+		value = value<<8 | int64(c)
+	}
+
+	return value
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
+
+// parseMKV reads the duration from the segment information and the
+// dimensions and codec of the first video track in a Matroska container.
+func parseMKV(r io.ReadSeeker) (*VideoInfo, error) {
+	id, size, err := readEBMLElement(r)
+	if err != nil || id != ebmlHeaderID || size < 0 {
+		return nil, errInvalidVideo
+	}
+
+	_, err = r.Seek(size, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _, err = readEBMLElement(r)
+	if err != nil || id != mkvSegmentID {
+		return nil, errInvalidVideo
+	}
+
+	var info, tracks []byte
+
+	// The size of the segment is ignored since it may be unknown. Its
+	// children are read until the first cluster of media data
+	for info == nil || tracks == nil {
+		id, size, err = readEBMLElement(r)
+		if err != nil || id == mkvClusterID || size < 0 {
+			break
+		}
+
+		if id != mkvInfoID && id != mkvTracksID {
+			_, err = r.Seek(size, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if size > maxVideoHeaderSize {
+			return nil, errInvalidVideo
+		}
+
+		data := make([]byte, size)
+
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return nil, errInvalidVideo
+		}
+
+		if id == mkvInfoID {
+			info = data
+		} else {
+			tracks = data
+		}
+	}
+
+	if info == nil && tracks == nil {
+		return nil, errInvalidVideo
+	}
+
+	return parseMKVSegment(info, tracks), nil
+}
+
+func parseMKVSegment(info, tracks []byte) *VideoInfo {
+	video := &VideoInfo{}
+
+	timescale := int64(mkvDefaultTimescale)
+
+	var duration float64
+
+	ebmlChildren(info, func(id int64, data []byte) bool {
+		switch id {
+		case mkvTimescaleID:
+			timescale = ebmlUint(data)
+		case mkvDurationID:
+			duration = ebmlFloat(data)
+		}
+
+		return true
+	})
+
+	video.Duration = time.Duration(duration * float64(timescale))
+
+	ebmlChildren(tracks, func(id int64, entry []byte) bool {
+		if id != mkvTrackEntryID {
+			return true
+		}
+
+		var trackType int64
+
+		var codec string
+
+		var width, height int
+
+		ebmlChildren(entry, func(id int64, data []byte) bool {
+			switch id {
+			case mkvTrackTypeID:
+				trackType = ebmlUint(data)
+			case mkvCodecID:
+				codec = string(bytes.TrimRight(data, "\x00"))
+			case mkvVideoID:
+				ebmlChildren(data, func(id int64, data []byte) bool {
+					switch id {
+					case mkvWidthID:
+						width = int(ebmlUint(data))
+					case mkvHeightID:
+						height = int(ebmlUint(data))
+					}
+
+					return true
+				})
+			}
+
+			return true
+		})
+
+		if trackType != mkvVideoTrack {
+			return true
+		}
+
+		video.Width, video.Height = width, height
+		video.Codec = videoCodecName(codec)
+
+		return false
+	})
+
+	return video
+}
@@ -0,0 +1,82 @@
+package replace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+var errWebPDecode = errors.New(
+	"only the dimensions of WebP images can be read",
+)
+
+var errInvalidWebP = errors.New("invalid WebP header")
+
+// webpHeaderLength is the number of bytes needed to read the dimensions of
+// a WebP image regardless of its encoding.
+const webpHeaderLength = 30
+
+// vp8lSignature is the first byte of a lossless bitstream.
+const vp8lSignature = 0x2f
+
+// vp8StartCode follows the frame tag of a lossy key frame.
+var vp8StartCode = []byte{0x9d, 0x01, 0x2a}
+
+func init() {
+	image.RegisterFormat(
+		"webp",
+		"RIFF????WEBP",
+		decodeWebP,
+		decodeWebPConfig,
+	)
+}
+
+func decodeWebP(io.Reader) (image.Image, error) {
+	return nil, errWebPDecode
+}
+
+// decodeWebPConfig reads the dimensions of a lossy (VP8), lossless (VP8L),
+// or extended (VP8X) WebP image from its header.
+func decodeWebPConfig(r io.Reader) (image.Config, error) {
+	var conf image.Config
+
+	b := make([]byte, webpHeaderLength)
+
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return conf, err
+	}
+
+	switch string(b[12:16]) {
+	case "VP8 ":
+		// The frame tag is followed by the start code and the dimensions
+		// whose upper 2 bits hold the scale
+		if !bytes.Equal(b[23:26], vp8StartCode) {
+			return conf, errInvalidWebP
+		}
+
+		conf.Width = int(binary.LittleEndian.Uint16(b[26:28]) & 0x3fff)
+		conf.Height = int(binary.LittleEndian.Uint16(b[28:30]) & 0x3fff)
+	case "VP8L":
+		// The signature is followed by the dimensions (minus one) in the
+		// lower 28 bits
+		if b[20] != vp8lSignature {
+			return conf, errInvalidWebP
+		}
+
+		bits := binary.LittleEndian.Uint32(b[21:25])
+		conf.Width = int(bits&0x3fff) + 1
+		conf.Height = int(bits>>14&0x3fff) + 1
+	case "VP8X":
+		// The flags are followed by the canvas dimensions (minus one) in 24
+		// bits each
+		conf.Width = int(uint32(b[24])|uint32(b[25])<<8|uint32(b[26])<<16) + 1
+		conf.Height = int(uint32(b[27])|uint32(b[28])<<8|uint32(b[29])<<16) + 1
+	default:
+		return conf, errInvalidWebP
+	}
+
+	return conf, nil
+}
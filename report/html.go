@@ -0,0 +1,120 @@
+package report
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	internaljson "github.com/ayoisaiah/f2/internal/json"
+	"github.com/ayoisaiah/f2/internal/status"
+)
+
+type htmlRow struct {
+	Source string
+	Target string
+	Status string
+	Class  string
+}
+
+type htmlPage struct {
+	WorkingDir string
+	Date       string
+	Changes    []htmlRow
+	Conflicts  []htmlRow
+	DryRun     bool
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8" />
+<title>F2 renaming operation</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.6em; text-align: left; }
+th { background: #f2f2f2; }
+.ok { color: #1a7f37; }
+.unchanged { color: #6e7781; }
+.warning { color: #9a6700; }
+.error { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>F2 renaming operation{{if .DryRun}} (dry run){{end}}</h1>
+<p>Working directory: <code>{{.WorkingDir}}</code></p>
+<p>Date: {{.Date}}</p>
+{{- if .Conflicts}}
+<h2>Conflicts</h2>
+<table>
+<tr><th>ORIGINAL</th><th>RENAMED</th><th>STATUS</th></tr>
+{{- range .Conflicts}}
+<tr><td>{{.Source}}</td><td>{{.Target}}</td><td class="{{.Class}}">{{.Status}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Changes}}
+<h2>Changes</h2>
+<table>
+<tr><th>ORIGINAL</th><th>RENAMED</th><th>STATUS</th></tr>
+{{- range .Changes}}
+<tr><td>{{.Source}}</td><td>{{.Target}}</td><td class="{{.Class}}">{{.Status}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlRows converts the rows prepared for the table output so that the
+// color of each status is represented by a CSS class.
+func htmlRows(data [][]string, isConflict bool) []htmlRow {
+	rows := make([]htmlRow, 0, len(data))
+
+	for _, d := range data {
+		row := htmlRow{
+			Source: d[0],
+			Target: d[1],
+			Status: pterm.RemoveColorFromString(d[2]),
+		}
+
+		switch {
+		case isConflict:
+			row.Class = "error"
+		case row.Status == "":
+			row.Status = string(status.Unchanged)
+			row.Class = "unchanged"
+		case row.Status == string(status.OK):
+			row.Class = "ok"
+		case row.Status == string(status.Overwriting):
+			row.Class = "warning"
+		default:
+			row.Class = "error"
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// printHTML renders the changes and conflicts as a self-contained
+// HTML page.
+func printHTML(
+	changes, conflicts [][]string,
+	jsonOpts *internaljson.OutputOpts,
+) {
+	page := htmlPage{
+		WorkingDir: jsonOpts.WorkingDir,
+		Date:       jsonOpts.Date.Format(time.RFC3339),
+		DryRun:     !jsonOpts.Exec,
+		Changes:    htmlRows(changes, false),
+		Conflicts:  htmlRows(conflicts, true),
+	}
+
+	err := htmlTemplate.Execute(Stdout, page)
+	if err != nil {
+		pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+	}
+}
@@ -3,19 +3,25 @@
 package report
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pterm/pterm"
 
+	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/conflict"
 	"github.com/ayoisaiah/f2/internal/file"
 	internaljson "github.com/ayoisaiah/f2/internal/json"
+	internalpath "github.com/ayoisaiah/f2/internal/path"
 	internalsort "github.com/ayoisaiah/f2/internal/sort"
 	"github.com/ayoisaiah/f2/internal/status"
+	"github.com/ayoisaiah/f2/internal/warning"
 )
 
 var (
@@ -23,10 +29,14 @@ var (
 	Stderr io.Writer = os.Stderr
 )
 
-func printTable(data [][]string, writer io.Writer) {
-	d := [][]string{
-		{"ORIGINAL", "RENAMED", "STATUS"},
-	}
+var changesTableHeader = []string{"ORIGINAL", "RENAMED", "STATUS"}
+
+// indexTableHeader is the header of the column that --show-index prepends
+// to the table of changes.
+const indexTableHeader = "INDEX"
+
+func printTable(header []string, data [][]string, writer io.Writer) {
+	d := [][]string{header}
 
 	d = append(d, data...)
 
@@ -36,35 +46,19 @@ func printTable(data [][]string, writer io.Writer) {
 
 	str, err := table.WithHasHeader().WithData(d).Srender()
 	if err != nil {
-		pterm.Error.Printfln("Unable to print table: %s", err.Error())
+		pterm.Fprintln(
+			Stderr,
+			pterm.Error.Sprintf("Unable to print table: %s", err.Error()),
+		)
 		return
 	}
 
 	fmt.Fprintln(writer, str)
 }
 
-// Changes displays the changes to be made in a table or json format.
-func Changes(
-	changes []*file.Change,
-	errs []int,
-	quiet bool,
-	jsonOpts *internaljson.OutputOpts,
-) {
-	if quiet {
-		return
-	}
-
-	if jsonOpts.Print {
-		o, err := internaljson.GetOutput(jsonOpts, changes, errs)
-		if err != nil {
-			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
-		}
-
-		pterm.Fprintln(Stdout, string(o))
-
-		return
-	}
-
+// changesTableData prepares a row (source, target, and color-coded status)
+// for each change.
+func changesTableData(changes []*file.Change) [][]string {
 	data := make([][]string, len(changes))
 
 	for i := range changes {
@@ -99,25 +93,12 @@ func Changes(
 		data[i] = d
 	}
 
-	printTable(data, Stdout)
+	return data
 }
 
-// Conflicts prints any detected conflicts to the standard output in table format.
-func Conflicts(
-	conflicts conflict.Collection,
-	jsonOpts *internaljson.OutputOpts,
-) {
-	if jsonOpts.Print {
-		o, err := internaljson.GetOutput(jsonOpts, nil, nil)
-		if err != nil {
-			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
-		}
-
-		pterm.Fprintln(Stdout, string(o))
-
-		return
-	}
-
+// conflictsTableData prepares a row (sources, target, and the reason for
+// the conflict) for each detected conflict.
+func conflictsTableData(conflicts conflict.Collection) [][]string {
 	var data [][]string
 
 	if slice, exists := conflicts[conflict.EmptyFilename]; exists {
@@ -131,6 +112,17 @@ func Conflicts(
 		}
 	}
 
+	if slice, exists := conflicts[conflict.WorkingDirRename]; exists {
+		for _, v := range slice {
+			slice := []string{
+				strings.Join(v.Sources, ""),
+				v.Target,
+				pterm.Red(status.WorkingDirRename),
+			}
+			data = append(data, slice)
+		}
+	}
+
 	if slice, exists := conflicts[conflict.TrailingPeriod]; exists {
 		for _, v := range slice {
 			for _, s := range v.Sources {
@@ -157,6 +149,33 @@ func Conflicts(
 		}
 	}
 
+	if slice, exists := conflicts[conflict.TypeMismatch]; exists {
+		for _, v := range slice {
+			slice := []string{
+				strings.Join(v.Sources, ""),
+				v.Target,
+				pterm.Red(
+					fmt.Sprintf(
+						string(status.TypeMismatch),
+						v.Cause,
+					),
+				),
+			}
+			data = append(data, slice)
+		}
+	}
+
+	if slice, exists := conflicts[conflict.OverwritingSource]; exists {
+		for _, v := range slice {
+			slice := []string{
+				strings.Join(v.Sources, ""),
+				v.Target,
+				pterm.Red(status.OverwritingSource),
+			}
+			data = append(data, slice)
+		}
+	}
+
 	if slice, exists := conflicts[conflict.OverwritingNewPath]; exists {
 		for _, v := range slice {
 			for _, s := range v.Sources {
@@ -206,18 +225,375 @@ func Conflicts(
 		}
 	}
 
-	printTable(data, Stdout)
+	if slice, exists := conflicts[conflict.MaxPathLengthExceeded]; exists {
+		for _, v := range slice {
+			slice := []string{
+				strings.Join(v.Sources, ""),
+				v.Target,
+				pterm.Red(
+					fmt.Sprintf(
+						string(status.PathLengthExceeded),
+						v.Cause,
+					),
+				),
+			}
+			data = append(data, slice)
+		}
+	}
+
+	return data
+}
+
+// Changes displays the changes to be made in a table, tree, json, or html
+// format.
+func Changes(
+	changes []*file.Change,
+	errs []int,
+	quiet bool,
+	jsonOpts *internaljson.OutputOpts,
+) {
+	if quiet {
+		return
+	}
+
+	if jsonOpts.Stream {
+		err := internaljson.WriteStream(Stdout, jsonOpts, changes, errs)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+		}
+
+		return
+	}
+
+	if jsonOpts.Print {
+		o, err := internaljson.GetOutput(jsonOpts, changes, errs)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+			return
+		}
+
+		pterm.Fprintln(Stdout, string(o))
+
+		return
+	}
+
+	data := changesTableData(changes)
+
+	if jsonOpts.HTML {
+		printHTML(data, nil, jsonOpts)
+		return
+	}
+
+	if jsonOpts.Tree {
+		printTree(changes, data, jsonOpts.WorkingDir, Stdout)
+		return
+	}
+
+	if jsonOpts.ShowIndex {
+		for i := range data {
+			data[i] = append(
+				[]string{strconv.Itoa(changes[i].Index)},
+				data[i]...,
+			)
+		}
+
+		printTable(
+			append([]string{indexTableHeader}, changesTableHeader...),
+			data,
+			Stdout,
+		)
+
+		return
+	}
+
+	printTable(changesTableHeader, data, Stdout)
+}
+
+// Conflicts prints any detected conflicts to the standard output in table
+// (or html) format.
+func Conflicts(
+	conflicts conflict.Collection,
+	jsonOpts *internaljson.OutputOpts,
+) {
+	if jsonOpts.Stream {
+		err := internaljson.WriteStream(Stdout, jsonOpts, nil, nil)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+		}
+
+		return
+	}
+
+	if jsonOpts.Print {
+		o, err := internaljson.GetOutput(jsonOpts, nil, nil)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+			return
+		}
+
+		pterm.Fprintln(Stdout, string(o))
+
+		return
+	}
+
+	data := conflictsTableData(conflicts)
+
+	if jsonOpts.HTML {
+		printHTML(nil, data, jsonOpts)
+		return
+	}
+
+	printTable(changesTableHeader, data, Stdout)
+}
+
+// Count prints the number of matches in each directory and the
+// total number of matches.
+func Count(
+	matches internalpath.Collection,
+	quiet bool,
+	jsonOpts *internaljson.OutputOpts,
+) {
+	if quiet {
+		return
+	}
+
+	directories := make(map[string]int, len(matches))
+
+	var total int
+
+	for dir, entries := range matches {
+		directories[dir] = len(entries)
+		total += len(entries)
+	}
+
+	if jsonOpts.Print {
+		o, err := internaljson.GetCountOutput(jsonOpts, directories, total)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+			return
+		}
+
+		pterm.Fprintln(Stdout, string(o))
+
+		return
+	}
+
+	dirs := make([]string, 0, len(directories))
+
+	for dir := range directories {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	data := make([][]string, len(dirs))
+
+	for i, dir := range dirs {
+		data[i] = []string{dir, strconv.Itoa(directories[dir])}
+	}
+
+	if len(data) > 0 {
+		printTable([]string{"DIRECTORY", "MATCHES"}, data, Stdout)
+	}
+
+	fmt.Fprintf(Stdout, "Total matches: %d\n", total)
+}
+
+// DuplicateNames prints the matches that share the same name across
+// different directories grouped by the name. These would collide if the
+// matches were moved into a single directory.
+func DuplicateNames(
+	matches internalpath.Collection,
+	quiet bool,
+	jsonOpts *internaljson.OutputOpts,
+) {
+	if quiet {
+		return
+	}
+
+	groups := make(map[string][]string)
+
+	for dir, entries := range matches {
+		for _, entry := range entries {
+			groups[entry.Name()] = append(
+				groups[entry.Name()],
+				filepath.Join(dir, entry.Name()),
+			)
+		}
+	}
+
+	duplicates := make(map[string][]string)
+
+	for name, paths := range groups {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			duplicates[name] = paths
+		}
+	}
+
+	if jsonOpts.Print {
+		o, err := internaljson.GetDuplicatesOutput(jsonOpts, duplicates)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+			return
+		}
+
+		pterm.Fprintln(Stdout, string(o))
+
+		return
+	}
+
+	names := make([]string, 0, len(duplicates))
+
+	for name := range duplicates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var data [][]string
+
+	for _, name := range names {
+		for _, path := range duplicates[name] {
+			data = append(data, []string{name, path})
+		}
+	}
+
+	if len(data) > 0 {
+		printTable([]string{"NAME", "PATH"}, data, Stdout)
+	}
+
+	fmt.Fprintf(Stdout, "Duplicate names: %d\n", len(names))
+}
+
+// Config prints the effective configuration of the renaming operation (after
+// the config files, default options, and flags are applied) in JSON format.
+func Config(conf *config.Config) {
+	b, err := json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+		return
+	}
+
+	pterm.Fprintln(Stdout, string(b))
+}
+
+// Warning prints the provided message to the standard error and records it
+// so that it is also included in the JSON output.
+func Warning(msg string) {
+	warning.Add(msg)
+
+	pterm.Fprintln(Stderr, pterm.Warning.Sprint(msg))
 }
 
 func BackupFailed(err error) {
-	pterm.Fprintln(Stderr,
-		pterm.Warning.Sprintf(
+	Warning(
+		fmt.Sprintf(
 			"Failed to backup renaming operation due to error: %s",
 			err.Error(),
 		),
 	)
 }
 
+// ManifestFailed prints a warning if the renaming operation could not be
+// recorded in the manifest file.
+func ManifestFailed(err error) {
+	Warning(
+		fmt.Sprintf(
+			"Failed to record renaming operation in the manifest due to error: %s",
+			err.Error(),
+		),
+	)
+}
+
+// Fixes prints the conflicts that were automatically fixed for each change
+// alongside the originally proposed target.
+func Fixes(changes []*file.Change) {
+	for _, change := range changes {
+		sourcePath := filepath.Join(change.BaseDir, change.Source)
+
+		for _, fix := range change.Fixes {
+			pterm.Fprintln(Stderr,
+				pterm.Info.Sprintf(
+					"Fixed '%s' -> '%s': %s",
+					pterm.Yellow(sourcePath),
+					pterm.Yellow(filepath.Join(change.BaseDir, fix.Target)),
+					fix.Reason,
+				),
+			)
+		}
+	}
+}
+
+// Failures prints the changes that could not be committed to the
+// standard error alongside the reason.
+func Failures(changes []*file.Change) {
+	for _, change := range changes {
+		if change.Error == nil {
+			continue
+		}
+
+		pterm.Fprintln(Stderr,
+			pterm.Error.Sprintf(
+				"Failed to rename '%s' to '%s': %s",
+				filepath.Join(change.BaseDir, change.Source),
+				filepath.Join(change.BaseDir, change.Target),
+				change.Error,
+			),
+		)
+	}
+}
+
+// Skipped explains why a path was not matched.
+func Skipped(path, reason string) {
+	fmt.Fprintf(Stderr, "Skipped '%s': %s\n", path, reason)
+}
+
+// Summary prints the number of paths that were renamed, skipped, or
+// failed alongside the directories that were created and the elapsed time.
+func Summary(stats *internaljson.Stats) {
+	fmt.Fprintf(
+		Stdout,
+		"Renamed %d %s, skipped %d, failed %d, created %d %s in %s\n",
+		stats.Renamed,
+		plural(stats.Renamed, "path", "paths"),
+		stats.Skipped,
+		stats.Failed,
+		stats.DirectoriesCreated,
+		plural(stats.DirectoriesCreated, "directory", "directories"),
+		stats.Elapsed,
+	)
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+
+	return pluralForm
+}
+
+// BackupCreated prints the location of the backup file for a
+// renaming operation.
+func BackupCreated(backupFilePath string) {
+	pterm.Fprintln(Stderr,
+		pterm.Success.Sprintf(
+			"Backup file created at: %s",
+			backupFilePath,
+		),
+	)
+}
+
+// PlanSaved prints the location of the plan file for a dry run.
+func PlanSaved(planFilePath string) {
+	pterm.Fprintln(Stderr,
+		pterm.Success.Sprintf(
+			"Plan saved at: %s. Commit it with --apply-plan",
+			planFilePath,
+		),
+	)
+}
+
 // NoMatches prints out a message indicating that the find string failed
 // to match any files.
 func NoMatches(
@@ -225,10 +601,19 @@ func NoMatches(
 ) {
 	msg := "Failed to match any files"
 
+	if jsonOpts.Stream {
+		err := internaljson.WriteNoMatchesStream(Stdout, jsonOpts)
+		if err != nil {
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
+		}
+
+		return
+	}
+
 	if jsonOpts.Print {
-		b, err := internaljson.GetOutput(jsonOpts, nil, nil)
+		b, err := internaljson.GetNoMatchesOutput(jsonOpts)
 		if err != nil {
-			pterm.Fprintln(Stderr, err)
+			pterm.Fprintln(Stderr, pterm.Error.Sprint(err))
 			return
 		}
 
@@ -237,7 +622,7 @@ func NoMatches(
 		return
 	}
 
-	pterm.Info.Println(msg)
+	pterm.Fprintln(Stderr, pterm.Info.Sprint(msg))
 }
 
 // Dry prints a report of the renaming changes to be made.
@@ -252,7 +637,7 @@ func Dry(
 
 	Changes(changes, nil, quiet, jsonOpts)
 
-	if !jsonOpts.Print {
+	if !jsonOpts.Print && !jsonOpts.HTML {
 		pterm.Info.Prefix = pterm.Prefix{
 			Text:  "DRY RUN",
 			Style: pterm.NewStyle(pterm.BgBlue, pterm.FgBlack),
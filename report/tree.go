@@ -0,0 +1,114 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"github.com/ayoisaiah/f2/internal/file"
+)
+
+// treeDir is a directory in the tree view of the changes.
+type treeDir struct {
+	dirs   map[string]*treeDir
+	leaves []string
+}
+
+func newTreeDir() *treeDir {
+	return &treeDir{
+		dirs: make(map[string]*treeDir),
+	}
+}
+
+// dir returns the subdirectory with the specified name
+// and creates it if it does not exist yet.
+func (d *treeDir) dir(name string) *treeDir {
+	sub, exists := d.dirs[name]
+	if !exists {
+		sub = newTreeDir()
+		d.dirs[name] = sub
+	}
+
+	return sub
+}
+
+// render writes the leaves of the directory followed by its subdirectories
+// (in alphabetical order) with each entry indented under its parent.
+func (d *treeDir) render(writer io.Writer, prefix string) {
+	names := make([]string, 0, len(d.dirs))
+	for name := range d.dirs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	total := len(d.leaves) + len(names)
+
+	branch := func(i int) (connector, indent string) {
+		if i == total-1 {
+			return "└── ", "    "
+		}
+
+		return "├── ", "│   "
+	}
+
+	for i, leaf := range d.leaves {
+		connector, _ := branch(i)
+		fmt.Fprintln(writer, prefix+pterm.Gray(connector)+leaf)
+	}
+
+	for i, name := range names {
+		connector, indent := branch(len(d.leaves) + i)
+		fmt.Fprintln(writer, prefix+pterm.Gray(connector)+pterm.Bold.Sprint(name))
+		d.dirs[name].render(writer, prefix+pterm.Gray(indent))
+	}
+}
+
+// printTree displays the changes grouped under their directories relative
+// to the working directory. The status of each change is taken from the
+// corresponding row of the table data.
+func printTree(
+	changes []*file.Change,
+	data [][]string,
+	workingDir string,
+	writer io.Writer,
+) {
+	root := newTreeDir()
+
+	for i, change := range changes {
+		dir := filepath.Clean(change.BaseDir)
+
+		if filepath.IsAbs(dir) {
+			rel, err := filepath.Rel(workingDir, dir)
+			if err == nil && !strings.HasPrefix(rel, "..") {
+				dir = rel
+			}
+		}
+
+		node := root
+
+		// Directories outside the working directory
+		// are displayed as a single entry
+		if filepath.IsAbs(dir) {
+			node = node.dir(dir)
+		} else if dir != "." {
+			for _, name := range strings.Split(filepath.ToSlash(dir), "/") {
+				node = node.dir(name)
+			}
+		}
+
+		leaf := change.Source + " → " + change.Target
+		if changeStatus := data[i][2]; changeStatus != "" {
+			leaf += " " + changeStatus
+		}
+
+		node.leaves = append(node.leaves, leaf)
+	}
+
+	fmt.Fprintln(writer, pterm.Bold.Sprint(workingDir))
+	root.render(writer, "")
+}
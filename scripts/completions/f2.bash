@@ -4,27 +4,99 @@ f2_opts="
   --find
   --replace
   --undo
+  --allow-move
   --allow-overwrites
+  --apply-plan
+  --backup-fallback
+  --broken-symlinks
+  --config
+  --count
+  --counter-file
+  --default-stem
+  --depth
+  --dir-mode
+  --dirs-first
+  --dirs-last
+  --edit
+  --empty
+  --empty-name-fallback
   --exclude
   --exec
+  --exec-after
+  --exec-after-fail-fast
+  --explain
+  --ext
+  --fail-fast
+  --find-dir
+  --find-duplicate-names
+  --find-includes-ext
   --fix-conflicts
+  --force
+  --group
+  --hash-in
+  --hash-not-in
   --help
   --hidden
   --include-dir
   --ignore-case
   --ignore-ext
+  --index-per-root
   --json
+  --json-stream
+  --long-paths
+  --manifest
+  --map-file
+  --match-symlinks-only
   --max-depth
+  --min-depth
   --no-color
+  --no-fix-chars
+  --no-fix-exists
+  --no-fix-length
+  --no-fix-period
+  --non-empty
+  --normalize-separators-to
   --only-dir
+  --output-format
+  --order-file
+  --overwrite-if
+  --owner
+  --pipeline
+  --preserve-subdir-structure
+  --print-config
+  --protect-prefix
+  --protect-suffix
   --quiet
+  --rate
   --recursive
+  --regex-engine
+  --replace-dir
+  --replace-if
   --replace-limit
+  --respect-gitignore
+  --restore-times
+  --safe
+  --save-plan
+  --set-mtime-from
+  --show-index
+  --since-last-run
+  --skip-conforming
   --sort
   --sortr
+  --start-step
+  --stat-max-bytes
+  --stdin-names
   --string-mode
+  --trim-to
+  --unaccent
+  --validate-with
+  --undo-dry-run
+  --undo-file
   --verbose
   --version
+  --with-xattrs
+  --workers
+  --yes
 "
 __f2_completions()
 {
@@ -5,15 +5,56 @@ complete --command f2 --long-option find --short-option f --description "Search
 complete --command f2 --long-option replace --short-option r --description "Replacement pattern for matches" --exclusive
 
 complete --command f2 --long-option undo --short-option u --description "Undo the last renaming operation in current directory" --no-files
+complete --command f2 --long-option undo-dry-run --description "Preview the undo of the last renaming operation" --no-files
+complete --command f2 --long-option undo-file --description "Undo the renaming operation in a backup file" --require-parameter --force-files
 
+complete --command f2 --long-option allow-move --description "Allow moving paths with --preserve-subdir-structure" --no-files
 complete --command f2 --long-option allow-overwrites --description "Allow overwriting existing files" --no-files
+complete --command f2 --long-option apply-plan --description "Commit the renaming operation in a plan file" --require-parameter --force-files
+
+complete --command f2 --long-option backup-fallback --description "Write the backup file to the temporary directory if needed" --no-files
+complete --command f2 --long-option broken-symlinks --description "Only match broken symbolic links" --no-files
+
+complete --command f2 --long-option config --description "Load the extension rules from a config file" --require-parameter --force-files
+complete --command f2 --long-option count --description "Print the number of matches and exit" --no-files
+complete --command f2 --long-option counter-file --description "Continue the numbering from a counter file" --require-parameter --force-files
+
+complete --command f2 --long-option default-stem --description "Match only the stem when no find pattern is provided" --no-files
+complete --command f2 --long-option depth --description "Only match entries at the specified depth" --no-files
+complete --command f2 --long-option dir-mode --description "Permissions of the created directories in octal" --no-files
+complete --command f2 --long-option dirs-first --description "Place directories before files" --no-files
+complete --command f2 --long-option dirs-last --description "Place directories after files" --no-files
+
+complete --command f2 --long-option edit --description "Edit the new names in a text editor" --no-files
+complete --command f2 --long-option empty --description "Only match empty files and directories" --no-files
+
+complete --command f2 --long-option empty-name-fallback --description "Fallback name for empty file names" --exclusive
 
 complete --command f2 --long-option exclude --short-option E --description "Exclude files and directories matching pattern" --no-files
 
 complete --command f2 --long-option exec --short-option x --description "Execute renaming operation" --no-files
 
+complete --command f2 --long-option exec-after --description "Run a command for each renamed path" --no-files
+
+complete --command f2 --long-option exec-after-fail-fast --description "Stop at the first failed --exec-after command" --no-files
+
+complete --command f2 --long-option explain --description "Print the reason that each path was not matched" --no-files
+
+complete --command f2 --long-option ext --description "Only match files with the specified extension" --exclusive
+
+complete --command f2 --long-option fail-fast --description "Stop at the first renaming error" --no-files
+
+complete --command f2 --long-option find-dir --description "Search pattern for directories" --no-files
+complete --command f2 --long-option find-duplicate-names --description "Print matches that share the same name and exit" --no-files
+complete --command f2 --long-option find-includes-ext --description "Match the find pattern against the file extension" --no-files
+
 complete --command f2 --long-option fix-conflicts --short-option F --description "Auto fix renaming conflicts" --no-files
 
+complete --command f2 --long-option force --description "Operate on protected system locations" --no-files
+complete --command f2 --long-option group --description "Match only paths that belong to the group" --no-files
+complete --command f2 --long-option hash-in --description "Match only files whose hash is in the list" --require-parameter --force-files
+complete --command f2 --long-option hash-not-in --description "Match only files whose hash is not in the list" --require-parameter --force-files
+
 complete --command f2 --long-option help --short-option h --description "Display help and exit" --no-files
 
 complete --command f2 --long-option hidden --short-option H --description "Match hidden files" --no-files
@@ -24,36 +65,99 @@ complete --command f2 --long-option ignore-case --short-option i --description "
 
 complete --command f2 --long-option ignore-ext --short-option e --description "Ignore file extension" --no-files
 
+complete --command f2 --long-option index-per-root --description "Number the matches separately for each path argument" --no-files
+
 complete --command f2 --long-option json --description "Enable json output" --no-files
+complete --command f2 --long-option json-stream --description "Enable json output with one change per line" --no-files
+
+complete --command f2 --long-option long-paths --description "Allow targets longer than 260 characters in Windows" --no-files
+
+complete --command f2 --long-option manifest --description "Append renamed files to a manifest" --require-parameter --force-files
+
+complete --command f2 --long-option map-file --description "Rename files according to exact mappings" --require-parameter --force-files
+
+complete --command f2 --long-option match-symlinks-only --description "Only match symbolic links" --no-files
 
 complete --command f2 --long-option max-depth --short-option m --description "Specify max depth for recursive search" --no-files
+complete --command f2 --long-option min-depth --description "Specify min depth for recursive search" --no-files
 
 complete --command f2 --long-option no-color --description "Disable coloured output" --no-files
 
+complete --command f2 --long-option no-fix-chars --description "Do not auto fix forbidden characters" --no-files
+complete --command f2 --long-option no-fix-exists --description "Do not auto fix existing paths" --no-files
+complete --command f2 --long-option no-fix-length --description "Do not auto fix long file names" --no-files
+complete --command f2 --long-option no-fix-period --description "Do not auto fix trailing periods" --no-files
+complete --command f2 --long-option non-empty --description "Only match files and directories that are not empty" --no-files
+complete --command f2 --long-option normalize-separators-to --description "Use a single separator character in the names" --no-files
+
 complete --command f2 --long-option only-dir --short-option D --description "Rename only directories" --no-files
 
+complete --command f2 --long-option output-format --description "Format of the report" --exclusive --arguments 'table tree json html'
+complete --command f2 --long-option order-file --description "Order the matches according to a file" --require-parameter --force-files
+
+complete --command f2 --long-option overwrite-if --description "Determine when existing paths may be overwritten" --exclusive --arguments 'always newer larger'
+complete --command f2 --long-option owner --description "Match only paths that are owned by the user" --no-files
+complete --command f2 --long-option pipeline --description "Rename the matches with a pipeline in the config file" --no-files
+
+complete --command f2 --long-option preserve-subdir-structure --description "Keep renamed paths in their original directory" --no-files
+complete --command f2 --long-option print-config --description "Print the effective options and exit" --no-files
+complete --command f2 --long-option protect-prefix --description "Keep the specified prefix untouched" --no-files
+complete --command f2 --long-option protect-suffix --description "Keep the specified suffix untouched" --no-files
 complete --command f2 --long-option quiet --short-option q --description "Disable all output except errors" --no-files
+complete --command f2 --long-option rate --description "Maximum number of filesystem operations per second" --no-files
 
 complete --command f2 --long-option recursive --short-option R --description "Search for matches in subdirectories" --no-files
 
+complete --command f2 --long-option regex-engine --description "Regular expression engine for find patterns" --exclusive --arguments 're2 pcre'
+
+complete --command f2 --long-option replace-dir --description "Replacement string for directories" --no-files
+
+complete --command f2 --long-option replace-if --description "Only replace files whose names match the pattern" --no-files
 complete --command f2 --long-option replace-limit --short-option l --description "Limit the matches to be replaced" --no-files
 
+complete --command f2 --long-option respect-gitignore --description "Skip the paths ignored by git" --no-files
+
+complete --command f2 --long-option restore-times --description "Restore the original modification times on undo" --no-files
+
 set -l sort_args "
   default\t'Alphabetical order'
   size\t'Sort by file size'
+  extension\t'Sort by file extension'
   mtime\t'Sort by file last modified time'
   btime\t'Sort by file creation time'
   atime\t'Sort by file last access time'
   ctime\t'Sort by file metadata last change time'
 "
 
+complete --command f2 --long-option safe --description "Refuse to commit the renaming operation" --no-files
+complete --command f2 --long-option save-plan --description "Save the changes of a dry run to a plan file" --no-files
+complete --command f2 --long-option set-mtime-from --description "Set the modification time from the date in the new name" --no-files
+complete --command f2 --long-option show-index --description "Include the index of each change in the output" --no-files
+complete --command f2 --long-option since-last-run --description "Only match files modified since the last renaming operation" --no-files
+complete --command f2 --long-option skip-conforming --description "Skip files whose names already match the pattern" --no-files
+
 complete --command f2 --long-option sort --description "Sort matches in ascending order" --exclusive --keep-order --arguments $sort_args
 
 complete --command f2 --long-option sortr --description "Sort matches in descending order" --exclusive --keep-order --arguments $sort_args
+complete --command f2 --long-option start-step --description "Start number and step of the indexing variables" --no-files
+complete --command f2 --long-option stat-max-bytes --description "Maximum size of files whose lines and words are counted" --no-files
+
+complete --command f2 --long-option stdin-names --description "Read new names from the standard input" --no-files
 
 complete --command f2 --long-option string-mode --short-option s --description "Treat the search pattern as a non-regex string" --no-files
 
+complete --command f2 --long-option trim-to --description "Shorten the new names at a word boundary" --no-files
+
+complete --command f2 --long-option unaccent --description "Remove diacritics from the new names" --no-files
+
+complete --command f2 --long-option validate-with --description "Only keep the changes approved by a command" --no-files
+
 complete --command f2 --long-option verbose --short-option V --description "Enable verbose output" --no-files
 
 complete --command f2 --long-option version --short-option v --description "Display version and exit" --no-files
 
+complete --command f2 --long-option with-xattrs --description "Rename AppleDouble files along with their files" --no-files
+
+complete --command f2 --long-option workers --description "Number of files whose metadata is read concurrently" --no-files
+
+complete --command f2 --long-option yes --short-option y --description "Skip the confirmation for overwriting existing paths" --no-files
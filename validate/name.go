@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ayoisaiah/f2/internal/conflict"
+	internalos "github.com/ayoisaiah/f2/internal/os"
+)
+
+// windowsReservedNames contains the device names that cannot be used as file
+// names in Windows regardless of the extension.
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// ValidationIssue describes why a name cannot be used as the target of a
+// renaming operation. Cause provides further details where applicable
+// (such as the offending characters).
+type ValidationIssue struct {
+	Conflict conflict.Name `json:"conflict"`
+	Cause    string        `json:"cause"`
+}
+
+// isReservedName reports whether the name refers to a device in Windows.
+func isReservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	stem = strings.TrimRight(stem, " ")
+
+	for _, reserved := range windowsReservedNames {
+		if strings.EqualFold(stem, reserved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateName checks whether the name is a valid target on TargetOS
+// without renaming anything. See ValidateNameForOS.
+func ValidateName(name string) []ValidationIssue {
+	return ValidateNameForOS(name, TargetOS)
+}
+
+// ValidateNameForOS checks whether the name is a valid target according to
+// the naming rules of the specified operating system (as reported by
+// runtime.GOOS). Like the targets of a renaming operation, the name may
+// contain path separators to indicate directories. The issues are returned
+// in a consistent order and an empty slice means that the name is valid.
+func ValidateNameForOS(name, goos string) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	separators := "/"
+	if goos == internalos.Windows {
+		separators = `/\`
+	}
+
+	pathComponents := strings.FieldsFunc(name, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+
+	if len(pathComponents) == 0 {
+		return append(issues, ValidationIssue{Conflict: conflict.EmptyFilename})
+	}
+
+	if chars := checkForbiddenCharacters(name, goos); chars != "" {
+		issues = append(issues, ValidationIssue{
+			Conflict: conflict.InvalidCharacters,
+			Cause:    chars,
+		})
+	}
+
+	filename := pathComponents[len(pathComponents)-1]
+
+	if isTargetLengthExceeded(filename, goos) {
+		cause := fmt.Sprintf("%d bytes", unixMaxBytes)
+		if goos == internalos.Windows {
+			cause = fmt.Sprintf("%d characters", windowsMaxFileCharLength)
+		}
+
+		issues = append(issues, ValidationIssue{
+			Conflict: conflict.MaxFilenameLengthExceeded,
+			Cause:    cause,
+		})
+	}
+
+	if goos != internalos.Windows {
+		return issues
+	}
+
+	if hasTrailingPeriod(pathComponents) {
+		issues = append(issues, ValidationIssue{
+			Conflict: conflict.TrailingPeriod,
+		})
+	}
+
+	for _, v := range pathComponents {
+		if isReservedName(v) {
+			issues = append(issues, ValidationIssue{
+				Conflict: conflict.ReservedName,
+				Cause:    v,
+			})
+		}
+	}
+
+	return issues
+}
@@ -5,13 +5,22 @@
 // 1. Overwriting a newly renamed path.
 // 2. Target destination contains forbidden characters (varies based on the operating system).
 // 3. Target destination already exists on the file system (except if
-// --allow-overwrite is specified)
+// --allow-overwrite is specified and the --overwrite-if policy is satisfied)
 // 4. Target name exceeds the maximum allowed length (255 characters in windows, and 255 bytes on Linux and macOS).
 // 5. Target destination contains trailing periods in any of the sub paths (Windows only).
 // 6. Target destination is empty.
+// 7. Source is the current working directory or one of its parents.
+// 8. Target destination exists as a directory when the source is a file (or
+// vice versa).
+// 9. Target destination is the source of another path that is renamed later
+// in the operation (such as when a chain of replacements swaps names).
+// 10. Full target path exceeds the maximum allowed length (260 characters in
+// Windows unless --long-paths is specified, and 4096 bytes on Linux and macOS).
 //
 // It detects each conflicts and reports them, but it can also automatically fix
 // them according to predefined rules (if -F/--fix-conflicts is specified).
+// A single name can also be checked against the naming rules of an OS
+// through ValidateName without performing a renaming operation.
 package validate
 
 import (
@@ -23,7 +32,9 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"unicode/utf16"
 
+	"github.com/ayoisaiah/f2/internal/config"
 	"github.com/ayoisaiah/f2/internal/conflict"
 	"github.com/ayoisaiah/f2/internal/file"
 	internalos "github.com/ayoisaiah/f2/internal/os"
@@ -31,6 +42,11 @@ import (
 	"github.com/ayoisaiah/f2/internal/status"
 )
 
+// TargetOS is the operating system whose naming rules are applied to the
+// targets (as reported by runtime.GOOS). It can be changed so that the rules
+// of every supported OS can be exercised on any platform.
+var TargetOS = runtime.GOOS
+
 var conflicts conflict.Collection
 
 var changes []*file.Change
@@ -40,6 +56,30 @@ const (
 	windowsMaxFileCharLength = 255
 	// max filename length of 255 bytes on Linux and other unix-based OSes.
 	unixMaxBytes = 255
+	// max path length of 260 characters in Windows (MAX_PATH) including the
+	// terminating null character.
+	windowsMaxPathLength = 260
+	// max directory path length of 248 characters in Windows since room
+	// must be left for an 8.3 file name.
+	windowsMaxDirLength = 248
+	// max length of 32767 characters for extended-length paths in Windows.
+	windowsMaxExtendedPathLength = 32767
+	// max path length of 4096 bytes on Linux (PATH_MAX) including the
+	// terminating null character.
+	unixMaxPathBytes = 4096
+)
+
+// Reasons reported for automatically fixed conflicts.
+const (
+	fixEmptyFilename      = "empty file name: left unchanged"
+	fixWorkingDirRename   = "renaming the working directory or its parents: left unchanged"
+	fixTrailingPeriod     = "removed trailing periods"
+	fixFilenameLength     = "trimmed file name to %s"
+	fixForbiddenChars     = "removed forbidden characters: (%s)"
+	fixPathExists         = "appended a number since the path already exists"
+	fixOverwritingNewPath = "appended a number to avoid overwriting a newly renamed path"
+	fixTypeMismatch       = "appended a number since the path already exists as a %s"
+	fixOverwritingSource  = "appended a number to avoid overwriting a path before it is renamed"
 )
 
 // renamedPathsType is used to detect overwriting file paths
@@ -53,6 +93,15 @@ type renamedPathsType map[string][]struct {
 	index      int // helps keep track of source position in the changes slice
 }
 
+// recordFix keeps track of an automatic conflict resolution so
+// that it can be reported.
+func recordFix(change *file.Change, reason string) {
+	change.Fixes = append(change.Fixes, file.Fix{
+		Target: change.Target,
+		Reason: reason,
+	})
+}
+
 // newTarget appends a number to the target file name so that it
 // does not conflict with an existing path on the filesystem or
 // another renamed file. For example: image.png becomes image (2).png.
@@ -100,18 +149,42 @@ func newTarget(change *file.Change, renamedPaths map[string][]struct {
 
 // checkEmptyFilenameConflict reports if the file renaming has resulted
 // in an empty string. This conflict is automatically fixed by leaving
-// the filename unchanged.
+// the filename unchanged. If a fallback name is provided, it is used as the
+// target instead and numbered if necessary so that it remains unique. The
+// original extension is appended to the fallback name if keepExt is set.
 func checkEmptyFilenameConflict(
 	change *file.Change,
-	autoFix bool,
+	renamedPaths renamedPathsType,
+	emptyNameFallback string,
+	keepExt, autoFix bool,
 ) (conflictDetected bool) {
 	sourcePath := filepath.Join(change.BaseDir, change.Source)
 	targetPath := filepath.Join(change.BaseDir, change.Target)
 
 	if change.Target == "." || change.Target == "" {
+		if emptyNameFallback != "" {
+			change.Target = emptyNameFallback
+			if keepExt {
+				change.Target += filepath.Ext(change.Source)
+			}
+
+			change.Status = status.OK
+
+			targetPath = filepath.Join(change.BaseDir, change.Target)
+
+			_, err := os.Stat(targetPath)
+			if _, ok := renamedPaths[targetPath]; ok || err == nil {
+				change.Target = newTarget(change, renamedPaths)
+			}
+
+			return
+		}
+
 		conflictDetected = true
 
 		if autoFix {
+			recordFix(change, fixEmptyFilename)
+
 			// The file is left unchanged
 			change.Target = change.Source
 			change.Status = status.Unchanged
@@ -132,10 +205,175 @@ func checkEmptyFilenameConflict(
 	return
 }
 
+// checkWorkingDirConflict reports if the source of a directory renaming
+// operation is the current working directory or one of its ancestors since
+// renaming such directories would pull the rug from under the operation.
+// This conflict is automatically fixed by leaving the directory unchanged.
+func checkWorkingDirConflict(
+	change *file.Change,
+	workingDir string,
+	autoFix bool,
+) (conflictDetected bool) {
+	if !change.IsDir || change.Source == change.Target {
+		return
+	}
+
+	sourcePath := filepath.Join(change.BaseDir, change.Source)
+	targetPath := filepath.Join(change.BaseDir, change.Target)
+
+	absSourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return
+	}
+
+	// Symbolic links are resolved on both sides so that the working
+	// directory cannot be reached through a different path than the
+	// source. Only the parent of the source is resolved since renaming a
+	// link does not affect the directory that it points to
+	if resolved, err := filepath.EvalSymlinks(
+		filepath.Dir(absSourcePath),
+	); err == nil {
+		absSourcePath = filepath.Join(resolved, filepath.Base(absSourcePath))
+	}
+
+	if resolved, err := filepath.EvalSymlinks(workingDir); err == nil {
+		workingDir = resolved
+	}
+
+	rel, err := filepath.Rel(absSourcePath, workingDir)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+
+	conflictDetected = true
+
+	if autoFix {
+		recordFix(change, fixWorkingDirRename)
+
+		change.Target = change.Source
+		change.Status = status.Unchanged
+
+		return
+	}
+
+	conflicts[conflict.WorkingDirRename] = append(
+		conflicts[conflict.WorkingDirRename],
+		conflict.Conflict{
+			Sources: []string{sourcePath},
+			Target:  targetPath,
+		},
+	)
+	change.Status = status.WorkingDirRename
+
+	return
+}
+
+// isRenamedBefore reports whether the path is the source of another
+// change that will be renamed before the provided change.
+func isRenamedBefore(change *file.Change, path string) bool {
+	for j := 0; j < len(changes); j++ {
+		ch := changes[j]
+		sp := filepath.Join(ch.BaseDir, ch.Source)
+		tp := filepath.Join(ch.BaseDir, ch.Target)
+
+		if path == sp && !strings.EqualFold(sp, tp) &&
+			change.Index > j {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkTypeMismatchConflict reports if the target of a file is an existing
+// directory or the target of a directory is an existing file. Renaming in
+// such cases behaves differently on each platform so it is reported
+// regardless of whether overwrites are allowed. This conflict is
+// automatically fixed by appending a number to the target.
+func checkTypeMismatchConflict(
+	change *file.Change,
+	autoFix bool,
+) (conflictDetected bool) {
+	sourcePath := filepath.Join(change.BaseDir, change.Source)
+	targetPath := filepath.Join(change.BaseDir, change.Target)
+
+	if strings.EqualFold(sourcePath, targetPath) {
+		return
+	}
+
+	fileInfo, err := os.Stat(targetPath)
+	if err != nil || fileInfo.IsDir() == change.IsDir {
+		return
+	}
+
+	if isRenamedBefore(change, targetPath) {
+		return
+	}
+
+	cause := "directory"
+	if !fileInfo.IsDir() {
+		cause = "file"
+	}
+
+	conflictDetected = true
+
+	if autoFix {
+		recordFix(change, fmt.Sprintf(fixTypeMismatch, cause))
+
+		change.Target = newTarget(change, nil)
+		change.Status = status.OK
+
+		return
+	}
+
+	conflicts[conflict.TypeMismatch] = append(
+		conflicts[conflict.TypeMismatch],
+		conflict.Conflict{
+			Sources: []string{sourcePath},
+			Target:  targetPath,
+			Cause:   cause,
+		},
+	)
+	change.Status = status.TypeMismatch
+
+	return
+}
+
+// canOverwrite reports whether the source path satisfies the policy for
+// overwriting the target path.
+func canOverwrite(sourcePath, targetPath, overwriteIf string) bool {
+	if overwriteIf == "" || overwriteIf == config.OverwriteAlways {
+		return true
+	}
+
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return false
+	}
+
+	targetInfo, err := os.Stat(targetPath)
+	if err != nil {
+		return false
+	}
+
+	switch overwriteIf {
+	case config.OverwriteNewer:
+		return sourceInfo.ModTime().After(targetInfo.ModTime())
+	case config.OverwriteLarger:
+		return sourceInfo.Size() > targetInfo.Size()
+	}
+
+	return false
+}
+
 // checkPathExistsConflict reports if the newly renamed path
-// already exists on the filesystem.
+// already exists on the filesystem. If overwrites are allowed, the existing
+// path is only overwritten if the overwrite policy is satisfied, otherwise
+// the change is left unchanged.
 func checkPathExistsConflict(
 	change *file.Change,
+	overwriteIf string,
 	autoFix, allowOverwrites bool,
 ) (conflictDetected bool) {
 	sourcePath := filepath.Join(change.BaseDir, change.Source)
@@ -158,6 +396,13 @@ func checkPathExistsConflict(
 
 		// Don't report a conflict if overwriting files are allowed
 		if allowOverwrites {
+			if !canOverwrite(sourcePath, targetPath, overwriteIf) {
+				change.Target = change.Source
+				change.Status = status.Unchanged
+
+				return
+			}
+
 			change.WillOverwrite = true
 			change.Status = status.Overwriting
 
@@ -166,18 +411,13 @@ func checkPathExistsConflict(
 
 		// Don't report a conflict if target path is changing before
 		// the source path is renamed
-		for j := 0; j < len(changes); j++ {
-			ch := changes[j]
-			sp := filepath.Join(ch.BaseDir, ch.Source)
-			tp := filepath.Join(ch.BaseDir, ch.Target)
-
-			if targetPath == sp && !strings.EqualFold(sp, tp) &&
-				change.Index > j {
-				return
-			}
+		if isRenamedBefore(change, targetPath) {
+			return
 		}
 
 		if autoFix {
+			recordFix(change, fixPathExists)
+
 			change.Target = newTarget(change, nil)
 			change.Status = status.OK
 
@@ -209,6 +449,8 @@ func checkOverwritingPathConflict(
 	// Report duplicate targets if any
 	for targetPath, source := range renamedPaths {
 		if len(source) > 1 {
+			var retry bool
+
 			var sources []string
 			for _, s := range source {
 				sources = append(sources, s.sourcePath)
@@ -224,6 +466,12 @@ func checkOverwritingPathConflict(
 						continue
 					}
 
+					if !retry {
+						recordFix(changes[item.index], fixOverwritingNewPath)
+					}
+
+					retry = false
+
 					target := newTarget(
 						changes[item.index],
 						renamedPaths,
@@ -241,6 +489,7 @@ func checkOverwritingPathConflict(
 						// repeat the last iteration to generate a new path
 						changes[item.index].Target = target
 						changes[item.index].Status = status.OK
+						retry = true
 						i--
 						continue
 					}
@@ -260,10 +509,74 @@ func checkOverwritingPathConflict(
 	}
 }
 
+// checkOverwritingSourceConflict ensures that no target is the source of
+// another change that is renamed later in the operation. Since the changes
+// are renamed in order, such a path would be overwritten before it is moved
+// to its own target. This is only possible when overwrites are allowed as
+// the path otherwise already exists. Such conflicts are solved by appending
+// a number to the target.
+func checkOverwritingSourceConflict(
+	renamedPaths renamedPathsType,
+	autoFix bool,
+) {
+	sources := make(map[string]int, len(changes))
+
+	for i, change := range changes {
+		sourcePath := filepath.Join(change.BaseDir, change.Source)
+		targetPath := filepath.Join(change.BaseDir, change.Target)
+
+		if !strings.EqualFold(sourcePath, targetPath) {
+			sources[sourcePath] = i
+		}
+	}
+
+	for i, change := range changes {
+		if change.Status != status.Overwriting {
+			continue
+		}
+
+		sourcePath := filepath.Join(change.BaseDir, change.Source)
+		targetPath := filepath.Join(change.BaseDir, change.Target)
+
+		j, ok := sources[targetPath]
+		if !ok || j <= i {
+			continue
+		}
+
+		if autoFix {
+			recordFix(change, fixOverwritingSource)
+
+			change.Target = newTarget(change, renamedPaths)
+			change.WillOverwrite = false
+			change.Status = status.OK
+
+			newPath := filepath.Join(change.BaseDir, change.Target)
+			renamedPaths[newPath] = append(renamedPaths[newPath], struct {
+				sourcePath string
+				index      int
+			}{
+				sourcePath: sourcePath,
+				index:      i,
+			})
+
+			continue
+		}
+
+		conflicts[conflict.OverwritingSource] = append(
+			conflicts[conflict.OverwritingSource],
+			conflict.Conflict{
+				Sources: []string{sourcePath},
+				Target:  targetPath,
+			},
+		)
+		change.Status = status.OverwritingSource
+	}
+}
+
 // checkForbiddenCharacters is responsible for ensuring that target file names
-// do not contain forbidden characters for the current OS.
-func checkForbiddenCharacters(path string) string {
-	if runtime.GOOS == internalos.Windows {
+// do not contain forbidden characters for the specified OS.
+func checkForbiddenCharacters(path, goos string) string {
+	if goos == internalos.Windows {
 		// partialWindowsForbiddenCharRegex is used here as forward and backward
 		// slashes are used for auto creating directories
 		if internalos.PartialWindowsForbiddenCharRegex.MatchString(path) {
@@ -277,7 +590,7 @@ func checkForbiddenCharacters(path string) string {
 		}
 	}
 
-	if runtime.GOOS == internalos.Darwin {
+	if goos == internalos.Darwin {
 		if strings.Contains(path, ":") {
 			return ":"
 		}
@@ -287,18 +600,18 @@ func checkForbiddenCharacters(path string) string {
 }
 
 // isTargetLengthExceeded is responsible for ensuring that the target name length
-// does not exceed the maximum value on each supported rating system.
-func isTargetLengthExceeded(target string) bool {
+// does not exceed the maximum value on the specified operating system.
+func isTargetLengthExceeded(target, goos string) bool {
 	// Get the standalone filename
 	filename := filepath.Base(target)
 
 	// max length of 255 characters in windows
-	if runtime.GOOS == internalos.Windows &&
+	if goos == internalos.Windows &&
 		len([]rune(filename)) > windowsMaxFileCharLength {
 		return true
 	}
 
-	if runtime.GOOS != internalos.Windows &&
+	if goos != internalos.Windows &&
 		len([]byte(filename)) > unixMaxBytes {
 		// max length of 255 bytes on Linux and other unix-based OSes
 		return true
@@ -307,28 +620,37 @@ func isTargetLengthExceeded(target string) bool {
 	return false
 }
 
+// hasTrailingPeriod reports whether any of the path components
+// ends in a period.
+func hasTrailingPeriod(pathComponents []string) bool {
+	for _, v := range pathComponents {
+		if v != strings.TrimRight(v, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
 // checkTrailingPeriods reports if the file renaming has resulted in
 // files or sub directories that end in trailing dots (Windows only).
 // This conflict is automatically resolved by removing the trailing periods.
 func checkTrailingPeriodConflict(
 	change *file.Change,
+	goos string,
 	autoFix bool,
 ) (conflictDetected bool) {
 	sourcePath := filepath.Join(change.BaseDir, change.Source)
 	targetPath := filepath.Join(change.BaseDir, change.Target)
 
-	if runtime.GOOS == internalos.Windows {
+	if goos == internalos.Windows {
 		pathComponents := strings.Split(change.Target, internalpath.Separator)
 
-		for _, v := range pathComponents {
-			if v != strings.TrimRight(v, ".") {
-				conflictDetected = true
-
-				break
-			}
-		}
+		conflictDetected = hasTrailingPeriod(pathComponents)
 
 		if autoFix && conflictDetected {
+			recordFix(change, fixTrailingPeriod)
+
 			for j, v := range pathComponents {
 				s := strings.TrimRight(v, ".")
 				pathComponents[j] = s
@@ -363,15 +685,23 @@ func checkTrailingPeriodConflict(
 // excess characters/bytes until the name is under the limit.
 func checkFileNameLengthConflict(
 	change *file.Change,
+	goos string,
 	autoFix bool,
 ) (conflictDetected bool) {
 	sourcePath := filepath.Join(change.BaseDir, change.Source)
 	targetPath := filepath.Join(change.BaseDir, change.Target)
 
-	exceeded := isTargetLengthExceeded(change.Target)
+	exceeded := isTargetLengthExceeded(change.Target, goos)
 	if exceeded {
+		cause := "255 bytes"
+		if goos == internalos.Windows {
+			cause = "255 characters"
+		}
+
 		if autoFix {
-			if runtime.GOOS == internalos.Windows {
+			recordFix(change, fmt.Sprintf(fixFilenameLength, cause))
+
+			if goos == internalos.Windows {
 				// trim filename so that it's less than 255 characters
 				filename := []rune(filepath.Base(change.Target))
 				ext := []rune(filepath.Ext(string(filename)))
@@ -404,11 +734,6 @@ func checkFileNameLengthConflict(
 			return
 		}
 
-		cause := "255 bytes"
-		if runtime.GOOS == internalos.Windows {
-			cause = "255 characters"
-		}
-
 		conflicts[conflict.MaxFilenameLengthExceeded] = append(
 			conflicts[conflict.MaxFilenameLengthExceeded],
 			conflict.Conflict{
@@ -424,6 +749,85 @@ func checkFileNameLengthConflict(
 	return
 }
 
+// pathLengthExceeded returns the limit that the absolute target path exceeds
+// or an empty string if it is within the limits of the OS. In Windows, the
+// limits are in UTF-16 code units and include the terminating null
+// character.
+func pathLengthExceeded(
+	targetPath, goos string,
+	isDir, longPaths bool,
+) string {
+	if goos != internalos.Windows {
+		if len(targetPath) >= unixMaxPathBytes {
+			return strconv.Itoa(unixMaxPathBytes) + " bytes"
+		}
+
+		return ""
+	}
+
+	length := func(path string) int {
+		return len(utf16.Encode([]rune(path)))
+	}
+
+	if longPaths {
+		if length(targetPath) >= windowsMaxExtendedPathLength {
+			return strconv.Itoa(windowsMaxExtendedPathLength) + " characters"
+		}
+
+		return ""
+	}
+
+	if length(targetPath) >= windowsMaxPathLength {
+		return strconv.Itoa(windowsMaxPathLength) + " characters"
+	}
+
+	dir := targetPath
+	if !isDir {
+		dir = filepath.Dir(targetPath)
+	}
+
+	if length(dir) >= windowsMaxDirLength {
+		return strconv.Itoa(windowsMaxDirLength) + " characters for directories"
+	}
+
+	return ""
+}
+
+// checkPathLengthConflict reports if the full target path is longer than
+// the acceptable limit of the OS so that the operation does not fail
+// halfway through creating the target directories. Unlike the file name
+// length, this conflict cannot be fixed automatically.
+func checkPathLengthConflict(
+	change *file.Change,
+	workingDir, goos string,
+	longPaths bool,
+) (conflictDetected bool) {
+	sourcePath := filepath.Join(change.BaseDir, change.Source)
+	targetPath := filepath.Join(change.BaseDir, change.Target)
+
+	absTargetPath := targetPath
+	if !filepath.IsAbs(absTargetPath) {
+		absTargetPath = filepath.Join(workingDir, targetPath)
+	}
+
+	cause := pathLengthExceeded(absTargetPath, goos, change.IsDir, longPaths)
+	if cause == "" {
+		return
+	}
+
+	conflicts[conflict.MaxPathLengthExceeded] = append(
+		conflicts[conflict.MaxPathLengthExceeded],
+		conflict.Conflict{
+			Sources: []string{sourcePath},
+			Target:  targetPath,
+			Cause:   cause,
+		},
+	)
+	change.Status = status.PathLengthExceeded
+
+	return true
+}
+
 // checkForbiddenCharactersConflict is used to detect if forbidden characters
 // are present in the target path for a file or directory according to the
 // naming rules of the respective OS. This detection excludes forward and
@@ -432,22 +836,24 @@ func checkFileNameLengthConflict(
 // Conflicts are automatically fixed by removing the culprit characters.
 func checkForbiddenCharactersConflict(
 	change *file.Change,
+	goos string,
 	autoFix bool,
 ) (conflictDetected bool) {
 	sourcePath := filepath.Join(change.BaseDir, change.Source)
 	targetPath := filepath.Join(change.BaseDir, change.Target)
 
-	forbiddenChars := checkForbiddenCharacters(change.Target)
+	forbiddenChars := checkForbiddenCharacters(change.Target, goos)
 	if forbiddenChars != "" {
 		if autoFix {
-			if runtime.GOOS == internalos.Windows {
+			recordFix(change, fmt.Sprintf(fixForbiddenChars, forbiddenChars))
+			if goos == internalos.Windows {
 				change.Target = internalos.PartialWindowsForbiddenCharRegex.ReplaceAllString(
 					change.Target,
 					"",
 				)
 			}
 
-			if runtime.GOOS == internalos.Darwin {
+			if goos == internalos.Darwin {
 				change.Target = strings.ReplaceAll(
 					change.Target,
 					":",
@@ -478,46 +884,112 @@ func checkForbiddenCharactersConflict(
 
 // detectConflicts checks the renamed files for various conflicts and
 // automatically fixes them if allowed.
-func detectConflicts(autoFix, allowOverwrites bool) {
+func detectConflicts(conf *config.Config) {
 	renamedPaths := make(renamedPathsType)
 
+	autoFix := conf.AutoFixConflicts
+
+	// fix reports whether the specified conflict
+	// should be fixed automatically
+	fix := func(name conflict.Name) bool {
+		return autoFix && !conf.NoFix[name]
+	}
+
 	for i := 0; i < len(changes); i++ {
 		change := changes[i]
 		sourcePath := filepath.Join(change.BaseDir, change.Source)
-		targetPath := filepath.Join(change.BaseDir, change.Target)
 
-		detected := checkEmptyFilenameConflict(change, autoFix)
+		// An AppleDouble file follows the target of its file (which is
+		// checked before it) unless its own target had to be fixed
+		if change.AppleDoubleOf != nil && len(change.Fixes) == 0 {
+			change.Target = file.AppleDoublePath(change.AppleDoubleOf.Target)
+		}
+
+		// The extension is kept under the same conditions as in the
+		// replacement so that the fallback name does not drop it
+		keepExt := !change.IsDir && (conf.StemOnly() || conf.IgnoreExt)
+
+		detected := checkEmptyFilenameConflict(
+			change,
+			renamedPaths,
+			conf.EmptyNameFallback,
+			keepExt,
+			autoFix,
+		)
 		if detected {
 			// no need to check for other conflicts here since the filename
 			// is empty. If auto fixed, no renaming will occur for the entry
 			continue
 		}
 
-		detected = checkTrailingPeriodConflict(change, autoFix)
-		if detected && autoFix {
+		detected = checkWorkingDirConflict(change, conf.WorkingDir, autoFix)
+		if detected {
+			continue
+		}
+
+		detected = checkTrailingPeriodConflict(
+			change,
+			TargetOS,
+			fix(conflict.TrailingPeriod),
+		)
+		if detected && fix(conflict.TrailingPeriod) {
 			// going back an index allows rechecking the path for conflicts once more
 			i--
 			continue
 		}
 
-		detected = checkFileNameLengthConflict(change, autoFix)
-		if detected && autoFix {
+		detected = checkFileNameLengthConflict(
+			change,
+			TargetOS,
+			fix(conflict.MaxFilenameLengthExceeded),
+		)
+		if detected && fix(conflict.MaxFilenameLengthExceeded) {
 			i--
 			continue
 		}
 
-		detected = checkForbiddenCharactersConflict(change, autoFix)
-		if detected && autoFix {
+		detected = checkPathLengthConflict(
+			change,
+			conf.WorkingDir,
+			TargetOS,
+			conf.LongPaths,
+		)
+		if detected {
+			continue
+		}
+
+		detected = checkForbiddenCharactersConflict(
+			change,
+			TargetOS,
+			fix(conflict.InvalidCharacters),
+		)
+		if detected && fix(conflict.InvalidCharacters) {
 			i--
 			continue
 		}
 
-		detected = checkPathExistsConflict(change, autoFix, allowOverwrites)
-		if detected && autoFix {
+		detected = checkTypeMismatchConflict(change, autoFix)
+		if detected {
+			if autoFix {
+				i--
+			}
+
+			continue
+		}
+
+		detected = checkPathExistsConflict(
+			change,
+			conf.OverwriteIf,
+			fix(conflict.FileExists),
+			conf.AllowOverwrites,
+		)
+		if detected && fix(conflict.FileExists) {
 			i--
 			continue
 		}
 
+		targetPath := filepath.Join(change.BaseDir, change.Target)
+
 		renamedPaths[targetPath] = append(renamedPaths[targetPath], struct {
 			sourcePath string
 			index      int
@@ -528,19 +1000,24 @@ func detectConflicts(autoFix, allowOverwrites bool) {
 	}
 
 	checkOverwritingPathConflict(renamedPaths, autoFix)
+
+	// The whole plan is checked once the final targets are known
+	checkOverwritingSourceConflict(renamedPaths, autoFix)
 }
 
 // Validate detects and reports any conflicts that can occur while renaming a
-// file. Conflicts are automatically fixed if specified in the program options.
+// file. Conflicts are automatically fixed if specified in the program options
+// except for those in conf.NoFix which are always reported. If
+// conf.LongPaths is set, targets may exceed the MAX_PATH limit in Windows.
 func Validate(
 	matches []*file.Change,
-	autoFix, allowOverwrites bool,
+	conf *config.Config,
 ) conflict.Collection {
 	conflicts = make(conflict.Collection)
 
 	changes = matches
 
-	detectConflicts(autoFix, allowOverwrites)
+	detectConflicts(conf)
 
 	return conflicts
 }